@@ -0,0 +1,646 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/webshield-dev/eudvcdecoder/datamodel"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+//
+// Conformance runs the dcc-testdata vectors, see https://github.com/eu-digital-green-certificates/dgc-testdata,
+// through each decode and verify stage and compares the outcome with every EXPECTED* flag in the vector.
+// New country vectors are dropped into testfiles/dcc-testdata/<country>/2DCode/raw
+//
+
+//Check a stage of decoding or verifying, named after the EXPECTED* flag in the test vector
+type Check string
+
+//the checks, see https://github.com/eu-digital-green-certificates/dgc-testdata/blob/main/README.md
+const (
+	//CheckPictureDecode the 2DCODE PNG decodes to the PREFIX
+	CheckPictureDecode Check = "EXPECTEDPICTUREDECODE"
+
+	//CheckUnprefix removing the HC1: from the PREFIX gives the BASE45
+	CheckUnprefix Check = "EXPECTEDUNPREFIX"
+
+	//CheckB45Decode base45 decoding gives the COMPRESSED
+	CheckB45Decode Check = "EXPECTEDB45DECODE"
+
+	//CheckCompression inflating gives the COSE
+	CheckCompression Check = "EXPECTEDCOMPRESSION"
+
+	//CheckValidObject the COSE is a COSE_Sign1
+	CheckValidObject Check = "EXPECTEDVALIDOBJECT"
+
+	//CheckDecode the COSE payload is the CBOR and decodes to a CWT
+	CheckDecode Check = "EXPECTEDDECODE"
+
+	//CheckValidJSON the decoded DCC matches the JSON
+	CheckValidJSON Check = "EXPECTEDVALIDJSON"
+
+	//CheckSchemaValidation the DCC is valid against the JSON schema
+	CheckSchemaValidation Check = "EXPECTEDSCHEMAVALIDATION"
+
+	//CheckEncode the JSON encodes to the CBOR
+	CheckEncode Check = "EXPECTEDENCODE"
+
+	//CheckVerify the COSE signature verifies with the CERTIFICATE
+	CheckVerify Check = "EXPECTEDVERIFY"
+
+	//CheckExpirationCheck the CWT is valid at the VALIDATIONCLOCK
+	CheckExpirationCheck Check = "EXPECTEDEXPIRATIONCHECK"
+
+	//CheckKeyUsage the CERTIFICATE is allowed to sign the certificate type
+	CheckKeyUsage Check = "EXPECTEDKEYUSAGE"
+)
+
+//Checks all the checks in the order they are run
+var Checks = []Check{
+	CheckPictureDecode,
+	CheckUnprefix,
+	CheckB45Decode,
+	CheckCompression,
+	CheckValidObject,
+	CheckDecode,
+	CheckValidJSON,
+	CheckSchemaValidation,
+	CheckEncode,
+	CheckVerify,
+	CheckExpirationCheck,
+	CheckKeyUsage,
+}
+
+//Vector a dcc-testdata raw test vector
+type Vector struct {
+	JSON       json.RawMessage `json:"JSON"`
+	CBOR       string          `json:"CBOR"`
+	COSE       string          `json:"COSE"`
+	Compressed string          `json:"COMPRESSED"`
+	Base45     string          `json:"BASE45"`
+	Prefix     string          `json:"PREFIX"`
+	TwoDCode   string          `json:"2DCODE"`
+	TestCtx    struct {
+		Version         int    `json:"VERSION"`
+		Schema          string `json:"SCHEMA"`
+		Certificate     string `json:"CERTIFICATE"`
+		ValidationClock string `json:"VALIDATIONCLOCK"`
+		Description     string `json:"DESCRIPTION"`
+	} `json:"TESTCTX"`
+	ExpectedResults map[Check]bool `json:"EXPECTEDRESULTS"`
+}
+
+//CheckResult the outcome of one check on a vector
+type CheckResult struct {
+	Check Check
+
+	//Expected the EXPECTED* flag in the vector
+	Expected bool
+
+	//Actual what the library did, only meaningful if Supported
+	Actual bool
+
+	//Supported false if the library cannot run this check
+	Supported bool
+
+	//Detail why the check failed, if known
+	Detail string
+
+	//KnownDeviation the reason if the check did not match the expected and is in KnownDeviations
+	KnownDeviation string
+}
+
+//Passed true if the check was run and matched the expected
+func (cr *CheckResult) Passed() bool {
+	return cr.Supported && cr.Expected == cr.Actual
+}
+
+//Known true if the check did not match the expected and is a known deviation
+func (cr *CheckResult) Known() bool {
+	return cr.Supported && !cr.Passed() && cr.KnownDeviation != ""
+}
+
+//VectorResult the outcome of all the checks on a vector
+type VectorResult struct {
+	Path string
+
+	//RelPath the Path relative to the root with forward slashes, as in KnownDeviations
+	RelPath string
+
+	Country string
+	Checks  []*CheckResult
+}
+
+//Failed the checks that were run and did not match the expected, and are not known deviations
+func (vr *VectorResult) Failed() []*CheckResult {
+	var failed []*CheckResult
+	for _, cr := range vr.Checks {
+		if cr.Supported && !cr.Passed() && !cr.Known() {
+			failed = append(failed, cr)
+		}
+	}
+	return failed
+}
+
+//Known the checks that did not match the expected and are known deviations
+func (vr *VectorResult) Known() []*CheckResult {
+	var known []*CheckResult
+	for _, cr := range vr.Checks {
+		if cr.Known() {
+			known = append(known, cr)
+		}
+	}
+	return known
+}
+
+//Report the outcome of a conformance run
+type Report struct {
+	Vectors []*VectorResult
+}
+
+//Failed the vectors with at least one failed check
+func (r *Report) Failed() []*VectorResult {
+	var failed []*VectorResult
+	for _, vr := range r.Vectors {
+		if len(vr.Failed()) != 0 {
+			failed = append(failed, vr)
+		}
+	}
+	return failed
+}
+
+//WriteTable write a per country table of passed, failed, known deviation and unsupported checks
+func (r *Report) WriteTable(w io.Writer) error {
+
+	type counts struct {
+		vectors, passed, failed, known, unsupported int
+	}
+
+	byCountry := make(map[string]*counts)
+	var countries []string
+	for _, vr := range r.Vectors {
+		c, ok := byCountry[vr.Country]
+		if !ok {
+			c = &counts{}
+			byCountry[vr.Country] = c
+			countries = append(countries, vr.Country)
+		}
+		c.vectors++
+		for _, cr := range vr.Checks {
+			switch {
+			case !cr.Supported:
+				c.unsupported++
+			case cr.Passed():
+				c.passed++
+			case cr.Known():
+				c.known++
+			default:
+				c.failed++
+			}
+		}
+	}
+	sort.Strings(countries)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "COUNTRY\tVECTORS\tPASSED\tFAILED\tKNOWN\tUNSUPPORTED\tRESULT")
+	for _, country := range countries {
+		c := byCountry[country]
+		result := "PASS"
+		if c.failed != 0 {
+			result = "FAIL"
+		} else if c.known != 0 {
+			result = "KNOWN"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%s\n",
+			country, c.vectors, c.passed, c.failed, c.known, c.unsupported, result)
+	}
+
+	return tw.Flush()
+}
+
+//Run walks root for <country>/2DCode/raw/*.json vectors and runs the checks on each
+func Run(root string) (*Report, error) {
+
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(path) == ".json" && strings.EqualFold(filepath.Base(filepath.Dir(path)), "raw") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	report := &Report{}
+	for _, path := range paths {
+		vr, err := RunVector(root, path)
+		if err != nil {
+			return nil, err
+		}
+		report.Vectors = append(report.Vectors, vr)
+	}
+
+	return report, nil
+}
+
+//RunVector run the checks on one vector, the country is the first directory under root
+func RunVector(root string, path string) (*VectorResult, error) {
+
+	data, err := helper.ReadData(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var vector Vector
+	if err := json.Unmarshal(data, &vector); err != nil {
+		return nil, fmt.Errorf("error unmarshalling test vector path=%s err=%s", path, err)
+	}
+
+	relPath := ""
+	if rel, err := filepath.Rel(root, path); err == nil {
+		relPath = filepath.ToSlash(rel)
+	}
+	country := strings.Split(relPath, "/")[0]
+
+	vr := &VectorResult{Path: path, RelPath: relPath, Country: country}
+	actual := runChecks(path, &vector)
+	for _, check := range Checks {
+		expected, ok := vector.ExpectedResults[check]
+		if !ok {
+			continue
+		}
+
+		cr := &CheckResult{Check: check, Expected: expected}
+		if result, ok := actual[check]; ok {
+			cr.Supported = !result.unsupported
+			cr.Actual = result.ok
+			cr.Detail = result.detail
+		}
+		if cr.Supported && !cr.Passed() {
+			cr.KnownDeviation = knownDeviation(relPath, check)
+		}
+		vr.Checks = append(vr.Checks, cr)
+	}
+
+	return vr, nil
+}
+
+type checkOutcome struct {
+	ok     bool
+	detail string
+
+	//unsupported the vector expects a check the library does not do, the detail says which
+	unsupported bool
+}
+
+func pass() checkOutcome {
+	return checkOutcome{ok: true}
+}
+
+func fail(format string, a ...interface{}) checkOutcome {
+	return checkOutcome{detail: fmt.Sprintf(format, a...)}
+}
+
+//runChecks the outcome of each supported check, checks the library does not support are not in the map
+func runChecks(path string, vector *Vector) map[Check]checkOutcome {
+
+	outcomes := make(map[Check]checkOutcome)
+	decoder := helper.NewDecoder(false, false)
+
+	//
+	// picture
+	//
+	if pngB, err := picture(path, vector); err != nil {
+		outcomes[CheckPictureDecode] = fail("%s", err)
+	} else if pictureOutput, err := decoder.FromQRCodePNGBytes(pngB); err != nil &&
+		(pictureOutput == nil || len(pictureOutput.DecodedQRCode) == 0) {
+		outcomes[CheckPictureDecode] = fail("error decoding 2DCODE err=%s", err)
+	} else if string(pictureOutput.DecodedQRCode) != vector.Prefix {
+		outcomes[CheckPictureDecode] = fail("2DCODE does not decode to PREFIX")
+	} else {
+		outcomes[CheckPictureDecode] = pass()
+	}
+
+	//
+	// unprefix
+	//
+	prefix := datamodel.QRCodePrefix + ":"
+	if !decoder.IsDGCFromQRCodeContents([]byte(vector.Prefix)) || !strings.HasPrefix(vector.Prefix, prefix) {
+		outcomes[CheckUnprefix] = fail("PREFIX does not start with %s", prefix)
+	} else if strings.TrimPrefix(vector.Prefix, prefix) != vector.Base45 {
+		outcomes[CheckUnprefix] = fail("unprefixed does not match BASE45")
+	} else {
+		outcomes[CheckUnprefix] = pass()
+	}
+
+	//
+	// decode stages, the decoder stops at the first stage that fails
+	//
+	decodeOutput, decodeErr := decoder.FromQRCodeContents([]byte(vector.Prefix))
+	if decodeOutput == nil {
+		decodeOutput = &helper.Output{}
+	}
+
+	outcomes[CheckB45Decode] = compareHex(decodeOutput.Base45Decoded, vector.Compressed, "COMPRESSED", decodeErr)
+	outcomes[CheckCompression] = compareHex(decodeOutput.Inflated, vector.COSE, "COSE", decodeErr)
+
+	if decodeOutput.SignedCWT == nil || decodeOutput.COSeCBORTag != 18 {
+		outcomes[CheckValidObject] = fail("not a COSE_Sign1 err=%v", decodeErr)
+	} else {
+		outcomes[CheckValidObject] = pass()
+	}
+
+	if decodeOutput.CommonPayload == nil {
+		outcomes[CheckDecode] = fail("CWT payload not decoded err=%v", decodeErr)
+	} else {
+		outcomes[CheckDecode] = compareCBOR(decodeOutput.DCC(), vector.CBOR)
+	}
+
+	outcomes[CheckValidJSON] = compareJSON(decodeOutput, vector.JSON)
+	outcomes[CheckSchemaValidation] = schemaValidation(decodeOutput)
+	outcomes[CheckEncode] = encode(decodeOutput, vector)
+
+	//
+	// verify
+	//
+	for check, outcome := range verifyChecks(decodeOutput, vector) {
+		outcomes[check] = outcome
+	}
+
+	return outcomes
+}
+
+//picture the 2DCODE PNG, some countries do not include it in the vector but have a <country>/png directory
+func picture(path string, vector *Vector) ([]byte, error) {
+
+	if vector.TwoDCode != "" {
+		pngB, err := base64.StdEncoding.DecodeString(vector.TwoDCode)
+		if err != nil {
+			return nil, fmt.Errorf("error base64 decoding 2DCODE err=%s", err)
+		}
+		return pngB, nil
+	}
+
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	pngDir := filepath.Join(filepath.Dir(path), "..", "..", "png")
+	for _, name := range []string{base + "_qr.png", base + ".png"} {
+		if pngB, err := helper.ReadData(filepath.Join(pngDir, name)); err == nil {
+			return pngB, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no 2DCODE and no PNG in %s", pngDir)
+}
+
+func compareHex(actual []byte, expectedHex string, name string, decodeErr error) checkOutcome {
+
+	expected, err := hex.DecodeString(expectedHex)
+	if err != nil {
+		return fail("error hex decoding %s err=%s", name, err)
+	}
+	if len(actual) == 0 {
+		return fail("stage did not complete err=%v", decodeErr)
+	}
+	if !bytes.Equal(actual, expected) {
+		return fail("does not match %s", name)
+	}
+
+	return pass()
+}
+
+//compareDCC the decoded DCC matches the expected, compared as JSON so the number types from CBOR and JSON
+//decoding do not matter
+func compareDCC(dcc *datamodel.DCC, expected *datamodel.DCC, name string) checkOutcome {
+
+	if dcc == nil {
+		return fail("no DCC decoded")
+	}
+
+	actualI, err := normalize(dcc)
+	if err != nil {
+		return fail("error normalizing decoded DCC err=%s", err)
+	}
+	expectedI, err := normalize(expected)
+	if err != nil {
+		return fail("error normalizing %s err=%s", name, err)
+	}
+
+	if !reflect.DeepEqual(expectedI, actualI) {
+		return fail("decoded DCC does not match %s", name)
+	}
+
+	return pass()
+}
+
+func compareJSON(decodeOutput *helper.Output, expectedJSON json.RawMessage) checkOutcome {
+
+	var expected datamodel.DCC
+	if err := json.Unmarshal(expectedJSON, &expected); err != nil {
+		return fail("error unmarshalling JSON err=%s", err)
+	}
+
+	return compareDCC(decodeOutput.DCC(), &expected, "JSON")
+}
+
+//compareCBOR the vector CBOR is either the whole CWT payload or only the DCC, and may use a different
+//CBOR encoding such as indefinite length maps, so compare what it decodes to
+func compareCBOR(dcc *datamodel.DCC, expectedHex string) checkOutcome {
+
+	cborB, err := hex.DecodeString(expectedHex)
+	if err != nil {
+		return fail("error hex decoding CBOR err=%s", err)
+	}
+
+	var payload datamodel.DGCPayloadCBORMapping
+	var expected *datamodel.DCC
+	if err := cbor.Unmarshal(cborB, &payload); err == nil && payload.HCERT.DCC() != nil {
+		expected = payload.HCERT.DCC()
+	} else {
+		expected = &datamodel.DCC{}
+		if err := cbor.Unmarshal(cborB, expected); err != nil {
+			return fail("error unmarshalling CBOR err=%s", err)
+		}
+	}
+
+	return compareDCC(dcc, expected, "CBOR")
+}
+
+//encode the JSON with the iss, iat and exp of the decoded CWT, the encoding need not be byte for byte the same
+//as the issuer's, such as map key order, so compare what the encoded CBOR decodes to with the vector CBOR
+func encode(decodeOutput *helper.Output, vector *Vector) checkOutcome {
+
+	if decodeOutput.CommonPayload == nil {
+		return fail("CWT payload not decoded")
+	}
+
+	var dcc datamodel.DCC
+	if err := json.Unmarshal(vector.JSON, &dcc); err != nil {
+		return fail("error unmarshalling JSON err=%s", err)
+	}
+
+	payload := &datamodel.DGCCommonPayload{
+		ISS:   decodeOutput.CommonPayload.ISS,
+		IAT:   decodeOutput.CommonPayload.IAT,
+		EXP:   decodeOutput.CommonPayload.EXP,
+		HCERT: datamodel.HCERTMap{datamodel.HCERTMapKeyOne: &dcc},
+	}
+	payloadB, err := helper.NewEncoder().CBOREncodePayload(payload)
+	if err != nil {
+		return fail("error encoding JSON err=%s", err)
+	}
+
+	var encoded datamodel.DGCPayloadCBORMapping
+	if err := cbor.Unmarshal(payloadB, &encoded); err != nil {
+		return fail("error unmarshalling encoded CBOR err=%s", err)
+	}
+	if encoded.ISS != payload.ISS || encoded.IAT != payload.IAT || encoded.EXP != payload.EXP {
+		return fail("encoded CWT claims do not match")
+	}
+
+	return compareCBOR(encoded.HCERT.DCC(), vector.CBOR)
+}
+
+//normalize JSON encode and decode the DCC
+func normalize(dcc *datamodel.DCC) (interface{}, error) {
+
+	jsonB, err := json.Marshal(dcc)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized interface{}
+	err = json.Unmarshal(jsonB, &normalized)
+
+	return normalized, err
+}
+
+//schemaValidation the DCC is valid against its JSON schema
+func schemaValidation(decodeOutput *helper.Output) checkOutcome {
+
+	if !decodeOutput.SchemaValidated {
+		return fail("schema not validated %v", decodeOutput.DiagnoseLines)
+	}
+	if len(decodeOutput.SchemaViolations) != 0 {
+		return fail("schema violations %v", decodeOutput.SchemaViolations)
+	}
+
+	return pass()
+}
+
+//verifyChecks verify with the vector CERTIFICATE at the VALIDATIONCLOCK
+func verifyChecks(decodeOutput *helper.Output, vector *Vector) map[Check]checkOutcome {
+
+	outcomes := make(map[Check]checkOutcome)
+	if decodeOutput.CommonPayload == nil {
+		for _, check := range []Check{CheckVerify, CheckExpirationCheck, CheckKeyUsage} {
+			outcomes[check] = fail("not decoded")
+		}
+		return outcomes
+	}
+
+	opts := &verifier.VerifyOptions{}
+
+	der, err := base64.StdEncoding.DecodeString(vector.TestCtx.Certificate)
+	if err == nil {
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(der); err == nil {
+			kid := sha256.Sum256(cert.Raw)
+			opts.KeyResolver = verifier.NewMemoryKeyResolver(&verifier.SigningKey{
+				Kid:         kid[:8],
+				PublicKey:   cert.PublicKey,
+				Certificate: cert,
+			})
+		}
+	}
+	if err != nil {
+		outcomes[CheckVerify] = fail("error reading CERTIFICATE err=%s", err)
+		opts.UnSafe = true
+	}
+
+	if clock, err := parseValidationClock(vector.TestCtx.ValidationClock); err == nil {
+		opts.Clock = verifier.FixedClock(clock)
+	} else {
+		outcomes[CheckExpirationCheck] = fail("%s", err)
+	}
+
+	dgVerifier, err := verifier.NewVerifier(false, false, nil)
+	if err != nil {
+		return outcomes
+	}
+
+	verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), []byte(vector.Prefix), opts)
+	if err != nil {
+		for _, check := range []Check{CheckVerify, CheckExpirationCheck, CheckKeyUsage} {
+			if _, ok := outcomes[check]; !ok {
+				outcomes[check] = fail("error verifying err=%s", err)
+			}
+		}
+		return outcomes
+	}
+
+	failureReasons := make(map[verifier.FailureReason]bool)
+	for _, reason := range verifierOutput.FailureReasons {
+		failureReasons[reason] = true
+	}
+
+	//the signature is valid even if the key was not allowed to sign, that is the key usage check
+	if _, ok := outcomes[CheckVerify]; !ok {
+		if verifierOutput.SigningKey != nil {
+			outcomes[CheckVerify] = pass()
+		} else {
+			outcomes[CheckVerify] = fail("signature not valid")
+		}
+	}
+
+	if _, ok := outcomes[CheckExpirationCheck]; !ok {
+		if failureReasons[verifier.FailureReasonExpired] || failureReasons[verifier.FailureReasonNotYetValid] {
+			outcomes[CheckExpirationCheck] = fail("not valid at VALIDATIONCLOCK %v", verifierOutput.FailureReasons)
+		} else {
+			outcomes[CheckExpirationCheck] = pass()
+		}
+	}
+
+	if verifierOutput.SigningKey == nil {
+		outcomes[CheckKeyUsage] = fail("signature not valid")
+	} else if failureReasons[verifier.FailureReasonKeyUsage] {
+		outcomes[CheckKeyUsage] = fail("key usage does not allow the certificate type")
+	} else {
+		outcomes[CheckKeyUsage] = pass()
+	}
+
+	return outcomes
+}
+
+//parseValidationClock the VALIDATIONCLOCK is usually RFC3339, some have no time zone in which case are UTC
+func parseValidationClock(validationClock string) (time.Time, error) {
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if clock, err := time.Parse(layout, validationClock); err == nil {
+			return clock, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("error parsing VALIDATIONCLOCK=%s", validationClock)
+}
@@ -0,0 +1,38 @@
+package conformance_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/conformance"
+)
+
+func Test_Conformance_DCC_TestData(t *testing.T) {
+
+	report, err := conformance.Run("../testfiles/dcc-testdata")
+	require.NoError(t, err)
+	require.NotEmpty(t, report.Vectors, "should have found the test vectors")
+
+	table := &strings.Builder{}
+	require.NoError(t, report.WriteTable(table))
+	t.Logf("\n%s", table)
+
+	for _, vr := range report.Failed() {
+		for _, cr := range vr.Failed() {
+			t.Errorf("%s %s expected=%t actual=%t %s", vr.Path, cr.Check, cr.Expected, cr.Actual, cr.Detail)
+		}
+	}
+
+	//every known deviation must still deviate, otherwise remove it from the list
+	var expectedKnown, actualKnown []string
+	for _, deviation := range conformance.KnownDeviations {
+		expectedKnown = append(expectedKnown, deviation.Path+" "+string(deviation.Check))
+	}
+	for _, vr := range report.Vectors {
+		for _, cr := range vr.Known() {
+			actualKnown = append(actualKnown, vr.RelPath+" "+string(cr.Check))
+		}
+	}
+	require.ElementsMatch(t, expectedKnown, actualKnown, "should match the known deviations")
+}
@@ -0,0 +1,89 @@
+package conformance
+
+//
+// Known deviations are vectors where the library does not match an EXPECTED* flag for a documented reason, they are
+// listed one by one so a new vector that fails for the same reason is still reported as a failure
+//
+
+//the NL vectors expect these to fail the schema validation, neither is a rule in the ehn-dcc JSON schema
+const (
+	reasonFutureDOB      = "date of birth after the VALIDATIONCLOCK is not a schema rule"
+	reasonNameOutsideBMP = "name with characters outside the Basic Multilingual Plane is not a schema rule"
+)
+
+//KnownDeviation a check on a vector that is known not to match the expected
+type KnownDeviation struct {
+
+	//Path the vector path relative to the dcc-testdata root, with forward slashes
+	Path string
+
+	//Check the check that does not match
+	Check Check
+
+	//Reason why the library does not match
+	Reason string
+}
+
+//KnownDeviations the known deviations in the dcc-testdata vectors
+var KnownDeviations = []KnownDeviation{
+	{Path: "NL/2DCode/raw/008-NL-test.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/014-NL-test.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/023-NL-test.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/037-NL-test.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/038-NL-test.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/039-NL-test.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/052-NL-vaccination.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/059-NL-vaccination.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/067-NL-vaccination.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/084-NL-vaccination.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/089-NL-vaccination.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/090-NL-vaccination.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/091-NL-vaccination.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/092-NL-vaccination.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/125-NL-recovery.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/139-NL-recovery.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/140-NL-recovery.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/141-NL-recovery.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/170-NL-test+vaccination.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/181-NL-test+vaccination.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/182-NL-test+vaccination.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/183-NL-test+vaccination.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/184-NL-test+vaccination.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/185-NL-test+vaccination.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB + " and " + reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/200-NL-test+recovery.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/206-NL-test+recovery.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/212-NL-test+recovery.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/217-NL-test+recovery.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/228-NL-test+recovery.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/229-NL-test+recovery.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/236-NL-recovery+vaccination.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/248-NL-recovery+vaccination.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/254-NL-recovery+vaccination.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/275-NL-recovery+vaccination.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/276-NL-recovery+vaccination.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/284-NL-test+recovery+vaccination.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/290-NL-test+recovery+vaccination.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/295-NL-test+recovery+vaccination.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/308-NL-test+recovery+vaccination.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/324-NL-test+recovery+vaccination.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/325-NL-test+recovery+vaccination.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/326-NL-test+recovery+vaccination.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/337-NL-test+wrong_key.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/342-NL-test+wrong_key.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/348-NL-test+wrong_key.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/352-NL-test+wrong_key.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/364-NL-test+wrong_key.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB},
+	{Path: "NL/2DCode/raw/365-NL-test+wrong_key.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/366-NL-test+wrong_key.json", Check: CheckSchemaValidation, Reason: reasonNameOutsideBMP},
+	{Path: "NL/2DCode/raw/367-NL-test+wrong_key.json", Check: CheckSchemaValidation, Reason: reasonFutureDOB + " and " + reasonNameOutsideBMP},
+}
+
+//knownDeviation the reason the check on the vector is a known deviation, empty if it is not one
+func knownDeviation(relPath string, check Check) string {
+	for _, deviation := range KnownDeviations {
+		if deviation.Path == relPath && deviation.Check == check {
+			return deviation.Reason
+		}
+	}
+	return ""
+}
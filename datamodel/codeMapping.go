@@ -0,0 +1,43 @@
+package datamodel
+
+//CodeMappings maps the DCC value set codes to the codes used by US systems, such as CVX, MVX and ATC, it is
+//versioned so a verifier can say which mapping it used
+type CodeMappings struct {
+
+	//MappingID its id
+	MappingID string `json:"mappingId,omitempty"`
+
+	//Version the mapping version, changes when a mapping is added or corrected
+	Version string `json:"version,omitempty"`
+
+	//MappingDate when the mapping was last reviewed
+	MappingDate string `json:"mappingDate,omitempty"`
+
+	//Sources where the target codes come from, by code system
+	Sources map[string]string `json:"sources,omitempty"`
+
+	//MedicinalProducts by vaccine-medicinal-product.json code
+	MedicinalProducts map[string]CodeMapping `json:"medicinalProducts,omitempty"`
+
+	//Prophylaxis by vaccine-prophylaxis.json code
+	Prophylaxis map[string]CodeMapping `json:"prophylaxis,omitempty"`
+
+	//MarketingAuthorisationHolders by vaccine-mah-manf.json code
+	MarketingAuthorisationHolders map[string]CodeMapping `json:"marketingAuthorisationHolders,omitempty"`
+}
+
+//CodeMapping the codes a value set code maps to, empty if there is no equivalent
+type CodeMapping struct {
+
+	//CVX CDC vaccine administered code
+	CVX string `json:"cvx,omitempty"`
+
+	//MVX CDC manufacturer code
+	MVX string `json:"mvx,omitempty"`
+
+	//SNOMED SNOMED CT vaccine type
+	SNOMED string `json:"snomed,omitempty"`
+
+	//ATC WHO Anatomical Therapeutic Chemical code
+	ATC string `json:"atc,omitempty"`
+}
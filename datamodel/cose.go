@@ -1,24 +1,5 @@
 package datamodel
 
-//COSEHeader only contains what is specified in the vaccine credential
-//https://ec.europa.eu/health/sites/default/files/ehealth/docs/digital-green-certificates_v3_en.pdf
-// see CBOR https://datatracker.ietf.org/doc/html/rfc8152#section-3.1 for where 1 and 4 come from
-//  Generic_Headers = (
-//       ? 1 => int / tstr,  ; algorithm identifier
-//       ? 2 => [+label],    ; criticality
-//       ? 3 => tstr / int,  ; content type
-//       ? 4 => bstr,        ; key identifier
-//       ? 5 => bstr,        ; IV
-//       ? 6 => bstr,        ; Partial IV
-//       ? 7 => COSE_Signature / [+COSE_Signature] ; Counter signature
-//
-type COSEHeader struct {
-	Alg int     `cbor:"1,keyasint,omitempty"`
-
-	//Kid has a []byte but ran into issue with unmarshalled so changed to a unit8
-	Kid []uint8 `cbor:"4,keyasint,omitempty"`
-}
-
 //SignedCWT the CBOR web token (CWT) see https://datatracker.ietf.org/doc/html/rfc8392
 type SignedCWT struct {
     _ struct{} `cbor:",toarray"`
@@ -41,3 +22,139 @@ type SignedCWT struct {
     //The COSE signature - is a singe signer
     Signature []byte
 }
+
+//COSESign a COSE_Sign message with one or more signers, each signer has its own headers and signature,
+//see https://datatracker.ietf.org/doc/html/rfc8152#section-4.1
+//  COSE_Sign = [
+//       Headers,
+//       payload : bstr / nil,
+//       signatures : [+ COSE_Signature]
+//   ]
+type COSESign struct {
+	_ struct{} `cbor:",toarray"`
+
+	Protected   []byte
+	Unprotected COSEHeader
+	Payload     []byte
+	Signatures  []*COSESignature
+}
+
+//COSESignature one signer of a COSE_Sign, see https://datatracker.ietf.org/doc/html/rfc8152#section-4.1
+//  COSE_Signature =  [
+//       Headers,
+//       signature : bstr
+//   ]
+type COSESignature struct {
+	_ struct{} `cbor:",toarray"`
+
+	//Protected the CBOR encoded signer protected headers wrapped in a byte string
+	Protected []byte
+
+	//Unprotected the signer unprotected headers
+	Unprotected COSEHeader
+
+	Signature []byte
+}
+
+//CBOR tags of the COSE and CWT messages, see https://datatracker.ietf.org/doc/html/rfc8152#section-2 and
+//https://datatracker.ietf.org/doc/html/rfc8392#section-6
+const (
+	//COSESign1Tag a COSE_Sign1 single signer message, the tag used by the EU DCC
+	COSESign1Tag uint64 = 18
+
+	//COSESignTag a COSE_Sign multiple signer message
+	COSESignTag uint64 = 98
+
+	//CWTTag a CWT, it wraps the tagged COSE message
+	CWTTag uint64 = 61
+)
+
+//COSE algorithm identifiers used by the EU DCC issuers
+//see https://datatracker.ietf.org/doc/html/rfc8152#section-8.1 and https://datatracker.ietf.org/doc/html/rfc8230#section-2
+const (
+	//COSEAlgES256 ECDSA w/ SHA-256
+	COSEAlgES256 int = -7
+
+	//COSEAlgPS256 RSASSA-PSS w/ SHA-256
+	COSEAlgPS256 int = -37
+)
+
+//COSESign1Context the context string used in the Sig_structure for a COSE_Sign1
+const COSESign1Context = "Signature1"
+
+//COSESignContext the context string used in the Sig_structure for a signer of a COSE_Sign
+const COSESignContext = "Signature"
+
+//SigStructure is what is actually signed for a COSE_Sign1 message,
+//see https://datatracker.ietf.org/doc/html/rfc8152#section-4.4
+//  Sig_structure = [
+//       context : "Signature" / "Signature1" / "CounterSignature",
+//       body_protected : empty_or_serialized_map,
+//       ? sign_protected : empty_or_serialized_map,
+//       external_aad : bstr,
+//       payload : bstr
+//   ]
+type SigStructure struct {
+	_ struct{} `cbor:",toarray"`
+
+	Context       string
+	BodyProtected []byte
+	ExternalAAD   []byte
+	Payload       []byte
+}
+
+//SigStructure rebuild the Sig_structure that the issuer signed, the byte strings must
+//never be nil as nil is CBOR encoded as null not an empty bstr
+func (s *SignedCWT) SigStructure(externalAAD []byte) *SigStructure {
+
+	ss := &SigStructure{
+		Context:       COSESign1Context,
+		BodyProtected: s.Protected,
+		ExternalAAD:   externalAAD,
+		Payload:       s.Payload,
+	}
+
+	if ss.BodyProtected == nil {
+		ss.BodyProtected = []byte{}
+	}
+	if ss.ExternalAAD == nil {
+		ss.ExternalAAD = []byte{}
+	}
+	if ss.Payload == nil {
+		ss.Payload = []byte{}
+	}
+
+	return ss
+}
+
+//SignerSigStructure is what is actually signed by a signer of a COSE_Sign message, it also has the signer
+//protected headers see https://datatracker.ietf.org/doc/html/rfc8152#section-4.4
+type SignerSigStructure struct {
+	_ struct{} `cbor:",toarray"`
+
+	Context       string
+	BodyProtected []byte
+	SignProtected []byte
+	ExternalAAD   []byte
+	Payload       []byte
+}
+
+//SignerSigStructure rebuild the Sig_structure the signer of a COSE_Sign signed, the message headers and payload
+//are in s
+func (s *SignedCWT) SignerSigStructure(signature *COSESignature, externalAAD []byte) *SignerSigStructure {
+
+	ss := s.SigStructure(externalAAD)
+
+	signerSS := &SignerSigStructure{
+		Context:       COSESignContext,
+		BodyProtected: ss.BodyProtected,
+		SignProtected: signature.Protected,
+		ExternalAAD:   ss.ExternalAAD,
+		Payload:       ss.Payload,
+	}
+	if signerSS.SignProtected == nil {
+		signerSS.SignProtected = []byte{}
+	}
+
+	return signerSS
+}
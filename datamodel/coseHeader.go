@@ -0,0 +1,308 @@
+package datamodel
+
+import (
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+//
+// COSE headers see https://datatracker.ietf.org/doc/html/rfc8152#section-3.1 and for x5chain and x5t
+// https://datatracker.ietf.org/doc/html/draft-ietf-cose-x509-08#section-2
+//  Generic_Headers = (
+//       ? 1 => int / tstr,  ; algorithm identifier
+//       ? 2 => [+label],    ; criticality
+//       ? 3 => tstr / int,  ; content type
+//       ? 4 => bstr,        ; key identifier
+//       ? 5 => bstr,        ; IV
+//       ? 6 => bstr,        ; Partial IV
+//       ? 7 => COSE_Signature / [+COSE_Signature] ; Counter signature
+//   )
+//   33 => COSE_X509 x5chain, 34 => COSE_CertHash x5t
+//
+// A message has a protected bucket, that is signed, and an unprotected bucket, that is not. A label should only be
+// in one of them, if it is in both the protected value is used
+//
+
+//COSE header labels
+const (
+	HeaderLabelAlg              int64 = 1
+	HeaderLabelCrit             int64 = 2
+	HeaderLabelContentType      int64 = 3
+	HeaderLabelKid              int64 = 4
+	HeaderLabelIV               int64 = 5
+	HeaderLabelPartialIV        int64 = 6
+	HeaderLabelCounterSignature int64 = 7
+	HeaderLabelX5Chain          int64 = 33
+	HeaderLabelX5T              int64 = 34
+)
+
+//UnderstoodHeaderLabels the labels of the COSEHeader fields, a crit label not in this list is not understood
+var UnderstoodHeaderLabels = []int64{HeaderLabelAlg, HeaderLabelCrit, HeaderLabelContentType, HeaderLabelKid,
+	HeaderLabelIV, HeaderLabelPartialIV, HeaderLabelCounterSignature, HeaderLabelX5Chain, HeaderLabelX5T}
+
+//COSEHeader one bucket of COSE header parameters
+type COSEHeader struct {
+
+	//Alg the COSE algorithm such as COSEAlgES256, an int or a tstr, nil if there is none
+	Alg *COSEAlgorithm `cbor:"1,keyasint,omitempty"`
+
+	//Crit the labels the receiver must understand, only allowed in the protected bucket, int or tstr labels
+	Crit []interface{} `cbor:"2,keyasint,omitempty"`
+
+	//ContentType the content type of the payload, a tstr media type or an int CoAP content format
+	ContentType interface{} `cbor:"3,keyasint,omitempty"`
+
+	//Kid has a []byte but ran into issue with unmarshalled so changed to a unit8
+	Kid []uint8 `cbor:"4,keyasint,omitempty"`
+
+	//IV the full initialization vector
+	IV []byte `cbor:"5,keyasint,omitempty"`
+
+	//PartialIV part of the initialization vector
+	PartialIV []byte `cbor:"6,keyasint,omitempty"`
+
+	//CounterSignature one COSE_Signature or an array of them, kept CBOR encoded
+	CounterSignature cbor.RawMessage `cbor:"7,keyasint,omitempty"`
+
+	//X5Chain the certificate chain, the signing certificate first, kept CBOR encoded see X5ChainCertificates
+	X5Chain cbor.RawMessage `cbor:"33,keyasint,omitempty"`
+
+	//X5T the hash of the signing certificate
+	X5T *X5T `cbor:"34,keyasint,omitempty"`
+}
+
+//X5ChainCertificates the DER encoded X.509 certificates of X5Chain, encoded as a bstr if there is one otherwise
+//an array of bstr. Decoded with decMode so the CBOR limits apply, nil if there is no x5chain
+func (h *COSEHeader) X5ChainCertificates(decMode cbor.DecMode) ([][]byte, error) {
+
+	if len(h.X5Chain) == 0 {
+		return nil, nil
+	}
+
+	var certificate []byte
+	if err := decMode.Unmarshal(h.X5Chain, &certificate); err == nil {
+		return [][]byte{certificate}, nil
+	}
+
+	var chain [][]byte
+	if err := decMode.Unmarshal(h.X5Chain, &chain); err != nil {
+		return nil, fmt.Errorf("error x5chain must be a bstr or an array of bstr err=%w", err)
+	}
+
+	return chain, nil
+}
+
+//X5T the hash of a certificate, COSE_CertHash = [ hashAlg : int / tstr, hashValue : bstr ]
+type X5T struct {
+	_ struct{} `cbor:",toarray"`
+
+	//Alg the COSE hash algorithm, such as -16 for SHA-256
+	Alg COSEAlgorithm
+
+	Hash []byte
+}
+
+//COSEAlgorithm a COSE algorithm identifier, an int such as COSEAlgES256 or a tstr, see
+//https://datatracker.ietf.org/doc/html/rfc9052#section-3.1. There are no registered tstr algorithms so a verifier
+//treats them as unsupported rather than failing the header decode
+type COSEAlgorithm struct {
+
+	//Int the algorithm if an int, 0 if a tstr
+	Int int
+
+	//Text the algorithm if a tstr, empty if an int
+	Text string
+}
+
+//IntAlgorithm the algorithm for an int identifier such as COSEAlgES256
+func IntAlgorithm(alg int) *COSEAlgorithm {
+	return &COSEAlgorithm{Int: alg}
+}
+
+//String the int, or the tstr quoted
+func (a COSEAlgorithm) String() string {
+	if a.Text != "" {
+		return fmt.Sprintf("%q", a.Text)
+	}
+	return fmt.Sprintf("%d", a.Int)
+}
+
+//MarshalCBOR the tstr if Text is set otherwise the int
+func (a COSEAlgorithm) MarshalCBOR() ([]byte, error) {
+	if a.Text != "" {
+		return cbor.Marshal(a.Text)
+	}
+	return cbor.Marshal(a.Int)
+}
+
+//UnmarshalCBOR from an int or a tstr, an error for any other type
+func (a *COSEAlgorithm) UnmarshalCBOR(data []byte) error {
+
+	var alg int
+	if err := cbor.Unmarshal(data, &alg); err == nil {
+		*a = COSEAlgorithm{Int: alg}
+		return nil
+	}
+
+	var text string
+	if err := cbor.Unmarshal(data, &text); err != nil || text == "" {
+		return fmt.Errorf("error COSE algorithm must be an int or a tstr cbor=%x", data)
+	}
+	*a = COSEAlgorithm{Text: text}
+
+	return nil
+}
+
+//HeaderBucket where a header parameter was found
+type HeaderBucket string
+
+const (
+	//HeaderBucketNone not in either bucket
+	HeaderBucketNone HeaderBucket = ""
+
+	//HeaderBucketProtected in the signed protected bucket
+	HeaderBucketProtected HeaderBucket = "protected"
+
+	//HeaderBucketUnprotected only in the unprotected bucket so not covered by the signature
+	HeaderBucketUnprotected HeaderBucket = "unprotected"
+)
+
+//COSEHeaders the protected and unprotected buckets of a COSE message or signer
+type COSEHeaders struct {
+
+	//Protected the CBOR decoded protected bucket, empty if there is none
+	Protected *COSEHeader
+
+	//Unprotected the unprotected bucket
+	Unprotected *COSEHeader
+}
+
+//NewCOSEHeaders CBOR decode the protected bucket, which is the CBOR encoded map wrapped in a bstr, and check the
+//x5chain of both buckets. Decoded with decMode so the CBOR limits apply
+func NewCOSEHeaders(decMode cbor.DecMode, protected []byte, unprotected COSEHeader) (*COSEHeaders, error) {
+
+	headers := &COSEHeaders{Protected: &COSEHeader{}, Unprotected: &unprotected}
+	if len(protected) != 0 {
+		if err := decMode.Unmarshal(protected, headers.Protected); err != nil {
+			return nil, fmt.Errorf("error cbor.Unmarshal protected header err=%w", err)
+		}
+	}
+
+	for _, header := range []*COSEHeader{headers.Protected, headers.Unprotected} {
+		if _, err := header.X5ChainCertificates(decMode); err != nil {
+			return nil, err
+		}
+	}
+
+	return headers, nil
+}
+
+//Merged one header with each parameter from the protected bucket if it is there otherwise the unprotected
+func (h *COSEHeaders) Merged() *COSEHeader {
+
+	p, u := h.Protected, h.Unprotected
+	merged := *u
+
+	if p.Alg != nil {
+		merged.Alg = p.Alg
+	}
+	if len(p.Crit) != 0 {
+		merged.Crit = p.Crit
+	}
+	if p.ContentType != nil {
+		merged.ContentType = p.ContentType
+	}
+	if len(p.Kid) != 0 {
+		merged.Kid = p.Kid
+	}
+	if len(p.IV) != 0 {
+		merged.IV = p.IV
+	}
+	if len(p.PartialIV) != 0 {
+		merged.PartialIV = p.PartialIV
+	}
+	if len(p.CounterSignature) != 0 {
+		merged.CounterSignature = p.CounterSignature
+	}
+	if len(p.X5Chain) != 0 {
+		merged.X5Chain = p.X5Chain
+	}
+	if p.X5T != nil {
+		merged.X5T = p.X5T
+	}
+
+	return &merged
+}
+
+//KidBucket where the kid is, some issuers only put it in the unprotected bucket so it is not signed
+func (h *COSEHeaders) KidBucket() HeaderBucket {
+
+	switch {
+	case len(h.Protected.Kid) != 0:
+		return HeaderBucketProtected
+	case len(h.Unprotected.Kid) != 0:
+		return HeaderBucketUnprotected
+	default:
+		return HeaderBucketNone
+	}
+}
+
+//ValidateCrit errors if crit is in the unprotected bucket, is empty, or has a label that is not understood,
+//see https://datatracker.ietf.org/doc/html/rfc8152#section-3.1
+func (h *COSEHeaders) ValidateCrit() error {
+
+	if h.Unprotected.Crit != nil {
+		return fmt.Errorf("error crit must be in the protected header")
+	}
+
+	if h.Protected.Crit == nil {
+		return nil
+	}
+	if len(h.Protected.Crit) == 0 {
+		return fmt.Errorf("error crit must have at least one label")
+	}
+
+	for _, label := range h.Protected.Crit {
+		if !understoodHeaderLabel(label) {
+			return fmt.Errorf("error crit label=%v is not understood", label)
+		}
+	}
+
+	return nil
+}
+
+//understoodHeaderLabel true if the label is an int in UnderstoodHeaderLabels
+func understoodHeaderLabel(label interface{}) bool {
+
+	var intLabel int64
+	switch label := label.(type) {
+	case uint64:
+		if label > uint64(HeaderLabelX5T) {
+			return false
+		}
+		intLabel = int64(label)
+	case int64:
+		intLabel = label
+	default:
+		return false
+	}
+
+	for _, understood := range UnderstoodHeaderLabels {
+		if intLabel == understood {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Headers the message headers, see NewCOSEHeaders
+func (s *SignedCWT) Headers(decMode cbor.DecMode) (*COSEHeaders, error) {
+	return NewCOSEHeaders(decMode, s.Protected, s.Unprotected)
+}
+
+//Headers the signer headers, see NewCOSEHeaders
+func (s *COSESignature) Headers(decMode cbor.DecMode) (*COSEHeaders, error) {
+	return NewCOSEHeaders(decMode, s.Protected, s.Unprotected)
+}
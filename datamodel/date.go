@@ -0,0 +1,185 @@
+package datamodel
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+//DateLayout the DCC full date format, YYYY-MM-DD
+const DateLayout = "2006-01-02"
+
+//Date a DCC full date without time, such as the recovery df and du. Encoded as a YYYY-MM-DD string in
+//both JSON and CBOR, the time is midnight UTC
+type Date struct {
+	time.Time
+}
+
+//ParseDate parse a YYYY-MM-DD date
+func ParseDate(value string) (Date, error) {
+
+	t, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return Date{}, fmt.Errorf("error parsing date=%s err=%s", value, err)
+	}
+
+	return Date{Time: t}, nil
+}
+
+//String the YYYY-MM-DD date, empty if zero
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format(DateLayout)
+}
+
+//MarshalJSON as a YYYY-MM-DD string
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+//UnmarshalJSON from a YYYY-MM-DD string
+func (d *Date) UnmarshalJSON(data []byte) error {
+
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	return d.parse(value)
+}
+
+//MarshalCBOR as a YYYY-MM-DD text string
+func (d Date) MarshalCBOR() ([]byte, error) {
+	return cbor.Marshal(d.String())
+}
+
+//UnmarshalCBOR from a YYYY-MM-DD text string, the RFC 8943 full-date tag 1004 is allowed
+func (d *Date) UnmarshalCBOR(data []byte) error {
+
+	var value string
+	if err := cbor.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	return d.parse(value)
+}
+
+func (d *Date) parse(value string) error {
+
+	if value == "" {
+		*d = Date{}
+		return nil
+	}
+
+	parsed, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+
+	return nil
+}
+
+//dateTimeLayouts the layouts a DateTime is parsed with, RFC 3339 first. Some issuers leave out the time zone, read
+//as UTC, write it without a colon, or only give the date
+var dateTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05Z0700", "2006-01-02T15:04:05", DateLayout}
+
+//DateTime a DCC date and time such as the test sc and dr, which should be RFC 3339. Issuers do not all follow that
+//so a value that cannot be parsed does not fail the decode, Raw keeps the value and ParseProblem says why
+type DateTime struct {
+	time.Time
+
+	//Raw the value as encoded, empty if the DateTime was not decoded
+	Raw string
+
+	//ParseProblem why Raw could not be parsed, empty if it was
+	ParseProblem string
+}
+
+//ParseDateTime parse a date and time, see dateTimeLayouts
+func ParseDateTime(value string) (DateTime, error) {
+
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return DateTime{Time: t, Raw: value}, nil
+		}
+	}
+
+	return DateTime{}, fmt.Errorf("error parsing date time=%s is not RFC 3339", value)
+}
+
+//String RFC 3339 if parsed, otherwise the Raw value
+func (d DateTime) String() string {
+	if d.IsZero() {
+		return d.Raw
+	}
+	return d.Format(time.RFC3339)
+}
+
+//MarshalJSON the Raw value if decoded so it is unchanged, otherwise RFC 3339
+func (d DateTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.encoded())
+}
+
+//UnmarshalJSON from a date time string, see DateTime for a value that cannot be parsed
+func (d *DateTime) UnmarshalJSON(data []byte) error {
+
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	d.parse(value)
+	return nil
+}
+
+//MarshalCBOR the Raw value if decoded so it is unchanged, otherwise RFC 3339
+func (d DateTime) MarshalCBOR() ([]byte, error) {
+	return cbor.Marshal(d.encoded())
+}
+
+//UnmarshalCBOR from a date time text string, a tag 0 date time string or a tag 1 epoch time is allowed. Any other
+//value is recorded as a ParseProblem
+func (d *DateTime) UnmarshalCBOR(data []byte) error {
+
+	var value string
+	if err := cbor.Unmarshal(data, &value); err == nil {
+		d.parse(value)
+		return nil
+	}
+
+	var t time.Time
+	if err := cbor.Unmarshal(data, &t); err == nil {
+		*d = DateTime{Time: t, Raw: t.Format(time.RFC3339)}
+		return nil
+	}
+
+	*d = DateTime{ParseProblem: fmt.Sprintf("error date time is not a string cbor=%x", data)}
+	return nil
+}
+
+func (d DateTime) encoded() string {
+	if d.Raw != "" {
+		return d.Raw
+	}
+	return d.String()
+}
+
+func (d *DateTime) parse(value string) {
+
+	if value == "" {
+		*d = DateTime{}
+		return
+	}
+
+	parsed, err := ParseDateTime(value)
+	if err != nil {
+		*d = DateTime{Raw: value, ParseProblem: err.Error()}
+		return
+	}
+	*d = parsed
+}
@@ -1,5 +1,6 @@
 package datamodel
 
+import "time"
 
 //QRCodePrefix the DGC is prefixed with this before converting into a QR code PNG
 const QRCodePrefix = "HC1"
@@ -13,8 +14,41 @@ type DCC struct {
 	DOB      string      `json:"dob"`
 	Name     Name        `json:"nam,omitempty"`
 	Vaccine  []Vaccine   `json:"v,omitempty"`
-	Test     interface{} `json:"t,omitempty"`
-	Recovery interface{} `json:"r,omitempty"`
+	Test     []Test      `json:"t,omitempty"`
+	Recovery []Recovery `json:"r,omitempty"`
+}
+
+//DCCGroup the group of entries a DCC holds, its json key
+type DCCGroup string
+
+const (
+	//DCCGroupVaccination the DCC has a vaccination entry (v)
+	DCCGroupVaccination DCCGroup = "v"
+
+	//DCCGroupTest the DCC has a test entry (t)
+	DCCGroupTest DCCGroup = "t"
+
+	//DCCGroupRecovery the DCC has a recovery entry (r)
+	DCCGroupRecovery DCCGroup = "r"
+)
+
+//Group returns the group of the DCC, a DCC should only have one of v, t, r. If more than one
+//is populated the vaccination takes precedence, then the recovery, then the test.
+//Returns empty if none are populated
+func (d *DCC) Group() DCCGroup {
+
+	switch {
+	case d == nil:
+		return ""
+	case len(d.Vaccine) != 0:
+		return DCCGroupVaccination
+	case len(d.Recovery) != 0:
+		return DCCGroupRecovery
+	case len(d.Test) != 0:
+		return DCCGroupTest
+	default:
+		return ""
+	}
 }
 
 //Name as defined in https://github.com/ehn-dcc-development/ehn-dcc-schema
@@ -125,6 +159,110 @@ type Vaccine struct {
 	CI string `json:"ci,omitempty"`
 }
 
+//Test Test group, if present, MUST contain exactly 1 (one) entry describing exactly one test result.
+type Test struct {
+
+	//TG Disease or agent targeted, a coded value from the value set disease-agent-targeted.json.
+	TG string `json:"tg"`
+
+	//TT The type of test, a coded value from the value set test-type.json
+	//"tt": "LP6464-4" (Nucleic acid amplification with probe detection)
+	//"tt": "LP217198-3" (Rapid immunoassay)
+	TT string `json:"tt"`
+
+	//NM The name of the nucleic acid amplification test (NAAT) used. The name
+	//should include the name of the test manufacturer and the commercial name
+	//of the test, separated by a comma. Optional for a NAAT, should not be used for a RAT.
+	NM string `json:"nm,omitempty"`
+
+	//MA Rapid antigen test (RAT) device identifier from the JRC database, a coded value from
+	//the value set test-manf.json. Mandatory for a RAT, should not be used for a NAAT.
+	MA string `json:"ma,omitempty"`
+
+	//SC The date and time when the test sample was collected. The time MUST include
+	//information on the time zone. The value MUST NOT denote the time when the test
+	//result was produced.
+	//"sc": "2021-04-13T14:20:00+00:00"
+	//A value that is not RFC 3339 is kept with its SC.ParseProblem rather than failing the decode
+	SC DateTime `json:"sc"`
+
+	//DR The date and time when the test result was produced, only in schema versions before 1.2.0
+	//which removed it, however is still present in earlier certificates.
+	DR *DateTime `json:"dr,omitempty"`
+
+	//TR The result of the test, a coded value from the value set test-result.json.
+	//"tr": "260415000" (Not detected)
+	//"tr": "260373001" (Detected)
+	TR string `json:"tr"`
+
+	//TC Name of the actor that conducted the test. Max 80 UTF-8 characters.
+	//Optional for a RAT
+	TC string `json:"tc,omitempty"`
+
+	//CO Country expressed as a 2-letter ISO3166 code (RECOMMENDED) or a
+	//reference to an international organisation responsible for carrying out the test
+	//(such as UNHCR or WHO). A coded value from the value set country-2-codes.json.
+	CO string `json:"co"`
+
+	//IS Name of the organisation that issued the certificate. Identifiers are allowed as
+	//part of the name, but not recommended to be used individually without the
+	//name as a text. Max 80 UTF-8 characters.
+	IS string `json:"is"`
+
+	//CI Unique certificate identifier (UVCI) as specified in the vaccinationproof_interoperability-guidelines_en.pdf (europa.eu)
+	//The inclusion of the checksum is optional. The prefix "URN:UVCI:" may be
+	//added.
+	CI string `json:"ci"`
+}
+
+//Recovery Recovery group, if present, MUST contain exactly 1 (one) entry describing exactly one recovery
+//statement. All elements of the recovery group are mandatory, empty values are not supported.
+type Recovery struct {
+
+	//TG Disease or agent targeted, a coded value from the value set disease-agent-targeted.json.
+	TG string `json:"tg"`
+
+	//FR The date when a sample for the NAAT test producing a positive result was
+	//collected, in the format YYYY-MM-DD (complete date without time).
+	FR Date `json:"fr"`
+
+	//CO Country expressed as a 2-letter ISO3166 code (RECOMMENDED) or a
+	//reference to an international organisation responsible for the recovery event
+	//(such as UNHCR or WHO). A coded value from the value set country-2-codes.json.
+	CO string `json:"co"`
+
+	//IS Name of the organisation that issued the certificate. Identifiers are allowed as
+	//part of the name, but not recommended to be used individually without the
+	//name as a text. Max 80 UTF-8 characters.
+	IS string `json:"is"`
+
+	//DF The first date on which the certificate is considered to be valid, in the format YYYY-MM-DD.
+	//The date MUST NOT be earlier than the date calculated as r/fr + 11 days.
+	DF Date `json:"df"`
+
+	//DU The last date on which the certificate is considered to be valid, assigned by the
+	//certificate issuer, in the format YYYY-MM-DD. The date MUST NOT be after the date calculated
+	//as r/fr + 180 days.
+	DU Date `json:"du"`
+
+	//CI Unique certificate identifier (UVCI) as specified in the vaccinationproof_interoperability-guidelines_en.pdf (europa.eu)
+	//The inclusion of the checksum is optional. The prefix "URN:UVCI:" may be
+	//added.
+	CI string `json:"ci"`
+}
+
+//IsValidAt true if t is on or after the df date and on or before the du date, the dates are whole
+//days in UTC so the certificate is valid until the end of the du day
+func (r *Recovery) IsValidAt(t time.Time) bool {
+
+	if r.DF.IsZero() || r.DU.IsZero() {
+		return false
+	}
+
+	t = t.UTC()
+	return !t.Before(r.DF.Time) && t.Before(r.DU.AddDate(0, 0, 1))
+}
+
 //HCERTMap looking a unmarshalled CBOR this is a map with one key "1" that is the DCC
 //see https://ec.europa.eu/health/sites/default/files/ehealth/docs/digital-green-certificates_v3_en.pdf
 type HCERTMap map[uint64]*DCC
@@ -6,10 +6,14 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/webshield-dev/eudvcdecoder/datamodel"
 
 	"github.com/webshield-dev/eudvcdecoder/helper"
+	"github.com/webshield-dev/eudvcdecoder/rules"
+	"github.com/webshield-dev/eudvcdecoder/vaccination"
 )
 
 /*
@@ -20,6 +24,9 @@ Decoder will decode and display the contents of a EU COVID-19 Digital Certificat
 The CLI flags are
 1. -qrc_file <value> file containing the qr code png
 2. -verbose <level> where level is 0 -> 9, default is zero
+3. -country <code> the country of arrival to evaluate the business rules for, such as DE
+4. -rules <dir> directory of business rules in the EU gateway format, required with -country
+5. -validationclock <time> RFC3339 time to evaluate the business rules and vaccination status at, default is now
 
 Example running with no verbose
 - `go run . -qrfile ./testfiles/at_1.png`
@@ -29,30 +36,83 @@ Example running with verbose
 
     `go run . -qrfile ./testfiles/ie_1_qr.png -verbose 1`
 
+Example evaluating the German entry rules
+
+    `go run . -qrfile ./testfiles/dcc-testdata/DE/2DCode/png/1.png -country DE -rules ./testfiles/rules -validationclock 2021-07-01T00:00:00Z`
+
+The exit code is 0 if decoded, 1 for a usage or other error, and if decoding fails the stage that failed
+- 2 image read, 3 QR code detect, 4 not a DCC or unsupported version (prefix), 5 base45, 6 inflate, 7 COSE,
+  8 protected header, 9 payload, 10 HCERT
+
+To generate test certificates see generate.go
+
+    `go run . generate -scenario ./testfiles/generator/scenario.yaml -out ./generated`
+
 */
 
 const (
-	cliVerboseFlag    = "verbose"
-	cliQRFilenameFlag = "qrfile"
+	cliVerboseFlag         = "verbose"
+	cliQRFilenameFlag      = "qrfile"
+	cliCountryFlag         = "country"
+	cliRulesFlag           = "rules"
+	cliValidationClockFlag = "validationclock"
 )
 
 var (
-	cliVerbose    string
-	cliQRFilename string
+	cliVerbose         string
+	cliQRFilename      string
+	cliCountry         string
+	cliRules           string
+	cliValidationClock string
+
+	//validationClock the -validationclock, or now
+	validationClock time.Time
 )
 
+//exitError a usage or other error that is not a decode stage
+const exitError = 1
+
+//stageExitCodes the exit code for each decode stage that can fail
+var stageExitCodes = map[helper.Stage]int{
+	helper.StageImageRead:       2,
+	helper.StageQRDetect:        3,
+	helper.StagePrefix:          4,
+	helper.StageBase45:          5,
+	helper.StageInflate:         6,
+	helper.StageCOSE:            7,
+	helper.StageProtectedHeader: 8,
+	helper.StagePayload:         9,
+	helper.StageHCERT:           10,
+	helper.StageContentLength:   11,
+}
+
+//exitCode the exit code for the decode stage that failed, exitError if not a decode error
+func exitCode(err error) int {
+	if code, ok := stageExitCodes[helper.StageOf(err)]; ok {
+		return code
+	}
+	return exitError
+}
+
 // makeFlagSet return flag set needed to start
 func makeFlagSet() *flag.FlagSet {
 	fs := flag.NewFlagSet("", flag.ExitOnError)
 
 	fs.StringVar(&cliVerbose, cliVerboseFlag, "0", "level of verbose")
 	fs.StringVar(&cliQRFilename, cliQRFilenameFlag, "", "qr code (.png) file name")
+	fs.StringVar(&cliCountry, cliCountryFlag, "", "country of arrival to evaluate the business rules for")
+	fs.StringVar(&cliRules, cliRulesFlag, "", "directory of business rules (EU gateway format)")
+	fs.StringVar(&cliValidationClock, cliValidationClockFlag, "", "RFC3339 time to evaluate the rules and vaccination status at, default now")
 
 	return fs
 }
 
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == generateCommand {
+		os.Exit(runGenerate(os.Args[2:]))
+	}
+
 	fs := makeFlagSet()
 
 	err := fs.Parse(os.Args[1:])
@@ -73,6 +133,15 @@ func main() {
 	maxVerbose := verbose > 1
 	lowVerbose := verbose == 1
 
+	validationClock = time.Now()
+	if cliValidationClock != "" {
+		if validationClock, err = time.Parse(time.RFC3339, cliValidationClock); err != nil {
+			fmt.Printf("error parsing -%s err=%s\n", cliValidationClockFlag, err)
+			fs.PrintDefaults()
+			os.Exit(1)
+		}
+	}
+
 	//set up value set data
 	vsDataPath := os.Getenv("VS_DATA_PATH")
 	if vsDataPath == "" {
@@ -95,7 +164,7 @@ func main() {
 	if err != nil {
 		_ = displayResults(vsMapper, decodeOutput, lowVerbose, maxVerbose)
 		fmt.Printf("ERROR processing certficate err=%s\n", err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 
 	if err := displayResults(vsMapper, decodeOutput, lowVerbose, maxVerbose); err != nil {
@@ -103,6 +172,75 @@ func main() {
 		os.Exit(1)
 	}
 
+	if cliCountry != "" || cliRules != "" {
+		if err := displayRules(vsMapper, decodeOutput); err != nil {
+			fmt.Printf("error evaluating business rules err=%s\n", err)
+			os.Exit(1)
+		}
+	}
+
+}
+
+//displayRules evaluate the -rules for the -country of arrival at the -validationclock and display the results
+func displayRules(vsMapper *helper.ValueSetMapper, output *helper.Output) error {
+
+	if cliCountry == "" || cliRules == "" {
+		return fmt.Errorf("both -%s and -%s are required", cliCountryFlag, cliRulesFlag)
+	}
+	if output.DCC() == nil {
+		return fmt.Errorf("no DCC decoded")
+	}
+
+	ruleSet, err := rules.LoadRuleSet(cliRules)
+	if err != nil {
+		return err
+	}
+
+	external := &rules.External{
+		ValidationClock: validationClock,
+		ValueSets:       vsMapper.ValueSets(),
+		CountryCode:     strings.ToUpper(cliCountry),
+	}
+	issuer := ""
+	if output.CommonPayload != nil {
+		issuer = output.CommonPayload.ISS
+		if output.CommonPayload.EXP != 0 {
+			external.Exp = time.Unix(int64(output.CommonPayload.EXP), 0).UTC()
+		}
+		if output.CommonPayload.IAT != 0 {
+			external.Iat = time.Unix(int64(output.CommonPayload.IAT), 0).UTC()
+		}
+	}
+
+	selected := ruleSet.Select(cliCountry, issuer, output.DCC(), validationClock)
+	results, err := rules.EvaluateRules(selected, output.DCC(), external, "")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n**** Business Rules country=%s validationClock=%s **** \n", external.CountryCode,
+		validationClock.Format(time.RFC3339))
+	if len(results) == 0 {
+		fmt.Printf("  no rules for country=%s in %s\n", external.CountryCode, cliRules)
+		return nil
+	}
+	for _, result := range results {
+		fmt.Printf("  %-7s %s v%s %s\n", strings.ToUpper(string(result.Outcome)), result.Rule.Identifier,
+			result.Rule.Version, result.Description)
+		if result.Err != nil {
+			fmt.Printf("          %s\n", result.Err)
+		}
+	}
+
+	accepted := "Yes"
+	if len(rules.Failed(results)) != 0 {
+		accepted = "No"
+	} else if len(rules.Open(results)) != 0 {
+		accepted = "Open"
+	}
+	fmt.Printf("Accepted: %s\n", accepted)
+
+	return nil
 }
 
 func displayResults(vsMapper *helper.ValueSetMapper, output *helper.Output,
@@ -113,6 +251,9 @@ func displayResults(vsMapper *helper.ValueSetMapper, output *helper.Output,
 
 	if len(output.DecodedQRCode) != 0 {
 		fmt.Printf("  Step 1 - Read QR Code PNG %s Successfully...\n", cliQRFilename)
+		if output.ContextIdentifier != "" {
+			fmt.Printf("    contextIdentifier=%s version=%d\n", output.ContextIdentifier, output.ContextVersion)
+		}
 		if maxVerbose {
 			fmt.Printf("    value=%s\n", string(output.DecodedQRCode))
 		}
@@ -125,7 +266,9 @@ func displayResults(vsMapper *helper.ValueSetMapper, output *helper.Output,
 		}
 	}
 
-	if len(output.Inflated) != 0 {
+	if len(output.Inflated) != 0 && !output.Compressed {
+		fmt.Printf("  Step 3 - Not Compressed, ZLIB Inflate Skipped...\n")
+	} else if len(output.Inflated) != 0 {
 		fmt.Printf("  Step 3 - ZLIB Inflated Successfully...\n")
 		if maxVerbose {
 			fmt.Printf("    hex(value)=%s\n", hex.EncodeToString(output.Inflated))
@@ -135,6 +278,12 @@ func displayResults(vsMapper *helper.ValueSetMapper, output *helper.Output,
 	if output.CBORUnmarshalledI != nil {
 		fmt.Printf("  Step 4 - CBOR UnMarshalled CBOR Web Token (CWT) using COSE tagged message COSE Number=%d Successfully...\n",
 			output.COSeCBORTag)
+		if !output.COSETagged {
+			fmt.Printf("    COSE message was untagged so read as a COSE_Sign1...\n")
+		}
+		if output.CWTTagged {
+			fmt.Printf("    COSE message was wrapped in the CWT tag 61...\n")
+		}
 		if maxVerbose {
 			fmt.Printf("    value=%+v\n", output.CBORUnmarshalledI)
 		}
@@ -154,6 +303,10 @@ func displayResults(vsMapper *helper.ValueSetMapper, output *helper.Output,
 		}
 	}
 
+	if output.Headers != nil && output.Headers.KidBucket() == datamodel.HeaderBucketUnprotected {
+		fmt.Printf("    Warning the kid is only in the UnProtected Header so is not signed...\n")
+	}
+
 	if output.PayloadI != nil {
 		fmt.Printf("    CWT CBOR UnMarshalled the Payload Successfully...\n")
 		if maxVerbose {
@@ -168,6 +321,15 @@ func displayResults(vsMapper *helper.ValueSetMapper, output *helper.Output,
 		}
 	}
 
+	if output.COSeCBORTag == datamodel.COSESignTag && len(output.Signatures) != 0 {
+		fmt.Printf("    CWT Read the COSE Signatures (%d signers) Successfully...\n", len(output.Signatures))
+		if maxVerbose {
+			for _, signature := range output.Signatures {
+				fmt.Printf("      hex(value)=%s\n", hex.EncodeToString(signature.Signature))
+			}
+		}
+	}
+
 	if len(output.DiagnoseLines) != 0 {
 		for _, line := range output.DiagnoseLines {
 			fmt.Printf("%s\n", line)
@@ -238,7 +400,16 @@ func displaySummary(vsMapper *helper.ValueSetMapper, output *helper.Output) {
 	fmt.Printf("Name:%s\n", fullName)
 	fmt.Printf("DOB :%s\n", cert.DOB)
 
-	fmt.Printf("Vaccine Details\n")
+	if len(output.SchemaViolations) != 0 {
+		fmt.Printf("Schema Violations (schema %s)\n", output.SchemaVersion)
+		for _, violation := range output.SchemaViolations {
+			fmt.Printf("  %s\n", violation)
+		}
+	}
+
+	if len(cert.Vaccine) != 0 {
+		fmt.Printf("Vaccine Details\n")
+	}
 	for _, vaccine := range cert.Vaccine {
 
 		//display MP - Medicinal product used for this specific dose of vaccination. A
@@ -262,8 +433,87 @@ func displaySummary(vsMapper *helper.ValueSetMapper, output *helper.Output) {
 		if maVS != nil {
 			fmt.Printf("  Vaccine Maker:      %s\n", maVS.Display)
 		}
+		fmt.Printf("  US Codes:           %s\n", usCodes(vsMapper, &vaccine))
 		fmt.Printf("  Issuer:             %s\n", vaccine.IS)
 		fmt.Printf("  ID:                 %s\n", vaccine.CI)
 
+		evaluation, err := vaccination.Evaluate(&vaccine, validationClock, nil)
+		if err != nil {
+			fmt.Printf("  Status:             unknown %s\n", err)
+			continue
+		}
+		fmt.Printf("  Status:             %s (%s)\n", evaluation.Status, evaluation.Explanation)
+	}
+
+	if len(cert.Test) != 0 {
+		fmt.Printf("Test Details\n")
+	}
+	for _, test := range cert.Test {
+
+		fmt.Printf("  Test Type:          %s\n", valueSetDisplay(vsMapper.DecodeTT(test.TT), test.TT))
+		if test.NM != "" {
+			fmt.Printf("  Test Name:          %s\n", test.NM)
+		}
+		if test.MA != "" {
+			fmt.Printf("  Test Device:        %s\n", test.MA)
+		}
+		fmt.Printf("  Test Result:        %s\n", valueSetDisplay(vsMapper.DecodeTR(test.TR), test.TR))
+		fmt.Printf("  Sample Time:        %s\n", test.SC)
+		if test.SC.ParseProblem != "" {
+			fmt.Printf("  Sample Time Issue:  %s\n", test.SC.ParseProblem)
+		}
+		fmt.Printf("  Test Centre:        %s\n", test.TC)
+		fmt.Printf("  Issuer:             %s\n", test.IS)
+		fmt.Printf("  ID:                 %s\n", test.CI)
+	}
+
+	if len(cert.Recovery) != 0 {
+		fmt.Printf("Recovery Details\n")
+	}
+	for _, recovery := range cert.Recovery {
+
+		validNow := "No"
+		if recovery.IsValidAt(validationClock) {
+			validNow = "Yes"
+		}
+
+		fmt.Printf("  First Positive:     %s\n", recovery.FR)
+		fmt.Printf("  Valid From:         %s\n", recovery.DF)
+		fmt.Printf("  Valid Until:        %s\n", recovery.DU)
+		fmt.Printf("  Valid Today:        %s\n", validNow)
+		fmt.Printf("  Country:            %s\n", recovery.CO)
+		fmt.Printf("  Issuer:             %s\n", recovery.IS)
+		fmt.Printf("  ID:                 %s\n", recovery.CI)
+	}
+}
+
+//valueSetDisplay the display text of a value set value, the code itself if the code is not in the value set
+func valueSetDisplay(value *datamodel.ValueSetValue, code string) string {
+	if value == nil || value.Display == "" {
+		return code
 	}
+	return value.Display
+}
+
+//usCodes the CVX, MVX and ATC codes of the vaccination, a code that cannot be mapped is shown as unknown
+func usCodes(vsMapper *helper.ValueSetMapper, vaccine *datamodel.Vaccine) string {
+
+	codes := make([]string, 0, 3)
+	for _, mapping := range []struct {
+		name    string
+		mapCode func(string) (string, error)
+		code    string
+	}{
+		{name: "CVX", mapCode: vsMapper.MPToCVX, code: vaccine.MP},
+		{name: "MVX", mapCode: vsMapper.MAToMVX, code: vaccine.MA},
+		{name: "ATC", mapCode: vsMapper.MPToATC, code: vaccine.MP},
+	} {
+		mapped, err := mapping.mapCode(mapping.code)
+		if err != nil {
+			mapped = "unknown"
+		}
+		codes = append(codes, mapping.name+"="+mapped)
+	}
+
+	return strings.Join(codes, " ")
 }
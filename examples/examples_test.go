@@ -26,7 +26,7 @@ func Test_Examples_Can_Be_Verified(t *testing.T) {
 
 	ctx := context.TODO()
 
-	verifier, err := euDgcVerifier.NewVerifier(true, true)
+	verifier, err := euDgcVerifier.NewVerifier(true, true, nil)
 	require.NoError(t, err)
 
 	for _, tc := range testCases {
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/webshield-dev/eudvcdecoder/generator"
+)
+
+/*
+
+The generate command issues synthetic test certificates from a scenario file, signed by throwaway keys, and
+writes them in the dcc-testdata layout
+
+    `go run . generate -scenario ./testfiles/generator/scenario.yaml -out ./generated`
+
+*/
+
+const (
+	generateCommand = "generate"
+
+	cliScenarioFlag = "scenario"
+	cliOutFlag      = "out"
+)
+
+//runGenerate run the generate command with its flags, returns the exit code
+func runGenerate(args []string) int {
+
+	var scenarioPath, outDir string
+
+	fs := flag.NewFlagSet(generateCommand, flag.ExitOnError)
+	fs.StringVar(&scenarioPath, cliScenarioFlag, "", "scenario (.yaml or .json) file name")
+	fs.StringVar(&outDir, cliOutFlag, "./generated", "directory to write the test vectors to")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Printf("error parsing command line flags err=%s\n", err)
+		fs.PrintDefaults()
+		return 1
+	}
+	if scenarioPath == "" {
+		fmt.Printf("error -%s is required\n", cliScenarioFlag)
+		fs.PrintDefaults()
+		return 1
+	}
+
+	scenario, err := generator.LoadScenario(scenarioPath)
+	if err != nil {
+		fmt.Printf("error loading scenario err=%s\n", err)
+		return 1
+	}
+
+	fmt.Printf("Generating EU Covid-19 Test Certificates\n")
+	fmt.Printf("  scenario=%s  out=%s\n", scenarioPath, outDir)
+
+	result, err := generator.Generate(scenario, outDir)
+	if err != nil {
+		fmt.Printf("ERROR generating certificates err=%s\n", err)
+		return 1
+	}
+
+	fmt.Printf("  CSCA subject=%s\n", result.CSCA.Subject)
+	for _, dsc := range result.DSCs {
+		fmt.Printf("  DSC subject=%s keyUsage=%v\n", dsc.Subject, dsc.UnknownExtKeyUsage)
+	}
+	for _, path := range result.Vectors {
+		fmt.Printf("  wrote %s\n", path)
+	}
+	fmt.Printf("Generated %d certificates in %s\n", len(result.Vectors), result.Dir)
+
+	return 0
+}
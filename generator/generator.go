@@ -0,0 +1,247 @@
+package generator
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/webshield-dev/eudvcdecoder/conformance"
+	"github.com/webshield-dev/eudvcdecoder/datamodel"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+	"github.com/webshield-dev/eudvcdecoder/schema"
+	"github.com/webshield-dev/eudvcdecoder/trust"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+//
+// Generate synthetic EU DCC test certificates from a scenario, signed by throwaway DSCs issued by a throwaway
+// CSCA. The output has the dcc-testdata layout, see https://github.com/eu-digital-green-certificates/dgc-testdata,
+// so it can be run by the conformance runner
+//
+//  <outDir>/<country>/2DCode/raw/<name>.json  the test vector, JSON, CBOR, COSE, COMPRESSED, BASE45, PREFIX,
+//                                              2DCODE, TESTCTX and EXPECTEDRESULTS
+//  <outDir>/<country>/png/<name>.png          the QR code
+//  <outDir>/<country>/csca.pem                the CSCA, to use as the verifier CSCARoots
+//  <outDir>/<country>/dsc.pem                 the DSCs, to load into a trust.Store
+//
+
+const (
+	rawDir   = "2DCode/raw"
+	pngDir   = "png"
+	cscaFile = "csca.pem"
+	dscFile  = "dsc.pem"
+
+	//vectorVersion the TESTCTX VERSION
+	vectorVersion = 1
+)
+
+//Result what was generated
+type Result struct {
+
+	//Dir the country directory holding the output
+	Dir string
+
+	//CSCA the country signing CA that issued the DSCs
+	CSCA *x509.Certificate
+
+	//DSCs the document signer certificates, one per algorithm and key usage
+	DSCs []*x509.Certificate
+
+	//Vectors the paths of the raw test vectors in scenario order
+	Vectors []string
+}
+
+//Generate issue each certificate in the scenario and write the test vectors, PNGs and certificates under outDir
+func Generate(scenario *Scenario, outDir string) (*Result, error) {
+
+	if scenario == nil {
+		return nil, fmt.Errorf("error generating no scenario")
+	}
+	if err := scenario.validate(); err != nil {
+		return nil, err
+	}
+
+	clock, err := scenario.validationClock()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Dir: filepath.Join(outDir, scenario.country())}
+	for _, dir := range []string{rawDir, pngDir} {
+		if err := os.MkdirAll(filepath.Join(result.Dir, filepath.FromSlash(dir)), 0750); err != nil {
+			return nil, fmt.Errorf("error making output directory err=%s", err)
+		}
+	}
+
+	issuer, err := newPKI(scenario.country(), clock)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, spec := range scenario.Certificates {
+		vector, pngB, err := issue(scenario, spec, issuer, clock)
+		if err != nil {
+			return nil, err
+		}
+
+		vectorB, err := json.MarshalIndent(vector, "", "    ")
+		if err != nil {
+			return nil, fmt.Errorf("error encoding vector name=%s err=%s", spec.Name, err)
+		}
+
+		vectorPath := filepath.Join(result.Dir, filepath.FromSlash(rawDir), spec.Name+".json")
+		if err := os.WriteFile(vectorPath, vectorB, 0600); err != nil {
+			return nil, fmt.Errorf("error writing vector err=%s", err)
+		}
+		if err := os.WriteFile(filepath.Join(result.Dir, pngDir, spec.Name+".png"), pngB, 0600); err != nil {
+			return nil, fmt.Errorf("error writing PNG err=%s", err)
+		}
+		result.Vectors = append(result.Vectors, vectorPath)
+	}
+
+	result.CSCA = issuer.csca.Certificate
+	for _, dsc := range issuer.dscList {
+		result.DSCs = append(result.DSCs, dsc.Certificate)
+	}
+
+	if err := writePEM(filepath.Join(result.Dir, cscaFile), result.CSCA); err != nil {
+		return nil, err
+	}
+	if err := writePEM(filepath.Join(result.Dir, dscFile), result.DSCs...); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+//issue sign the certificate and build its test vector and QR code PNG
+func issue(scenario *Scenario, spec *CertificateSpec, issuer *pki,
+	clock time.Time) (*conformance.Vector, []byte, error) {
+
+	alg, err := scenario.algorithm(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	dsc, err := issuer.dsc(alg, spec.KeyUsage)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jsonB, dcc, err := spec.dccJSON()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	iat, exp, err := spec.times(clock)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload := &datamodel.DGCCommonPayload{
+		ISS:   spec.Issuer,
+		IAT:   uint64(iat.Unix()),
+		EXP:   uint64(exp.Unix()),
+		HCERT: datamodel.HCERTMap{datamodel.HCERTMapKeyOne: dcc},
+	}
+	if payload.ISS == "" {
+		payload.ISS = scenario.country()
+	}
+
+	var signer crypto.Signer = dsc.Key
+	if spec.BadSignature {
+		signer = badSigner{dsc.Key}
+	}
+
+	encodeOutput, err := helper.NewEncoder().ToQRCodeContents(payload, signer, trust.KID(dsc.Certificate))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error encoding certificate name=%s err=%s", spec.Name, err)
+	}
+
+	pngB, err := helper.ToQRCodePNGBytes(encodeOutput.DecodedQRCode, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error rendering certificate name=%s err=%s", spec.Name, err)
+	}
+
+	expectedResults, err := expectedResults(spec, jsonB, dcc, iat, exp, clock)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prefix := string(encodeOutput.DecodedQRCode)
+	vector := &conformance.Vector{
+		JSON:            jsonB,
+		CBOR:            hex.EncodeToString(encodeOutput.CBORUnmarshalledPayload),
+		COSE:            hex.EncodeToString(encodeOutput.Inflated),
+		Compressed:      hex.EncodeToString(encodeOutput.Base45Decoded),
+		Base45:          strings.TrimPrefix(prefix, datamodel.QRCodePrefix+":"),
+		Prefix:          prefix,
+		TwoDCode:        base64.StdEncoding.EncodeToString(pngB),
+		ExpectedResults: expectedResults,
+	}
+	vector.TestCtx.Version = vectorVersion
+	vector.TestCtx.Schema = dcc.Version
+	vector.TestCtx.Certificate = base64.StdEncoding.EncodeToString(dsc.Certificate.Raw)
+	vector.TestCtx.ValidationClock = clock.Format(time.RFC3339)
+	vector.TestCtx.Description = spec.Description
+
+	return vector, pngB, nil
+}
+
+//expectedResults every decode stage passes as the generator only makes valid encodings, the schema, signature,
+//expiration and key usage checks depend on the scenario. The key usage check is only included if the DSC is
+//restricted
+func expectedResults(spec *CertificateSpec, jsonB []byte, dcc *datamodel.DCC, iat time.Time, exp time.Time,
+	clock time.Time) (map[conformance.Check]bool, error) {
+
+	var rawDCC interface{}
+	if err := json.Unmarshal(jsonB, &rawDCC); err != nil {
+		return nil, fmt.Errorf("error certificate name=%s reading dcc err=%s", spec.Name, err)
+	}
+	schemaResult, err := schema.Validate(rawDCC)
+	if err != nil {
+		return nil, fmt.Errorf("error certificate name=%s validating schema err=%s", spec.Name, err)
+	}
+
+	expected := map[conformance.Check]bool{
+		conformance.CheckPictureDecode:    true,
+		conformance.CheckUnprefix:         true,
+		conformance.CheckB45Decode:        true,
+		conformance.CheckCompression:      true,
+		conformance.CheckValidObject:      true,
+		conformance.CheckDecode:           true,
+		conformance.CheckValidJSON:        true,
+		conformance.CheckEncode:           true,
+		conformance.CheckSchemaValidation: schemaResult.Valid(),
+		conformance.CheckVerify:           !spec.BadSignature,
+		conformance.CheckExpirationCheck:  !clock.Before(iat) && !clock.After(exp),
+	}
+
+	if len(spec.KeyUsage) != 0 {
+		expected[conformance.CheckKeyUsage] = !spec.BadSignature &&
+			hasKeyUsage(spec.KeyUsage, verifier.CertificateTypeOf(dcc))
+	}
+
+	return expected, nil
+}
+
+func writePEM(path string, certs ...*x509.Certificate) error {
+
+	var pemB []byte
+	for _, cert := range certs {
+		pemB = append(pemB, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+
+	if err := os.WriteFile(path, pemB, 0600); err != nil {
+		return fmt.Errorf("error writing certificates path=%s err=%s", path, err)
+	}
+
+	return nil
+}
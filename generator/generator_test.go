@@ -0,0 +1,182 @@
+package generator_test
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/conformance"
+	"github.com/webshield-dev/eudvcdecoder/generator"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+	"github.com/webshield-dev/eudvcdecoder/trust"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+func Test_Generate_Conformance(t *testing.T) {
+
+	scenario, err := generator.LoadScenario("../testfiles/generator/scenario.yaml")
+	require.NoError(t, err)
+
+	outDir := t.TempDir()
+	result, err := generator.Generate(scenario, outDir)
+	require.NoError(t, err)
+	require.Len(t, result.Vectors, len(scenario.Certificates))
+	require.Len(t, result.DSCs, 4, "should issue a DSC per algorithm and key usage")
+
+	//the generated vectors should pass the conformance runner with their own expected results
+	report, err := conformance.Run(outDir)
+	require.NoError(t, err)
+	require.Len(t, report.Vectors, len(scenario.Certificates))
+	for _, vr := range report.Vectors {
+		for _, cr := range vr.Checks {
+			require.True(t, cr.Passed(), "%s %s expected=%t %s", vr.Path, cr.Check, cr.Expected, cr.Detail)
+		}
+	}
+
+	type testCase struct {
+		name string
+
+		vector          string
+		expectedFailing []conformance.Check
+	}
+
+	testCases := []testCase{
+		{name: "should expect a valid vaccination to pass", vector: "vaccination-valid"},
+		{name: "should expect a non ASCII name to pass", vector: "vaccination-non-ascii-name"},
+		{
+			name:            "should expect an expired certificate to fail the expiration check",
+			vector:          "vaccination-expired",
+			expectedFailing: []conformance.Check{conformance.CheckExpirationCheck},
+		},
+		{
+			name:            "should expect an iat in the future to fail the expiration check",
+			vector:          "vaccination-future-iat",
+			expectedFailing: []conformance.Check{conformance.CheckExpirationCheck},
+		},
+		{
+			name:            "should expect a test only DSC to fail the key usage check for a vaccination",
+			vector:          "vaccination-wrong-key-usage",
+			expectedFailing: []conformance.Check{conformance.CheckKeyUsage},
+		},
+		{
+			name:            "should expect a bad signature to fail verify",
+			vector:          "vaccination-bad-signature",
+			expectedFailing: []conformance.Check{conformance.CheckVerify},
+		},
+		{
+			name:            "should expect a lower case fnt to fail schema validation",
+			vector:          "vaccination-schema-invalid",
+			expectedFailing: []conformance.Check{conformance.CheckSchemaValidation},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			data, err := helper.ReadData(filepath.Join(result.Dir, "2DCode", "raw", tc.vector+".json"))
+			require.NoError(t, err)
+			var vector conformance.Vector
+			require.NoError(t, json.Unmarshal(data, &vector))
+
+			var failing []conformance.Check
+			for _, check := range conformance.Checks {
+				if expected, ok := vector.ExpectedResults[check]; ok && !expected {
+					failing = append(failing, check)
+				}
+			}
+			require.Equal(t, tc.expectedFailing, failing)
+
+			_, err = os.Stat(filepath.Join(result.Dir, "png", tc.vector+".png"))
+			require.NoError(t, err, "should write the PNG")
+		})
+	}
+}
+
+func Test_Generate_Chains_To_CSCA(t *testing.T) {
+
+	scenario, err := generator.LoadScenario("../testfiles/generator/scenario.yaml")
+	require.NoError(t, err)
+
+	result, err := generator.Generate(scenario, t.TempDir())
+	require.NoError(t, err)
+
+	store, err := trust.LoadStore(filepath.Join(result.Dir, "dsc.pem"))
+	require.NoError(t, err)
+	require.Equal(t, len(result.DSCs), store.Len())
+
+	cscaPEM, err := helper.ReadData(filepath.Join(result.Dir, "csca.pem"))
+	require.NoError(t, err)
+	cscaRoots := x509.NewCertPool()
+	require.True(t, cscaRoots.AppendCertsFromPEM(cscaPEM))
+
+	dgVerifier, err := verifier.NewVerifier(false, false, store)
+	require.NoError(t, err)
+
+	output, err := dgVerifier.FromFileQRCode(context.TODO(), filepath.Join(result.Dir, "png", "vaccination-valid.png"),
+		&verifier.VerifyOptions{CSCARoots: cscaRoots})
+	require.NoError(t, err)
+	require.NotNil(t, output.SigningKey, "should resolve the DSC from the trust store")
+	require.Len(t, output.CertificateChain, 2, "should chain the DSC to the CSCA")
+}
+
+func Test_Generate_Scenario_Errors(t *testing.T) {
+
+	dcc := map[string]interface{}{"ver": "1.3.0"}
+
+	type testCase struct {
+		name string
+
+		scenario *generator.Scenario
+	}
+
+	testCases := []testCase{
+		{name: "should need certificates", scenario: &generator.Scenario{}},
+		{
+			name:     "should need a name",
+			scenario: &generator.Scenario{Certificates: []*generator.CertificateSpec{{DCC: dcc}}},
+		},
+		{
+			name: "should need unique names",
+			scenario: &generator.Scenario{Certificates: []*generator.CertificateSpec{
+				{Name: "a", DCC: dcc}, {Name: "a", DCC: dcc}}},
+		},
+		{
+			name:     "should need a file name",
+			scenario: &generator.Scenario{Certificates: []*generator.CertificateSpec{{Name: "../a", DCC: dcc}}},
+		},
+		{
+			name:     "should need a dcc",
+			scenario: &generator.Scenario{Certificates: []*generator.CertificateSpec{{Name: "a"}}},
+		},
+		{
+			name: "should need a known key usage",
+			scenario: &generator.Scenario{Certificates: []*generator.CertificateSpec{
+				{Name: "a", DCC: dcc, KeyUsage: []verifier.CertificateType{"booster"}}}},
+		},
+		{
+			name: "should need a supported algorithm",
+			scenario: &generator.Scenario{Algorithm: "EdDSA", Certificates: []*generator.CertificateSpec{
+				{Name: "a", DCC: dcc}}},
+		},
+		{
+			name: "should need a valid duration",
+			scenario: &generator.Scenario{Certificates: []*generator.CertificateSpec{
+				{Name: "a", DCC: dcc, IssuedAt: "yesterday"}}},
+		},
+		{
+			name:     "should need an RFC3339 validation clock",
+			scenario: &generator.Scenario{ValidationClock: "2021-06-01", Certificates: []*generator.CertificateSpec{{Name: "a", DCC: dcc}}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := generator.Generate(tc.scenario, t.TempDir())
+			require.Error(t, err)
+		})
+	}
+}
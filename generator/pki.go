@@ -0,0 +1,187 @@
+package generator
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+//
+// A throwaway Country Signing CA (CSCA) and the Document Signer Certificates (DSC) it issues, the private
+// keys only exist in memory while generating, see
+// https://ec.europa.eu/health/sites/default/files/ehealth/docs/digital-green-certificates_v1_en.pdf section 5
+//
+
+const (
+	cscaValidity = 4 * 365 * 24 * time.Hour
+	dscValidity  = 2 * 365 * 24 * time.Hour
+)
+
+//Signer a DSC and its private key
+type Signer struct {
+	Certificate *x509.Certificate
+	Key         crypto.Signer
+}
+
+//pki the CSCA and the DSCs issued so far, one DSC per algorithm and key usage
+type pki struct {
+	country string
+	clock   time.Time
+
+	csca    *Signer
+	dscs    map[string]*Signer
+	dscList []*Signer
+}
+
+//newPKI make a CSCA valid from two years before the clock, so a DSC chains at any iat within a year of it
+func newPKI(country string, clock time.Time) (*pki, error) {
+
+	key, err := newKey(AlgorithmES256)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		Subject: pkix.Name{
+			Country:    []string{country},
+			CommonName: country + " Generated Test CSCA",
+		},
+		NotBefore:             clock.Add(-cscaValidity / 2),
+		NotAfter:              clock.Add(cscaValidity / 2),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	cert, err := createCertificate(template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pki{
+		country: country,
+		clock:   clock,
+		csca:    &Signer{Certificate: cert, Key: key},
+		dscs:    make(map[string]*Signer),
+	}, nil
+}
+
+//dsc the DSC for the algorithm restricted to the key usages, issued the first time it is needed
+func (p *pki) dsc(alg string, keyUsage []verifier.CertificateType) (*Signer, error) {
+
+	usages := make([]string, 0, len(keyUsage))
+	for _, certType := range keyUsage {
+		usages = append(usages, string(certType))
+	}
+	sort.Strings(usages)
+	id := alg + "/" + strings.Join(usages, ",")
+
+	if dsc, ok := p.dscs[id]; ok {
+		return dsc, nil
+	}
+
+	key, err := newKey(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		Subject: pkix.Name{
+			Country:    []string{p.country},
+			CommonName: fmt.Sprintf("%s Generated Test DSC %d", p.country, len(p.dscList)+1),
+		},
+		NotBefore: p.clock.Add(-dscValidity / 2),
+		NotAfter:  p.clock.Add(dscValidity / 2),
+		KeyUsage:  x509.KeyUsageDigitalSignature,
+	}
+	for _, certType := range keyUsage {
+		template.UnknownExtKeyUsage = append(template.UnknownExtKeyUsage, verifier.KeyUsageOID(certType))
+	}
+
+	cert, err := createCertificate(template, p.csca.Certificate, key.Public(), p.csca.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	dsc := &Signer{Certificate: cert, Key: key}
+	p.dscs[id] = dsc
+	p.dscList = append(p.dscList, dsc)
+
+	return dsc, nil
+}
+
+func newKey(alg string) (crypto.Signer, error) {
+
+	var key crypto.Signer
+	var err error
+	if alg == AlgorithmPS256 {
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	} else {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error generating %s key err=%s", alg, err)
+	}
+
+	return key, nil
+}
+
+func createCertificate(template *x509.Certificate, parent *x509.Certificate, publicKey crypto.PublicKey,
+	parentKey crypto.Signer) (*x509.Certificate, error) {
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("error generating serial number err=%s", err)
+	}
+	template.SerialNumber = serial
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, publicKey, parentKey)
+	if err != nil {
+		return nil, fmt.Errorf("error creating certificate subject=%s err=%s", template.Subject, err)
+	}
+
+	return x509.ParseCertificate(der)
+}
+
+//badSigner signs a different digest so the signature is well formed but does not verify
+type badSigner struct {
+	crypto.Signer
+}
+
+func (bs badSigner) Sign(random io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+
+	corrupted := make([]byte, len(digest))
+	for i, b := range digest {
+		corrupted[i] = ^b
+	}
+
+	return bs.Signer.Sign(random, corrupted, opts)
+}
+
+//hasKeyUsage true if the DSC is restricted to the certificate type, or not restricted
+func hasKeyUsage(keyUsage []verifier.CertificateType, certType verifier.CertificateType) bool {
+
+	if len(keyUsage) == 0 {
+		return true
+	}
+	for _, allowed := range keyUsage {
+		if allowed == certType {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,226 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/webshield-dev/eudvcdecoder/datamodel"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+	"gopkg.in/yaml.v3"
+)
+
+//
+// A scenario file lists the certificates to issue, it is YAML or JSON (.json). Each certificate has the DCC
+// as it would appear in the dcc-testdata JSON plus how to issue it, for example
+//
+//  country: XX
+//  validationClock: "2021-06-01T12:00:00Z"
+//  certificates:
+//    - name: expired
+//      description: "INVALID: exp before the validation clock"
+//      issuedAt: -720h
+//      expiresIn: 24h
+//      dcc: {ver: 1.3.0, ...}
+//
+
+const (
+	//AlgorithmES256 sign with an ECDSA P-256 DSC
+	AlgorithmES256 = "ES256"
+
+	//AlgorithmPS256 sign with an RSA DSC
+	AlgorithmPS256 = "PS256"
+
+	//DefaultCountry the country of the CSCA and DSCs when the scenario has none, XX is not a real country so the
+	//certificates cannot be confused with a real issuer
+	DefaultCountry = "XX"
+
+	defaultIssuedAt  = -24 * time.Hour
+	defaultExpiresIn = 365 * 24 * time.Hour
+)
+
+//Scenario the certificates to generate
+type Scenario struct {
+
+	//Country the CSCA and DSC country, and the CWT iss if a certificate has no Issuer, DefaultCountry if empty
+	Country string `json:"country" yaml:"country"`
+
+	//Algorithm the default signing algorithm ES256 or PS256, ES256 if empty
+	Algorithm string `json:"algorithm" yaml:"algorithm"`
+
+	//ValidationClock RFC3339 time the certificates are issued relative to and checked at, now if empty
+	ValidationClock string `json:"validationClock" yaml:"validationClock"`
+
+	Certificates []*CertificateSpec `json:"certificates" yaml:"certificates"`
+}
+
+//CertificateSpec one certificate to issue
+type CertificateSpec struct {
+
+	//Name the vector file name without the extension, must be unique
+	Name string `json:"name" yaml:"name"`
+
+	//Description the TESTCTX DESCRIPTION
+	Description string `json:"description" yaml:"description"`
+
+	//Algorithm overrides the scenario algorithm
+	Algorithm string `json:"algorithm" yaml:"algorithm"`
+
+	//KeyUsage restrict the DSC to signing these certificate types (vaccination, test, recovery), if empty the
+	//DSC has no EU DCC extended key usage so can sign any type. Use a type other than the DCC for a wrong EKU
+	KeyUsage []verifier.CertificateType `json:"keyUsage" yaml:"keyUsage"`
+
+	//Issuer the CWT iss, the scenario country if empty
+	Issuer string `json:"issuer" yaml:"issuer"`
+
+	//IssuedAt the CWT iat relative to the validation clock as a Go duration such as -48h, positive for a
+	//certificate issued in the future, -24h if empty
+	IssuedAt string `json:"issuedAt" yaml:"issuedAt"`
+
+	//ExpiresIn the CWT exp relative to the iat as a Go duration, 8760h if empty
+	ExpiresIn string `json:"expiresIn" yaml:"expiresIn"`
+
+	//BadSignature corrupt the signature so it does not verify
+	BadSignature bool `json:"badSignature" yaml:"badSignature"`
+
+	//DCC the digital covid certificate, as in the dcc-testdata JSON
+	DCC interface{} `json:"dcc" yaml:"dcc"`
+}
+
+//LoadScenario read a YAML or JSON (.json) scenario file
+func LoadScenario(path string) (*Scenario, error) {
+
+	data, err := helper.ReadData(path)
+	if err != nil {
+		return nil, err
+	}
+
+	scenario := &Scenario{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, scenario)
+	} else {
+		err = yaml.Unmarshal(data, scenario)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading scenario path=%s err=%s", path, err)
+	}
+
+	return scenario, nil
+}
+
+//country the scenario country or the default
+func (s *Scenario) country() string {
+	if s.Country == "" {
+		return DefaultCountry
+	}
+	return s.Country
+}
+
+//validationClock the scenario validation clock or now
+func (s *Scenario) validationClock() (time.Time, error) {
+
+	if s.ValidationClock == "" {
+		return time.Now().UTC().Truncate(time.Second), nil
+	}
+
+	clock, err := time.Parse(time.RFC3339, s.ValidationClock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing validationClock=%s err=%s", s.ValidationClock, err)
+	}
+
+	return clock, nil
+}
+
+//algorithm the certificate algorithm, or the scenario algorithm, or ES256
+func (s *Scenario) algorithm(spec *CertificateSpec) (string, error) {
+
+	alg := spec.Algorithm
+	if alg == "" {
+		alg = s.Algorithm
+	}
+	if alg == "" {
+		alg = AlgorithmES256
+	}
+
+	alg = strings.ToUpper(alg)
+	if alg != AlgorithmES256 && alg != AlgorithmPS256 {
+		return "", fmt.Errorf("error certificate name=%s unsupported algorithm=%s", spec.Name, alg)
+	}
+
+	return alg, nil
+}
+
+//validate each certificate has a unique file name, a DCC and known key usages
+func (s *Scenario) validate() error {
+
+	if len(s.Certificates) == 0 {
+		return fmt.Errorf("error scenario has no certificates")
+	}
+
+	names := make(map[string]bool)
+	for i, spec := range s.Certificates {
+		if spec == nil || spec.Name == "" {
+			return fmt.Errorf("error certificate %d has no name", i)
+		}
+		if spec.Name != filepath.Base(spec.Name) || strings.HasPrefix(spec.Name, ".") {
+			return fmt.Errorf("error certificate name=%s must be a file name", spec.Name)
+		}
+		if names[spec.Name] {
+			return fmt.Errorf("error certificate name=%s is not unique", spec.Name)
+		}
+		names[spec.Name] = true
+
+		if spec.DCC == nil {
+			return fmt.Errorf("error certificate name=%s has no dcc", spec.Name)
+		}
+		for _, certType := range spec.KeyUsage {
+			if verifier.KeyUsageOID(certType) == nil {
+				return fmt.Errorf("error certificate name=%s unknown keyUsage=%s", spec.Name, certType)
+			}
+		}
+	}
+
+	return nil
+}
+
+//times the CWT iat and exp
+func (spec *CertificateSpec) times(clock time.Time) (iat time.Time, exp time.Time, err error) {
+
+	issuedAt, err := durationOrDefault(spec.IssuedAt, defaultIssuedAt)
+	if err != nil {
+		return iat, exp, fmt.Errorf("error certificate name=%s issuedAt err=%s", spec.Name, err)
+	}
+	expiresIn, err := durationOrDefault(spec.ExpiresIn, defaultExpiresIn)
+	if err != nil {
+		return iat, exp, fmt.Errorf("error certificate name=%s expiresIn err=%s", spec.Name, err)
+	}
+
+	iat = clock.Add(issuedAt)
+	return iat, iat.Add(expiresIn), nil
+}
+
+//dccJSON the DCC as JSON and as the datamodel DCC
+func (spec *CertificateSpec) dccJSON() (json.RawMessage, *datamodel.DCC, error) {
+
+	jsonB, err := json.Marshal(spec.DCC)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error certificate name=%s encoding dcc err=%s", spec.Name, err)
+	}
+
+	dcc := &datamodel.DCC{}
+	if err := json.Unmarshal(jsonB, dcc); err != nil {
+		return nil, nil, fmt.Errorf("error certificate name=%s reading dcc err=%s", spec.Name, err)
+	}
+
+	return jsonB, dcc, nil
+}
+
+func durationOrDefault(value string, defaultDuration time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultDuration, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -6,8 +6,10 @@ require (
 	github.com/dasio/base45 v1.0.1
 	github.com/fxamacker/cbor/v2 v2.3.0
 	github.com/makiuchi-d/gozxing v0.0.2
+	github.com/santhosh-tekuri/jsonschema/v5 v5.0.0
 	github.com/stretchr/testify v1.7.0
 	github.com/webshield-dev/dhc-common v0.0.0-20211213195516-b1e7b1196c96
+	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776
 )
 
 require (
@@ -19,5 +21,4 @@ require (
 	golang.org/x/text v0.3.5 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 	gopkg.in/check.v1 v1.0.0-20200227125254-8fa46927fb4f // indirect
-	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776 // indirect
 )
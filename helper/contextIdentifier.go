@@ -0,0 +1,85 @@
+package helper
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/webshield-dev/eudvcdecoder/datamodel"
+)
+
+//
+// The QR code contents start with a context identifier, HC1: for version 1 of the health certificate, later
+// versions are HC2:, HC3: and so on. The spec allows the zlib compression to be skipped, so after base45 decoding
+// the bytes are either zlib compressed or the COSE message itself, tagged or an untagged array, see
+// https://ec.europa.eu/health/sites/default/files/ehealth/docs/digital-green-certificates_v1_en.pdf section 2.6
+//
+
+//contextIdentifierType the context identifier without the version number
+const contextIdentifierType = "HC"
+
+//SupportedContextVersions the context identifier versions the decoder can decode
+var SupportedContextVersions = []int{1}
+
+//parseContextIdentifier the identifier such as HC1, its version, and the base45 contents after the colon. An
+//error if there is no context identifier, the version is still returned if it is not supported
+func parseContextIdentifier(contents []byte) (string, int, []byte, error) {
+
+	colon := bytes.IndexByte(contents, ':')
+	if colon < 0 || !bytes.HasPrefix(contents, []byte(contextIdentifierType)) {
+		return "", 0, nil, fmt.Errorf("error not a DCC must start with a context identifier such as %s:",
+			datamodel.QRCodePrefix)
+	}
+
+	identifier := string(contents[:colon])
+	version, err := contextVersion(identifier[len(contextIdentifierType):])
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("error not a DCC context identifier=%q is not %s<version>:", identifier,
+			contextIdentifierType)
+	}
+
+	for _, supported := range SupportedContextVersions {
+		if version == supported {
+			return identifier, version, contents[colon+1:], nil
+		}
+	}
+
+	return identifier, version, nil, fmt.Errorf("error unsupported context identifier=%s version=%d supported=%v",
+		identifier, version, SupportedContextVersions)
+}
+
+//contextVersion the context identifier version, an unsigned decimal from 1 with no sign or leading zero
+func contextVersion(digits string) (int, error) {
+
+	if digits == "" || digits[0] == '0' {
+		return 0, fmt.Errorf("error context version=%q must be a number from 1", digits)
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("error context version=%q must be a number from 1", digits)
+		}
+	}
+
+	return strconv.Atoi(digits)
+}
+
+//isZLIB true if the bytes start with a zlib header, deflate with a valid header checksum, see RFC 1950
+func isZLIB(data []byte) bool {
+
+	if len(data) < 2 {
+		return false
+	}
+
+	cmf, flg := data[0], data[1]
+	return cmf&0x0f == 8 && cmf>>4 <= 7 && (uint16(cmf)<<8|uint16(flg))%31 == 0
+}
+
+//isCBORTag true if the bytes start with a CBOR tag, major type 6, such as the COSE_Sign1 tag 18
+func isCBORTag(data []byte) bool {
+	return len(data) != 0 && data[0]>>5 == 6
+}
+
+//isCOSEMessage true if the bytes start with a CBOR tag or the 4 element array of an untagged COSE message
+func isCOSEMessage(data []byte) bool {
+	return isCBORTag(data) || (len(data) != 0 && data[0] == 0x84)
+}
@@ -0,0 +1,99 @@
+package helper_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dasio/base45"
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+)
+
+func Test_Decode_Uncompressed(t *testing.T) {
+
+	decoder := helper.NewDecoder(false, false)
+
+	compressed, err := decoder.FromFileQRCode("../testfiles/dcc-testdata/DE/2DCode/png/1.png")
+	require.NoError(t, err)
+	require.True(t, compressed.Compressed)
+	require.Equal(t, "HC1", compressed.ContextIdentifier)
+	require.Equal(t, 1, compressed.ContextVersion)
+
+	//the same COSE message without the zlib step
+	uncompressed, err := decoder.FromQRCodeContents([]byte("HC1:" + base45.EncodeToString(compressed.Inflated)))
+	require.NoError(t, err)
+	require.True(t, uncompressed.Decoded)
+	require.False(t, uncompressed.Compressed)
+	require.Equal(t, compressed.Inflated, uncompressed.Inflated)
+	require.Equal(t, uncompressed.Base45Decoded, uncompressed.Inflated, "should not inflate")
+	require.Equal(t, compressed.DCC(), uncompressed.DCC())
+	require.Equal(t, compressed.COSESignature, uncompressed.COSESignature)
+}
+
+func Test_Decode_Context_Identifier(t *testing.T) {
+
+	decoder := helper.NewDecoder(false, false)
+
+	compressed, err := decoder.FromFileQRCode("../testfiles/dcc-testdata/DE/2DCode/png/1.png")
+	require.NoError(t, err)
+	base45Contents := strings.TrimPrefix(string(compressed.DecodedQRCode), "HC1:")
+
+	type testCase struct {
+		name     string
+		contents string
+
+		expectedIdentifier string
+		expectedVersion    int
+	}
+
+	testCases := []testCase{
+		{
+			name:               "should reject a future version",
+			contents:           "HC2:" + base45Contents,
+			expectedIdentifier: "HC2",
+			expectedVersion:    2,
+		},
+		{
+			name:               "should reject a version with two digits",
+			contents:           "HC10:" + base45Contents,
+			expectedIdentifier: "HC10",
+			expectedVersion:    10,
+		},
+		{
+			name:     "should reject no version",
+			contents: "HC:" + base45Contents,
+		},
+		{
+			name:     "should reject version zero",
+			contents: "HC0:" + base45Contents,
+		},
+		{
+			name:     "should reject a version with a sign",
+			contents: "HC+1:" + base45Contents,
+		},
+		{
+			name:     "should reject a version with a leading zero",
+			contents: "HC01:" + base45Contents,
+		},
+		{
+			name:     "should reject another context identifier",
+			contents: "AB1:" + base45Contents,
+		},
+		{
+			name:     "should reject no context identifier",
+			contents: base45Contents,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			output, err := decoder.FromQRCodeContents([]byte(tc.contents))
+			require.Error(t, err)
+			require.Equal(t, helper.StagePrefix, helper.StageOf(err))
+			require.Equal(t, tc.expectedIdentifier, output.ContextIdentifier)
+			require.Equal(t, tc.expectedVersion, output.ContextVersion)
+			require.Empty(t, output.Base45Decoded, "should not decode an unsupported version")
+		})
+	}
+}
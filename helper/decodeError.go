@@ -0,0 +1,96 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+)
+
+//
+// A DecodeError says which decoding stage failed, so a caller can tell "not a DCC" from "corrupt base45" from
+// "bad COSE tag", use errors.As
+//
+//  var decodeErr *helper.DecodeError
+//  if errors.As(err, &decodeErr) && decodeErr.Stage == helper.StagePrefix {
+//      //not a DCC
+//  }
+//
+
+//Stage a decoding stage, in the order they are run
+type Stage string
+
+const (
+	//StageImageRead reading the QR code image file or decoding the PNG or JPG
+	StageImageRead Stage = "image_read"
+
+	//StageQRDetect finding and reading the QR code in the image
+	StageQRDetect Stage = "qr_detect"
+
+	//StageContentLength the QR code contents are longer than the DecoderOptions MaxContentLength
+	StageContentLength Stage = "content_length"
+
+	//StagePrefix the QR code contents do not start with a context identifier such as HC1:, so are not a DCC, or the
+	//context identifier version is not supported
+	StagePrefix Stage = "prefix"
+
+	//StageBase45 base45 decoding the contents after the prefix
+	StageBase45 Stage = "base45"
+
+	//StageInflate zlib inflating the base45 decoded bytes
+	StageInflate Stage = "inflate"
+
+	//StageCOSE reading the COSE_Sign1 or COSE_Sign structure, tagged or untagged
+	StageCOSE Stage = "cose"
+
+	//StageProtectedHeader CBOR decoding the COSE protected header
+	StageProtectedHeader Stage = "protected_header"
+
+	//StagePayload CBOR decoding the CWT payload
+	StagePayload Stage = "payload"
+
+	//StageHCERT reading the common payload claims and the HCERT holding the DCC
+	StageHCERT Stage = "hcert"
+)
+
+//NoOffset the DecodeError Offset when the position of the error in the stage input is not known
+const NoOffset = -1
+
+//DecodeError a decoding stage failed
+type DecodeError struct {
+
+	//Stage the stage that failed
+	Stage Stage
+
+	//Offset the byte offset of the error in the stage input, NoOffset if not known
+	Offset int64
+
+	//Err the cause
+	Err error
+}
+
+//Error see error
+func (e *DecodeError) Error() string {
+	if e.Offset != NoOffset {
+		return fmt.Sprintf("error decoding stage=%s offset=%d err=%s", e.Stage, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("error decoding stage=%s err=%s", e.Stage, e.Err)
+}
+
+//Unwrap the cause
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+//StageOf the stage of the DecodeError in the err chain, empty if there is none
+func StageOf(err error) Stage {
+
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		return decodeErr.Stage
+	}
+
+	return ""
+}
+
+func newDecodeError(stage Stage, err error) *DecodeError {
+	return &DecodeError{Stage: stage, Offset: NoOffset, Err: err}
+}
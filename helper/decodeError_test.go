@@ -0,0 +1,152 @@
+package helper_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/dasio/base45"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+)
+
+func Test_DecodeError_Stages(t *testing.T) {
+
+	//contents builds the HC1 contents of the CBOR, tagged as a COSE message if tag is not zero
+	contents := func(t *testing.T, tag uint64, cborI interface{}) []byte {
+		cborB, err := cbor.Marshal(cborI)
+		require.NoError(t, err)
+		if tag != 0 {
+			cborB, err = cbor.Marshal(cbor.RawTag{Number: tag, Content: cborB})
+			require.NoError(t, err)
+		}
+		return prefixed(t, deflate(t, cborB))
+	}
+
+	emptyMap, err := cbor.Marshal(map[int]interface{}{})
+	require.NoError(t, err)
+	noHCERT, err := cbor.Marshal(map[int]interface{}{1: "DE"})
+	require.NoError(t, err)
+
+	type testCase struct {
+		name           string
+		contents       []byte
+		expectedStage  helper.Stage
+		expectedOffset bool
+	}
+
+	testCases := []testCase{
+		{
+			name:          "should be a prefix error if not HC1",
+			contents:      []byte("HC9:6BFOXN"),
+			expectedStage: helper.StagePrefix,
+		},
+		{
+			name:          "should be a prefix error if too short",
+			contents:      []byte("HC"),
+			expectedStage: helper.StagePrefix,
+		},
+		{
+			name:           "should be a base45 error with the offset",
+			contents:       []byte("HC1:6BFO~N"),
+			expectedStage:  helper.StageBase45,
+			expectedOffset: true,
+		},
+		{
+			name:          "should be an inflate error if not zlib",
+			contents:      prefixed(t, []byte("not compressed")),
+			expectedStage: helper.StageInflate,
+		},
+		{
+			name:          "should be a COSE error if not CBOR",
+			contents:      prefixed(t, deflate(t, []byte{0xff, 0xff})),
+			expectedStage: helper.StageCOSE,
+		},
+		{
+			name:          "should be a COSE error if not a signed COSE message",
+			contents:      contents(t, 96, []interface{}{emptyMap, map[int]interface{}{}, emptyMap, []byte{1}}),
+			expectedStage: helper.StageCOSE,
+		},
+		{
+			name:          "should be a protected header error if not a CBOR map",
+			contents:      contents(t, 18, []interface{}{[]byte{0xff}, map[int]interface{}{}, emptyMap, []byte{1}}),
+			expectedStage: helper.StageProtectedHeader,
+		},
+		{
+			name:          "should be a payload error if not CBOR",
+			contents:      contents(t, 18, []interface{}{emptyMap, map[int]interface{}{}, []byte{0xff}, []byte{1}}),
+			expectedStage: helper.StagePayload,
+		},
+		{
+			name:          "should be an HCERT error if there is no DCC",
+			contents:      contents(t, 18, []interface{}{emptyMap, map[int]interface{}{}, noHCERT, []byte{1}}),
+			expectedStage: helper.StageHCERT,
+		},
+	}
+
+	decoder := helper.NewDecoder(false, false)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			output, err := decoder.FromQRCodeContents(tc.contents)
+			require.Error(t, err)
+			require.NotNil(t, output, "should return what was decoded")
+			require.False(t, output.Decoded)
+
+			var decodeErr *helper.DecodeError
+			require.True(t, errors.As(err, &decodeErr), "should be a DecodeError")
+			require.Equal(t, tc.expectedStage, decodeErr.Stage)
+			require.Equal(t, tc.expectedStage, helper.StageOf(err))
+			require.NotNil(t, errors.Unwrap(err), "should wrap the cause")
+			if tc.expectedOffset {
+				require.NotEqual(t, int64(helper.NoOffset), decodeErr.Offset)
+			} else {
+				require.Equal(t, int64(helper.NoOffset), decodeErr.Offset)
+			}
+		})
+	}
+}
+
+func Test_DecodeError_Image_Stages(t *testing.T) {
+
+	blank := image.NewGray(image.Rect(0, 0, 64, 64))
+	for i := range blank.Pix {
+		blank.Pix[i] = 0xff
+	}
+	var blankPNG bytes.Buffer
+	require.NoError(t, png.Encode(&blankPNG, blank))
+
+	decoder := helper.NewDecoder(false, false)
+
+	_, err := decoder.FromFileQRCode("../testfiles/does-not-exist.png")
+	require.Equal(t, helper.StageImageRead, helper.StageOf(err), "should be an image read error if no file")
+
+	_, err = decoder.FromFileQRCode("../testfiles/rules/DE/VR-DE-0001.json")
+	require.Equal(t, helper.StageImageRead, helper.StageOf(err), "should be an image read error if not an image")
+
+	_, err = decoder.FromQRCodePNGBytes([]byte("not a png"))
+	require.Equal(t, helper.StageImageRead, helper.StageOf(err), "should be an image read error if not a PNG")
+
+	_, err = decoder.FromQRCodePNGBytes(blankPNG.Bytes())
+	require.Equal(t, helper.StageQRDetect, helper.StageOf(err), "should be a QR detect error if no QR code")
+
+	require.Empty(t, helper.StageOf(errors.New("other")), "should have no stage if not a DecodeError")
+}
+
+func deflate(t *testing.T, data []byte) []byte {
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return compressed.Bytes()
+}
+
+func prefixed(t *testing.T, data []byte) []byte {
+	return []byte("HC1:" + base45.EncodeToString(data))
+}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"compress/zlib"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/webshield-dev/eudvcdecoder/datamodel"
+	"github.com/webshield-dev/eudvcdecoder/schema"
 	"image"
 	"image/jpeg"
 	"image/png"
@@ -28,21 +30,38 @@ import (
 /*
 The decoding steps are as follows
 1. Read the QR code .png containing the Digital Certificate to get a base45 encoded certificate
-2. Decode the base45 certificate to get a compressed certificate
-3. ZLIB inflate the compressed certificate to get a CBOR Web Token
+2. Remove the context identifier prefix, HC1:, and decode the base45 certificate to get a compressed certificate
+3. ZLIB inflate the compressed certificate to get a CBOR Web Token, the compression is optional
 4. CBOR decode the CBOR Web Token to get the protected header, unprotected header, payload, and signature
 5. CBOR decode the protected header to get the Signing Algorithm and KeyID
 6. CBOR decode the payload to get the issuer, iat, exp, subject information, and vaccination information
-7. NOT implemented check the COSE signature by getting signing key from issuing State and using it to check the CBOR signature.
+7. Validate the DCC against the JSON schema for its version, violations are recorded in the output
+8. The verifier package checks the COSE signature using the signing key from the issuing State.
 
 */
 
-//NewDecoder make a decoder
+//NewDecoder make a decoder with the DefaultDecoderOptions limits
 func NewDecoder(debug bool, maxDebug bool) Decoder {
-	return &decoderImpl{debug: debug, maxDebug: maxDebug}
+
+	//the default limits are always valid
+	decoder, _ := NewDecoderWithOptions(debug, maxDebug, nil)
+	return decoder
+}
+
+//NewDecoderWithOptions make a decoder with the limits, nil or a zero limit uses the default. An error if a
+//limit is out of range
+func NewDecoderWithOptions(debug bool, maxDebug bool, opts *DecoderOptions) (Decoder, error) {
+
+	opts = opts.withDefaults()
+	decMode, err := opts.DecMode()
+	if err != nil {
+		return nil, err
+	}
+
+	return &decoderImpl{debug: debug, maxDebug: maxDebug, opts: opts, decMode: decMode}, nil
 }
 
-//Decoder methods to decode a EU covid certificate
+//Decoder methods to decode a EU covid certificate, a decoding error is a *DecodeError with the stage that failed
 type Decoder interface {
 
 	//FromFileQRCode assumes file contains a DGC QR code, reads a decodes. If any errors returns what it has
@@ -76,26 +95,66 @@ type Output struct {
 	//DecodedQRCode the result of reading the QR code
 	DecodedQRCode []byte
 
+	//ContextIdentifier the prefix before the colon, such as HC1, empty if there is none
+	ContextIdentifier string
+
+	//ContextVersion the version of the ContextIdentifier, 1 for HC1, set even if it is not supported
+	ContextVersion int
+
 	//Base45Decoded the result of base45 decoding the decoded QR code
 	Base45Decoded []byte
 
-	//Inflated the result of inflating the base45 decoded qr code
+	//Compressed true if the Base45Decoded was zlib compressed, false if the compression was skipped
+	Compressed bool
+
+	//Inflated the result of inflating the base45 decoded qr code, the Base45Decoded if not Compressed
 	Inflated []byte
 
 	//COSeCBORTag the message is encoded as a CBOR Tagged Message, this is the TAG from the message.
-	//currently only handle COSE_Sign1 which is tag 18 see https://datatracker.ietf.org/doc/html/rfc8152#section-2
+	//handle COSE_Sign1 which is tag 18 and COSE_Sign which is tag 98, an untagged message is a COSE_Sign1 so is 18
+	//see https://datatracker.ietf.org/doc/html/rfc8152#section-2
 	COSeCBORTag uint64
 
+	//COSETagged false if the COSE message was an untagged array
+	COSETagged bool
+
+	//CWTTagged true if the COSE message was wrapped in the CWT tag 61
+	CWTTagged bool
+
 	CBORUnmarshalledI       interface{}
 	CBORUnmarshalledPayload []byte //cbor encoded payload
 	PayloadI                interface{}
-	ProtectedHeader         map[int]interface{} // did not make a COSEHeader as wanted to see what else is inside
+	ProtectedHeader         map[int]interface{} // the raw protected header map, see Headers for the typed headers
 	UnProtectedHeader       *datamodel.COSEHeader
 	COSESignature           []byte
 
+	//Headers the protected and unprotected headers of the message, Headers.Merged() is one view of them with the
+	//protected values taking precedence, Headers.KidBucket() says if the kid is only in the unprotected header
+	Headers *datamodel.COSEHeaders
+
+	//SignedCWT the COSE_Sign1 structure as read from the CBOR message, kept so the signature
+	//can be verified against the exact protected header and payload bytes
+	SignedCWT *datamodel.SignedCWT
+
+	//Signatures the signatures of the message, for a COSE_Sign1 the one signature has the message headers,
+	//for a COSE_Sign there is one per signer with the signers headers. The SignedCWT has no Signature for a COSE_Sign
+	Signatures []*datamodel.COSESignature
+
+	//SignatureHeaders the headers of each of the Signatures, decoded within the decoder limits
+	SignatureHeaders []*datamodel.COSEHeaders
+
 	//CommonPayload the common payload within the credential
 	CommonPayload           *datamodel.DGCCommonPayload
 
+	//SchemaValidated true if the DCC was validated against its JSON schema, see ValidateSchema
+	SchemaValidated bool
+
+	//SchemaVersion the major.minor version of the JSON schema the DCC was validated against
+	SchemaVersion string
+
+	//SchemaViolations the fields that do not match the JSON schema, a DCC with violations is still decoded
+	SchemaViolations []*schema.Violation
+
 	//DiagnoseLines the decoding is multi-step if run into issues then diagnostic info is added here
 	DiagnoseLines           []string //if trying to learn display here
 }
@@ -113,6 +172,8 @@ func (o *Output) DCC() *datamodel.DCC {
 type decoderImpl struct {
 	debug    bool
 	maxDebug bool
+	opts     *DecoderOptions
+	decMode  cbor.DecMode
 }
 
 
@@ -122,7 +183,7 @@ func (di *decoderImpl) FromFileQRCode(filename string) (*Output, error) {
 	if strings.HasSuffix(filename, ".png") {
 		pngBytes, err := ioutil.ReadFile(os.ExpandEnv(filename))
 		if err != nil {
-			return nil, fmt.Errorf("error reading QR code file=%s err=%s", filename, err)
+			return nil, newDecodeError(StageImageRead, fmt.Errorf("error reading QR code file=%s err=%s", filename, err))
 		}
 		return di.FromQRCodePNGBytes(pngBytes)
 	}
@@ -130,12 +191,12 @@ func (di *decoderImpl) FromFileQRCode(filename string) (*Output, error) {
 	if strings.HasSuffix(filename, ".jpg") {
 		jpegBytes, err := ioutil.ReadFile(os.ExpandEnv(filename))
 		if err != nil {
-			return nil, fmt.Errorf("error reading QR code file=%s err=%s", filename, err)
+			return nil, newDecodeError(StageImageRead, fmt.Errorf("error reading QR code file=%s err=%s", filename, err))
 		}
 		return di.FromQRCodeJPGBytes(jpegBytes)
 	}
 
-	return nil, fmt.Errorf("error only supports .png and .jpg formats passed=%s", filename)
+	return nil, newDecodeError(StageImageRead, fmt.Errorf("error only supports .png and .jpg formats passed=%s", filename))
 
 }
 
@@ -147,22 +208,21 @@ func (di *decoderImpl) FromQRCodePNGBytes(pngB []byte) (*Output, error) {
 	var img image.Image
 	img, err := png.Decode(reader)
 	if err != nil {
-		return nil, err
+		return nil, newDecodeError(StageImageRead, err)
 	}
 
 	// prepare BinaryBitmap
 	var bmp *gozxing.BinaryBitmap
 	bmp, err = gozxing.NewBinaryBitmapFromImage(img)
 	if err != nil {
-		return nil, err
+		return nil, newDecodeError(StageQRDetect, err)
 	}
 
 	// decode image
 	var result *gozxing.Result
-	qrReader := qrcode.NewQRCodeReader()
-	result, err = qrReader.Decode(bmp, nil)
+	result, err = decodeQRCode(bmp)
 	if err != nil {
-		return nil, err
+		return nil, newDecodeError(StageQRDetect, err)
 	}
 
 
@@ -178,22 +238,21 @@ func (di *decoderImpl) FromQRCodeJPGBytes(pngB []byte) (*Output, error) {
 	var img image.Image
 	img, err := jpeg.Decode(reader)
 	if err != nil {
-		return nil, err
+		return nil, newDecodeError(StageImageRead, err)
 	}
 
 	// prepare BinaryBitmap
 	var bmp *gozxing.BinaryBitmap
 	bmp, err = gozxing.NewBinaryBitmapFromImage(img)
 	if err != nil {
-		return nil, err
+		return nil, newDecodeError(StageQRDetect, err)
 	}
 
 	// decode image
 	var result *gozxing.Result
-	qrReader := qrcode.NewQRCodeReader()
-	result, err = qrReader.Decode(bmp, nil)
+	result, err = decodeQRCode(bmp)
 	if err != nil {
-		return nil, err
+		return nil, newDecodeError(StageQRDetect, err)
 	}
 
 
@@ -204,12 +263,28 @@ func (di *decoderImpl) FromQRCodeJPGBytes(pngB []byte) (*Output, error) {
 
 
 
+//decodeQRCode some QR codes are not found by the detector, if so retry treating the image as only the QR code
+func decodeQRCode(bmp *gozxing.BinaryBitmap) (*gozxing.Result, error) {
+
+	qrReader := qrcode.NewQRCodeReader()
+	result, err := qrReader.Decode(bmp, nil)
+	if err == nil {
+		return result, nil
+	}
+
+	pureHints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_PURE_BARCODE: true}
+	if pureResult, pureErr := qrReader.Decode(bmp, pureHints); pureErr == nil {
+		return pureResult, nil
+	}
+
+	return nil, err
+}
+
 //IsDGCFromQRCodeContents returns true if the card is a digital green card, does no processing
 //looks for HC1 code
 func (di *decoderImpl) IsDGCFromQRCodeContents(qrCodeContents []byte) bool {
 
-	prefix := qrCodeContents[0:3]
-	return string(prefix) == datamodel.QRCodePrefix
+	return bytes.HasPrefix(qrCodeContents, []byte(datamodel.QRCodePrefix))
 }
 
 //FromQRCodeContents see interface
@@ -221,44 +296,61 @@ func (di *decoderImpl) FromQRCodeContents(qrCodeContents []byte) (*Output, error
 
 	output.DecodedQRCode = qrCodeContents
 
+	if len(qrCodeContents) > di.opts.MaxContentLength {
+		return output, newDecodeError(StageContentLength, &LimitError{Limit: LimitContentLength, Max: di.opts.MaxContentLength})
+	}
+
 	//
 	//2. Base64 Decode
 	//
 
-	//remove the HCx: prefix
-	base45B := qrCodeContents[4:]
+	//remove the HCx: context identifier prefix, an unsupported version is recorded and rejected
+	identifier, version, base45B, err := parseContextIdentifier(qrCodeContents)
+	output.ContextIdentifier = identifier
+	output.ContextVersion = version
+	if err != nil {
+		return output, newDecodeError(StagePrefix, err)
+	}
 	base45Decoded, err := base45.DecodeString(string(base45B))
 	if err != nil {
-		return output, err
+		decodeErr := newDecodeError(StageBase45, err)
+		var corrupt base45.CorruptInputError
+		if errors.As(err, &corrupt) {
+			decodeErr.Offset = int64(corrupt)
+		}
+		return output, decodeErr
 	}
 	output.Base45Decoded = base45Decoded
 
 	//
-	//3. Inflate
+	//3. Inflate, unless the compression was skipped and it is already the COSE message
 	//
-
-	reader := bytes.NewReader(base45Decoded)
-	zlibReader, err := zlib.NewReader(reader)
-	if err != nil {
-		return output, err
-	}
-
-	inflated := new(bytes.Buffer)
-	/* #nosec G110 */ //ok as not passed from outside
-	_, err = io.Copy(inflated, zlibReader)
-	if err != nil {
-		return output, err
+	if isZLIB(base45Decoded) || !isCOSEMessage(base45Decoded) {
+		inflated, err := di.inflate(base45Decoded)
+		if err != nil {
+			return output, err
+		}
+		output.Compressed = true
+		output.Inflated = inflated
+	} else {
+		output.Inflated = base45Decoded
 	}
-	output.Inflated = inflated.Bytes()
 
 	//
 	//4. CBOR decode the CBOR Web Token to get the protected header, unprotected header, payload, and signature
 	//
 	//
-	if err := di.cborUnMarshall(inflated.Bytes(), output); err != nil {
+	if err := di.cborUnMarshall(output.Inflated, output); err != nil {
 		return output, err
 	}
 
+	//
+	//5. Validate the DCC against the JSON schema for its version, violations are recorded not rejected
+	//
+	if err := ValidateSchema(output); err != nil {
+		output.DiagnoseLines = append(output.DiagnoseLines, err.Error())
+	}
+
 	//successfully decoded the vaccine credential
 	output.Decoded = true
 
@@ -266,6 +358,38 @@ func (di *decoderImpl) FromQRCodeContents(qrCodeContents []byte) (*Output, error
 
 }
 
+//inflate zlib inflate up to the MaxInflatedSize
+func (di *decoderImpl) inflate(compressed []byte) ([]byte, error) {
+
+	zlibReader, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, newDecodeError(StageInflate, err)
+	}
+
+	//read one more byte than allowed to find out if the limit is exceeded, without inflating a decompression bomb
+	inflated := new(bytes.Buffer)
+	inflatedSize, err := io.Copy(inflated, io.LimitReader(zlibReader, int64(di.opts.MaxInflatedSize)+1))
+	if err != nil {
+		return nil, newDecodeError(StageInflate, err)
+	}
+	if inflatedSize > int64(di.opts.MaxInflatedSize) {
+		return nil, newDecodeError(StageInflate, &LimitError{Limit: LimitInflatedSize, Max: di.opts.MaxInflatedSize})
+	}
+
+	return inflated.Bytes(), nil
+}
+
+//cborDecodeError a DecodeError for the stage, the cause is a LimitError if the CBOR err is because a limit was
+//exceeded, otherwise wrapped
+func (di *decoderImpl) cborDecodeError(stage Stage, err error, wrapped error) *DecodeError {
+
+	if limitErr := di.opts.cborLimitError(err); limitErr != nil {
+		return newDecodeError(stage, limitErr)
+	}
+
+	return newDecodeError(stage, wrapped)
+}
+
 func (di *decoderImpl) cborUnMarshall(inflated []byte, outputToPopulate *Output) error {
 
 	//
@@ -292,23 +416,62 @@ func (di *decoderImpl) cborUnMarshall(inflated []byte, outputToPopulate *Output)
 	//       when present and is a nil value when detached
 	//
 
-	var taggedMessage cbor.Tag
-	if err := cbor.Unmarshal(inflated, &taggedMessage); err != nil {
-		return fmt.Errorf("error unmarshalling inflated CWT into an interface{} err=%s", err)
+	//
+	// Some issuers do not tag the COSE_Sign1, and the COSE message may be wrapped in the CWT tag 61,
+	// see https://datatracker.ietf.org/doc/html/rfc8392#section-6
+	//
+	var messageI interface{}
+	if err := di.decMode.Unmarshal(inflated, &messageI); err != nil {
+		return di.cborDecodeError(StageCOSE, err,
+			fmt.Errorf("error unmarshalling inflated CWT into an interface{} err=%s", err))
 	}
-	outputToPopulate.COSeCBORTag = taggedMessage.Number
-	outputToPopulate.CBORUnmarshalledI = taggedMessage
+	outputToPopulate.CBORUnmarshalledI = messageI
 
-	//must be a COSE_Sign1 otherwise cannot read signature
-	if taggedMessage.Number != 18 {
-		return fmt.Errorf("error CBOR tagged message number must be 18 got=%d", taggedMessage.Number)
+	message, err := di.untagCOSE(inflated, outputToPopulate)
+	if err != nil {
+		return err
 	}
 
 	var sCWT datamodel.SignedCWT
-	if err := cbor.Unmarshal(inflated, &sCWT); err != nil {
-		return fmt.Errorf("error unmarshalling inflated CWT into an CWT struct err=%s", err)
+	switch outputToPopulate.COSeCBORTag {
+
+	case datamodel.COSESign1Tag:
+		if err := di.decMode.Unmarshal(message, &sCWT); err != nil {
+			return di.cborDecodeError(StageCOSE, err,
+				fmt.Errorf("error unmarshalling inflated CWT into an CWT struct err=%s", err))
+		}
+		outputToPopulate.Signatures = []*datamodel.COSESignature{{
+			Protected:   sCWT.Protected,
+			Unprotected: sCWT.Unprotected,
+			Signature:   sCWT.Signature,
+		}}
+
+	case datamodel.COSESignTag:
+		var sign datamodel.COSESign
+		if err := di.decMode.Unmarshal(message, &sign); err != nil {
+			return di.cborDecodeError(StageCOSE, err,
+				fmt.Errorf("error unmarshalling inflated CWT into a COSE_Sign struct err=%s", err))
+		}
+		if len(sign.Signatures) == 0 {
+			return newDecodeError(StageCOSE, fmt.Errorf("error COSE_Sign has no signatures"))
+		}
+		for _, signature := range sign.Signatures {
+			if signature == nil {
+				return newDecodeError(StageCOSE, fmt.Errorf("error COSE_Sign has a null signature"))
+			}
+		}
+		sCWT = datamodel.SignedCWT{Protected: sign.Protected, Unprotected: sign.Unprotected, Payload: sign.Payload}
+		outputToPopulate.Signatures = sign.Signatures
+
+	default:
+		//cannot read a signature from other COSE messages
+		return newDecodeError(StageCOSE, fmt.Errorf(
+			"error CBOR tagged message number must be %d (COSE_Sign1) or %d (COSE_Sign) got=%d",
+			datamodel.COSESign1Tag, datamodel.COSESignTag, outputToPopulate.COSeCBORTag))
 	}
 
+	outputToPopulate.SignedCWT = &sCWT
+
 	// Add the unprotected header was a map that did not need more decoding
 	outputToPopulate.UnProtectedHeader = &sCWT.Unprotected
 
@@ -317,28 +480,32 @@ func (di *decoderImpl) cborUnMarshall(inflated []byte, outputToPopulate *Output)
 	//
 	if len(sCWT.Protected) != 0 {
 		var protectedI map[int]interface{}
-		if err := cbor.Unmarshal(sCWT.Protected, &protectedI); err != nil {
-			return fmt.Errorf("error cbor.Unmarshal protected header hex=%s err=%s",
-				hex.EncodeToString(sCWT.Protected), err)
+		if err := di.decMode.Unmarshal(sCWT.Protected, &protectedI); err != nil {
+			return di.cborDecodeError(StageProtectedHeader, err, fmt.Errorf(
+				"error cbor.Unmarshal protected header hex=%s err=%s", hex.EncodeToString(sCWT.Protected), err))
 		}
 		outputToPopulate.ProtectedHeader = protectedI
+	}
 
-		/*
-			//
-			// DEBUG dump the protected header types
-			//
-			for k, v := range protectedI {
-				fmt.Printf("**** DEBUG PROTECTED HEADER k=%d v=%v t=%T\n", k, v, v)
-			}*/
-
-		//added this as sometimes found issues and this is a way to further check
-		//fixme why not set protected header to this type?
-		var failProtected datamodel.COSEHeader
-		if err := cbor.Unmarshal(sCWT.Protected, &failProtected); err != nil {
-			return fmt.Errorf("error cbor.Unmarshal protected header hex=%s err=%s",
-				hex.EncodeToString(sCWT.Protected), err)
+	//
+	// the typed headers, a COSE_Sign signer has its own headers
+	//
+	headers, err := di.coseHeaders(sCWT.Protected, sCWT.Unprotected)
+	if err != nil {
+		return err
+	}
+	outputToPopulate.Headers = headers
+
+	if outputToPopulate.COSeCBORTag == datamodel.COSESignTag {
+		for _, signature := range outputToPopulate.Signatures {
+			signatureHeaders, err := di.coseHeaders(signature.Protected, signature.Unprotected)
+			if err != nil {
+				return err
+			}
+			outputToPopulate.SignatureHeaders = append(outputToPopulate.SignatureHeaders, signatureHeaders)
 		}
-
+	} else {
+		outputToPopulate.SignatureHeaders = []*datamodel.COSEHeaders{headers}
 	}
 
 	//
@@ -346,28 +513,79 @@ func (di *decoderImpl) cborUnMarshall(inflated []byte, outputToPopulate *Output)
 	//
 	outputToPopulate.CBORUnmarshalledPayload = sCWT.Payload
 	var payloadI interface{}
-	if err := cbor.Unmarshal(sCWT.Payload, &payloadI); err != nil {
-		return err
+	if err := di.decMode.Unmarshal(sCWT.Payload, &payloadI); err != nil {
+		return di.cborDecodeError(StagePayload, err, err)
 	}
 	outputToPopulate.PayloadI = payloadI
 
 	var p datamodel.DGCPayloadCBORMapping
-	if err := cbor.Unmarshal(sCWT.Payload, &p); err != nil {
+	if err := di.decMode.Unmarshal(sCWT.Payload, &p); err != nil {
 		//debug process to understand more
 		outputToPopulate.DiagnoseLines = DebugCBORCommonPayload(sCWT.Payload)
 
-		return fmt.Errorf("error cbor unmarshalling common payload run with verbose to see more err=%s", err)
+		return newDecodeError(StageHCERT,
+			fmt.Errorf("error cbor unmarshalling common payload run with verbose to see more err=%s", err))
 	}
 
 	//create the datamodel version of common payload
 	outputToPopulate.CommonPayload = &datamodel.DGCCommonPayload{}
 	outputToPopulate.CommonPayload.Populate(&p)
+	if outputToPopulate.DCC() == nil {
+		return newDecodeError(StageHCERT, fmt.Errorf("error the CWT has no HCERT claim with a DCC"))
+	}
 
 	//
-	// Add Signature not used for now
+	// Add Signature, verified by the verifier package
 	//
 	outputToPopulate.COSESignature = sCWT.Signature
 
 	return nil
 
 }
+
+//coseHeaders CBOR decode the protected header and x5chain within the limits and check the crit labels are understood
+func (di *decoderImpl) coseHeaders(protectedB []byte, unprotected datamodel.COSEHeader) (*datamodel.COSEHeaders, error) {
+
+	headers, err := datamodel.NewCOSEHeaders(di.decMode, protectedB, unprotected)
+	if err != nil {
+		return nil, di.cborDecodeError(StageProtectedHeader, err, fmt.Errorf(
+			"error decoding COSE headers protected hex=%s err=%s", hex.EncodeToString(protectedB), err))
+	}
+
+	if err := headers.ValidateCrit(); err != nil {
+		return nil, newDecodeError(StageProtectedHeader, err)
+	}
+
+	return headers, nil
+}
+
+//untagCOSE the COSE message array without its tags, sets the COSeCBORTag and if the message was tagged. An
+//untagged array is read as a COSE_Sign1
+func (di *decoderImpl) untagCOSE(inflated []byte, outputToPopulate *Output) ([]byte, error) {
+
+	if !isCBORTag(inflated) {
+		outputToPopulate.COSeCBORTag = datamodel.COSESign1Tag
+		return inflated, nil
+	}
+
+	var tagged cbor.RawTag
+	if err := di.decMode.Unmarshal(inflated, &tagged); err != nil {
+		return nil, di.cborDecodeError(StageCOSE, err, fmt.Errorf("error unmarshalling COSE tag err=%s", err))
+	}
+
+	if tagged.Number == datamodel.CWTTag {
+		outputToPopulate.CWTTagged = true
+		if !isCBORTag(tagged.Content) {
+			return nil, newDecodeError(StageCOSE, fmt.Errorf("error CWT tag %d must wrap a tagged COSE message",
+				datamodel.CWTTag))
+		}
+		if err := di.decMode.Unmarshal(tagged.Content, &tagged); err != nil {
+			return nil, di.cborDecodeError(StageCOSE, err, fmt.Errorf("error unmarshalling COSE tag err=%s", err))
+		}
+	}
+
+	outputToPopulate.COSETagged = true
+	outputToPopulate.COSeCBORTag = tagged.Number
+
+	return tagged.Content, nil
+}
@@ -0,0 +1,166 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+//
+// Limits on what the decoder will process, the QR code contents are supplied by whoever presents the
+// certificate so a small QR code could inflate to gigabytes (a decompression bomb) or hold deeply nested CBOR.
+// A DCC is a few hundred bytes once inflated so the defaults are generous
+//
+
+const (
+	//DefaultMaxContentLength the most characters a version 40 QR code holds in alphanumeric mode
+	DefaultMaxContentLength = 4296
+
+	//DefaultMaxInflatedSize the most bytes the zlib compressed CWT may inflate to
+	DefaultMaxInflatedSize = 64 * 1024
+
+	//DefaultMaxNestedLevels the deepest nesting of CBOR arrays, maps and tags
+	DefaultMaxNestedLevels = 16
+
+	//DefaultMaxArrayElements the most elements in a CBOR array
+	DefaultMaxArrayElements = 256
+
+	//DefaultMaxMapPairs the most key value pairs in a CBOR map
+	DefaultMaxMapPairs = 256
+)
+
+//DecoderOptions the decoding limits, a zero value uses the default for that limit. The CBOR limits must be in the
+//ranges allowed by cbor.DecOptions, nested levels 4 to 256, array elements and map pairs 16 or more
+type DecoderOptions struct {
+
+	//MaxContentLength the most bytes of QR code contents, including the HC1: prefix
+	MaxContentLength int
+
+	//MaxInflatedSize the most bytes the CWT may inflate to
+	MaxInflatedSize int
+
+	//MaxNestedLevels the deepest nesting of CBOR arrays, maps and tags
+	MaxNestedLevels int
+
+	//MaxArrayElements the most elements in a CBOR array
+	MaxArrayElements int
+
+	//MaxMapPairs the most key value pairs in a CBOR map
+	MaxMapPairs int
+}
+
+//DefaultDecoderOptions the default limits
+func DefaultDecoderOptions() *DecoderOptions {
+	return &DecoderOptions{
+		MaxContentLength: DefaultMaxContentLength,
+		MaxInflatedSize:  DefaultMaxInflatedSize,
+		MaxNestedLevels:  DefaultMaxNestedLevels,
+		MaxArrayElements: DefaultMaxArrayElements,
+		MaxMapPairs:      DefaultMaxMapPairs,
+	}
+}
+
+//withDefaults a copy with the defaults for the limits not set
+func (o *DecoderOptions) withDefaults() *DecoderOptions {
+
+	withDefaults := DefaultDecoderOptions()
+	if o == nil {
+		return withDefaults
+	}
+
+	if o.MaxContentLength != 0 {
+		withDefaults.MaxContentLength = o.MaxContentLength
+	}
+	if o.MaxInflatedSize != 0 {
+		withDefaults.MaxInflatedSize = o.MaxInflatedSize
+	}
+	if o.MaxNestedLevels != 0 {
+		withDefaults.MaxNestedLevels = o.MaxNestedLevels
+	}
+	if o.MaxArrayElements != 0 {
+		withDefaults.MaxArrayElements = o.MaxArrayElements
+	}
+	if o.MaxMapPairs != 0 {
+		withDefaults.MaxMapPairs = o.MaxMapPairs
+	}
+
+	return withDefaults
+}
+
+//DecMode the CBOR decoding mode enforcing the limits, nil or a zero limit uses the default. Use it to decode
+//parts of a message outside the decoder, such as the COSE headers
+func (o *DecoderOptions) DecMode() (cbor.DecMode, error) {
+
+	o = o.withDefaults()
+
+	if o.MaxContentLength < 0 || o.MaxInflatedSize < 0 {
+		return nil, fmt.Errorf("error decoder options MaxContentLength=%d and MaxInflatedSize=%d must be positive",
+			o.MaxContentLength, o.MaxInflatedSize)
+	}
+
+	decMode, err := cbor.DecOptions{
+		MaxNestedLevels:  o.MaxNestedLevels,
+		MaxArrayElements: o.MaxArrayElements,
+		MaxMapPairs:      o.MaxMapPairs,
+	}.DecMode()
+	if err != nil {
+		return nil, fmt.Errorf("error decoder options err=%s", err)
+	}
+
+	return decMode, nil
+}
+
+//Limit a DecoderOptions limit
+type Limit string
+
+const (
+	//LimitContentLength the MaxContentLength
+	LimitContentLength Limit = "content_length"
+
+	//LimitInflatedSize the MaxInflatedSize
+	LimitInflatedSize Limit = "inflated_size"
+
+	//LimitNestedLevels the MaxNestedLevels
+	LimitNestedLevels Limit = "nested_levels"
+
+	//LimitArrayElements the MaxArrayElements
+	LimitArrayElements Limit = "array_elements"
+
+	//LimitMapPairs the MaxMapPairs
+	LimitMapPairs Limit = "map_pairs"
+)
+
+//LimitError a DecoderOptions limit was exceeded, it is the Err of a DecodeError so use errors.As
+type LimitError struct {
+
+	//Limit the limit exceeded
+	Limit Limit
+
+	//Max the value of the limit
+	Max int
+}
+
+//Error see error
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("error limit=%s exceeded max=%d", e.Limit, e.Max)
+}
+
+//cborLimitError the LimitError if the CBOR decoding error is because a limit was exceeded, otherwise nil
+func (o *DecoderOptions) cborLimitError(err error) *LimitError {
+
+	var nestedErr *cbor.MaxNestedLevelError
+	var arrayErr *cbor.MaxArrayElementsError
+	var mapErr *cbor.MaxMapPairsError
+
+	switch {
+	case errors.As(err, &nestedErr):
+		return &LimitError{Limit: LimitNestedLevels, Max: o.MaxNestedLevels}
+	case errors.As(err, &arrayErr):
+		return &LimitError{Limit: LimitArrayElements, Max: o.MaxArrayElements}
+	case errors.As(err, &mapErr):
+		return &LimitError{Limit: LimitMapPairs, Max: o.MaxMapPairs}
+	default:
+		return nil
+	}
+}
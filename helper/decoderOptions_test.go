@@ -0,0 +1,165 @@
+package helper_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+)
+
+func Test_Decoder_Limits(t *testing.T) {
+
+	//sign1 the contents of a COSE_Sign1 with the protected header and payload
+	sign1 := func(t *testing.T, protectedHeader map[int]interface{}, payload interface{}) []byte {
+		protected, err := cbor.Marshal(protectedHeader)
+		require.NoError(t, err)
+		payloadB, err := cbor.Marshal(payload)
+		require.NoError(t, err)
+		cwtB, err := cbor.Marshal(cbor.Tag{Number: 18, Content: []interface{}{protected, map[int]interface{}{},
+			payloadB, []byte{1}}})
+		require.NoError(t, err)
+		return prefixed(t, deflate(t, cwtB))
+	}
+
+	var nested interface{} = "dcc"
+	for i := 0; i < 20; i++ {
+		nested = []interface{}{nested}
+	}
+	longArray := make([]interface{}, 300)
+	for i := range longArray {
+		longArray[i] = i
+	}
+	longChain := make([][]byte, 300)
+	for i := range longChain {
+		longChain[i] = []byte{0x30, 0x01}
+	}
+	bigMap := make(map[int]interface{}, 300)
+	for i := 0; i < 300; i++ {
+		bigMap[i] = i
+	}
+
+	type testCase struct {
+		name     string
+		opts     *helper.DecoderOptions
+		contents []byte
+
+		expectedStage helper.Stage
+		expectedLimit helper.Limit
+		expectedMax   int
+	}
+
+	testCases := []testCase{
+		{
+			name:          "should limit the content length",
+			contents:      []byte("HC1:" + strings.Repeat("0", helper.DefaultMaxContentLength)),
+			expectedStage: helper.StageContentLength,
+			expectedLimit: helper.LimitContentLength,
+			expectedMax:   helper.DefaultMaxContentLength,
+		},
+		{
+			name:          "should limit the inflated size of a decompression bomb",
+			contents:      prefixed(t, deflate(t, bytes.Repeat([]byte{0}, 1024*1024))),
+			expectedStage: helper.StageInflate,
+			expectedLimit: helper.LimitInflatedSize,
+			expectedMax:   helper.DefaultMaxInflatedSize,
+		},
+		{
+			name:          "should use the inflated size option",
+			opts:          &helper.DecoderOptions{MaxInflatedSize: 64},
+			contents:      sign1(t, map[int]interface{}{1: -7}, map[int]interface{}{1: strings.Repeat("D", 100)}),
+			expectedStage: helper.StageInflate,
+			expectedLimit: helper.LimitInflatedSize,
+			expectedMax:   64,
+		},
+		{
+			name:          "should limit the nesting",
+			contents:      sign1(t, map[int]interface{}{1: -7}, map[int]interface{}{-260: map[int]interface{}{1: nested}}),
+			expectedStage: helper.StagePayload,
+			expectedLimit: helper.LimitNestedLevels,
+			expectedMax:   helper.DefaultMaxNestedLevels,
+		},
+		{
+			name:          "should limit the array elements",
+			contents:      sign1(t, map[int]interface{}{1: -7}, map[int]interface{}{-260: map[int]interface{}{1: longArray}}),
+			expectedStage: helper.StagePayload,
+			expectedLimit: helper.LimitArrayElements,
+			expectedMax:   helper.DefaultMaxArrayElements,
+		},
+		{
+			name:          "should limit the map pairs",
+			contents:      sign1(t, map[int]interface{}{1: -7}, bigMap),
+			expectedStage: helper.StagePayload,
+			expectedLimit: helper.LimitMapPairs,
+			expectedMax:   helper.DefaultMaxMapPairs,
+		},
+		{
+			name:          "should limit the x5chain in the protected header",
+			opts:          &helper.DecoderOptions{MaxArrayElements: 64},
+			contents:      sign1(t, map[int]interface{}{1: -7, 33: longChain}, map[int]interface{}{1: "DE"}),
+			expectedStage: helper.StageProtectedHeader,
+			expectedLimit: helper.LimitArrayElements,
+			expectedMax:   64,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			decoder, err := helper.NewDecoderWithOptions(false, false, tc.opts)
+			require.NoError(t, err)
+
+			_, err = decoder.FromQRCodeContents(tc.contents)
+			require.Error(t, err)
+			require.Equal(t, tc.expectedStage, helper.StageOf(err))
+
+			var limitErr *helper.LimitError
+			require.True(t, errors.As(err, &limitErr), "should be a LimitError err=%s", err)
+			require.Equal(t, tc.expectedLimit, limitErr.Limit)
+			require.Equal(t, tc.expectedMax, limitErr.Max)
+		})
+	}
+}
+
+func Test_Decoder_Limits_Allow_DCC(t *testing.T) {
+
+	//the smallest limits cbor allows still decode a DCC
+	decoder, err := helper.NewDecoderWithOptions(false, false, &helper.DecoderOptions{
+		MaxContentLength: 1024,
+		MaxInflatedSize:  1024,
+		MaxNestedLevels:  8,
+		MaxArrayElements: 16,
+		MaxMapPairs:      16,
+	})
+	require.NoError(t, err)
+
+	output, err := decoder.FromFileQRCode("../testfiles/dcc-testdata/DE/2DCode/png/1.png")
+	require.NoError(t, err)
+	require.True(t, output.Decoded)
+}
+
+func Test_Decoder_Options_Errors(t *testing.T) {
+
+	type testCase struct {
+		name string
+		opts *helper.DecoderOptions
+	}
+
+	testCases := []testCase{
+		{name: "should error if nesting below the cbor minimum", opts: &helper.DecoderOptions{MaxNestedLevels: 2}},
+		{name: "should error if array elements below the cbor minimum", opts: &helper.DecoderOptions{MaxArrayElements: 4}},
+		{name: "should error if map pairs below the cbor minimum", opts: &helper.DecoderOptions{MaxMapPairs: 4}},
+		{name: "should error if a negative content length", opts: &helper.DecoderOptions{MaxContentLength: -1}},
+		{name: "should error if a negative inflated size", opts: &helper.DecoderOptions{MaxInflatedSize: -1}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := helper.NewDecoderWithOptions(false, false, tc.opts)
+			require.Error(t, err)
+		})
+	}
+}
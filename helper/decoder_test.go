@@ -1,11 +1,16 @@
 package helper_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"encoding/json"
+	"github.com/fxamacker/cbor/v2"
 	"github.com/stretchr/testify/require"
 	"github.com/webshield-dev/eudvcdecoder/datamodel"
 	"github.com/webshield-dev/eudvcdecoder/helper"
 	"testing"
+	"time"
 )
 
 type dccTestData struct {
@@ -79,6 +84,9 @@ func Test_Decode(t *testing.T) {
 				dcc := decodeOutput.DCC()
 				require.Equal(t, *testData.JSON, *dcc)
 
+				require.True(t, decodeOutput.SchemaValidated, "should have validated the schema")
+				require.Empty(t, decodeOutput.SchemaViolations, "should be valid against the schema")
+
 				if testData.Prefix != "" {
                     require.Equal(t, testData.Prefix, string(decodeOutput.DecodedQRCode), "base45 decoded should match")
 
@@ -88,3 +96,436 @@ func Test_Decode(t *testing.T) {
 		})
 	}
 }
+
+func Test_Decode_Test_Group(t *testing.T) {
+
+	type testCase struct {
+		name string
+
+		qrCodePath string
+
+		expectedTT string
+		expectedTR string
+		expectedSC time.Time
+	}
+
+	testCases := []testCase{
+		{
+			name:       "should decode a german rapid antigen test",
+			qrCodePath: "../testfiles/dcc-testdata/DE/2DCode/png/2.png",
+			expectedTT: "LP217198-3",
+			expectedTR: "260415000",
+			expectedSC: time.Date(2021, 5, 30, 10, 12, 22, 0, time.UTC),
+		},
+		{
+			name:       "should decode an ireland test with a sample time zone",
+			qrCodePath: "../testfiles/dcc-testdata/IE/png/2_qr.png",
+			expectedTT: "COVID-19 test",
+			expectedTR: "260415000",
+			expectedSC: time.Date(2021, 6, 4, 22, 0, 0, 0, time.UTC),
+		},
+	}
+
+	vcDecoder := helper.NewDecoder(false, false)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			decodeOutput, err := vcDecoder.FromFileQRCode(tc.qrCodePath)
+			require.NoError(t, err)
+
+			dcc := decodeOutput.DCC()
+			require.NotNil(t, dcc)
+			require.Len(t, dcc.Test, 1)
+			require.Empty(t, dcc.Vaccine)
+
+			test := dcc.Test[0]
+			require.Equal(t, tc.expectedTT, test.TT)
+			require.Equal(t, tc.expectedTR, test.TR)
+			require.True(t, tc.expectedSC.Equal(test.SC.Time), "sample time should match got=%s", test.SC)
+			require.Empty(t, test.SC.ParseProblem)
+			require.NotNil(t, test.DR, "should decode the schema 1.0 result time")
+		})
+	}
+}
+
+func Test_Decode_Test_Sample_Time(t *testing.T) {
+
+	type testCase struct {
+		name string
+
+		sc              string
+		expectedSC      time.Time
+		expectedProblem bool
+	}
+
+	testCases := []testCase{
+		{
+			name:       "should decode an rfc 3339 sample time",
+			sc:         "2021-05-30T10:12:22+02:00",
+			expectedSC: time.Date(2021, 5, 30, 8, 12, 22, 0, time.UTC),
+		},
+		{
+			name:       "should decode a sample time without a time zone as utc",
+			sc:         "2021-05-30T10:12:22",
+			expectedSC: time.Date(2021, 5, 30, 10, 12, 22, 0, time.UTC),
+		},
+		{
+			name:       "should decode a date only sample time",
+			sc:         "2021-05-30",
+			expectedSC: time.Date(2021, 5, 30, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:            "should keep a sample time that cannot be parsed as a parse problem",
+			sc:              "30/05/2021 10:12",
+			expectedProblem: true,
+		},
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	vcDecoder := helper.NewDecoder(false, false)
+	vcEncoder := helper.NewEncoder()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			source, err := vcDecoder.FromFileQRCode("../testfiles/dcc-testdata/DE/2DCode/png/2.png")
+			require.NoError(t, err)
+			source.DCC().Test[0].SC = datamodel.DateTime{Raw: tc.sc}
+
+			encodeOutput, err := vcEncoder.ToQRCodeContents(source.CommonPayload, key, nil)
+			require.NoError(t, err)
+
+			decodeOutput, err := vcDecoder.FromQRCodeContents(encodeOutput.DecodedQRCode)
+			require.NoError(t, err, "should not reject the certificate")
+
+			sc := decodeOutput.DCC().Test[0].SC
+			require.Equal(t, tc.sc, sc.Raw)
+			if tc.expectedProblem {
+				require.NotEmpty(t, sc.ParseProblem)
+				require.True(t, sc.IsZero())
+				require.Equal(t, tc.sc, sc.String(), "should display the sample time as encoded")
+				return
+			}
+			require.Empty(t, sc.ParseProblem)
+			require.True(t, tc.expectedSC.Equal(sc.Time), "sample time should match got=%s", sc)
+		})
+	}
+}
+
+func Test_Decode_Recovery_Group(t *testing.T) {
+
+	vcDecoder := helper.NewDecoder(false, false)
+	decodeOutput, err := vcDecoder.FromFileQRCode("../testfiles/dcc-testdata/NL/png/094-NL-recovery.png")
+	require.NoError(t, err)
+
+	dcc := decodeOutput.DCC()
+	require.NotNil(t, dcc)
+	require.Len(t, dcc.Recovery, 1)
+	require.Empty(t, dcc.Vaccine)
+	require.Empty(t, dcc.Test)
+
+	recovery := dcc.Recovery[0]
+	require.Equal(t, "2021-03-25", recovery.FR.String())
+	require.Equal(t, "2021-04-12", recovery.DF.String())
+	require.Equal(t, "2021-06-01", recovery.DU.String())
+
+	type testCase struct {
+		name string
+
+		at            time.Time
+		expectedValid bool
+	}
+
+	testCases := []testCase{
+		{name: "should not be valid before df", at: time.Date(2021, 4, 11, 23, 59, 59, 0, time.UTC)},
+		{name: "should be valid on df", at: time.Date(2021, 4, 12, 0, 0, 0, 0, time.UTC), expectedValid: true},
+		{name: "should be valid until the end of du", at: time.Date(2021, 6, 1, 23, 59, 59, 0, time.UTC),
+			expectedValid: true},
+		{name: "should not be valid after du", at: time.Date(2021, 6, 2, 0, 0, 0, 0, time.UTC)},
+		{name: "should compare in UTC", at: time.Date(2021, 6, 2, 1, 0, 0, 0, time.FixedZone("CEST", 2*60*60)),
+			expectedValid: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expectedValid, recovery.IsValidAt(tc.at))
+		})
+	}
+}
+
+func Test_Decode_COSE_Messages(t *testing.T) {
+
+	decoder := helper.NewDecoder(false, false)
+
+	tagged, err := decoder.FromFileQRCode("../testfiles/dcc-testdata/DE/2DCode/png/1.png")
+	require.NoError(t, err)
+	require.True(t, tagged.COSETagged)
+	require.Len(t, tagged.Signatures, 1)
+
+	var cose cbor.RawTag
+	require.NoError(t, cbor.Unmarshal(tagged.Inflated, &cose))
+	sCWT := tagged.SignedCWT
+
+	cwtB, err := cbor.Marshal(cbor.RawTag{Number: datamodel.CWTTag, Content: tagged.Inflated})
+	require.NoError(t, err)
+
+	sign := &datamodel.COSESign{
+		Protected:   sCWT.Protected,
+		Unprotected: sCWT.Unprotected,
+		Payload:     sCWT.Payload,
+		Signatures: []*datamodel.COSESignature{
+			{Unprotected: datamodel.COSEHeader{Kid: []byte{1}}, Signature: []byte{1, 2}},
+			{Unprotected: datamodel.COSEHeader{Kid: []byte{3}}, Signature: []byte{3, 4}},
+		},
+	}
+	signB, err := cbor.Marshal(cbor.Tag{Number: datamodel.COSESignTag, Content: sign})
+	require.NoError(t, err)
+
+	critProtected, err := cbor.Marshal(map[int]interface{}{1: -7, 2: []interface{}{99}})
+	require.NoError(t, err)
+	critSignerB, err := cbor.Marshal(cbor.Tag{Number: datamodel.COSESignTag, Content: &datamodel.COSESign{
+		Protected:  sCWT.Protected,
+		Payload:    sCWT.Payload,
+		Signatures: []*datamodel.COSESignature{{Protected: critProtected, Signature: []byte{1, 2}}},
+	}})
+	require.NoError(t, err)
+
+	noSignersB, err := cbor.Marshal(cbor.Tag{Number: datamodel.COSESignTag,
+		Content: &datamodel.COSESign{Protected: sCWT.Protected, Payload: sCWT.Payload}})
+	require.NoError(t, err)
+
+	type testCase struct {
+		name     string
+		cose     []byte
+		compress bool
+
+		expectedError      bool
+		expectedStage      helper.Stage
+		expectedTag        uint64
+		expectedCOSETagged bool
+		expectedCWTTagged  bool
+		expectedSignatures int
+	}
+
+	testCases := []testCase{
+		{
+			name:               "should decode an untagged COSE_Sign1",
+			cose:               cose.Content,
+			compress:           true,
+			expectedTag:        datamodel.COSESign1Tag,
+			expectedSignatures: 1,
+		},
+		{
+			name:               "should decode an uncompressed untagged COSE_Sign1",
+			cose:               cose.Content,
+			expectedTag:        datamodel.COSESign1Tag,
+			expectedSignatures: 1,
+		},
+		{
+			name:               "should decode a COSE_Sign1 wrapped in the CWT tag",
+			cose:               cwtB,
+			compress:           true,
+			expectedTag:        datamodel.COSESign1Tag,
+			expectedCOSETagged: true,
+			expectedCWTTagged:  true,
+			expectedSignatures: 1,
+		},
+		{
+			name:               "should decode a COSE_Sign with two signers",
+			cose:               signB,
+			compress:           true,
+			expectedTag:        datamodel.COSESignTag,
+			expectedCOSETagged: true,
+			expectedSignatures: 2,
+		},
+		{
+			name:          "should error if a COSE_Sign has no signers",
+			cose:          noSignersB,
+			compress:      true,
+			expectedError: true,
+			expectedStage: helper.StageCOSE,
+		},
+		{
+			name:          "should error if a COSE_Sign signer has a crit label that is not understood",
+			cose:          critSignerB,
+			compress:      true,
+			expectedError: true,
+			expectedStage: helper.StageProtectedHeader,
+		},
+		{
+			name:          "should error if the CWT tag does not wrap a tagged COSE message",
+			cose:          append([]byte{0xd8, 0x3d}, cose.Content...),
+			compress:      true,
+			expectedError: true,
+			expectedStage: helper.StageCOSE,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			contents := tc.cose
+			if tc.compress {
+				contents = deflate(t, contents)
+			}
+
+			output, err := decoder.FromQRCodeContents(prefixed(t, contents))
+			if tc.expectedError {
+				require.Error(t, err)
+				require.Equal(t, tc.expectedStage, helper.StageOf(err))
+				return
+			}
+			require.NoError(t, err)
+
+			require.True(t, output.Decoded)
+			require.Equal(t, tc.compress, output.Compressed)
+			require.Equal(t, tc.expectedTag, output.COSeCBORTag)
+			require.Equal(t, tc.expectedCOSETagged, output.COSETagged)
+			require.Equal(t, tc.expectedCWTTagged, output.CWTTagged)
+			require.Len(t, output.Signatures, tc.expectedSignatures)
+			require.Equal(t, tagged.DCC(), output.DCC())
+			require.Equal(t, sCWT.Payload, output.SignedCWT.Payload)
+		})
+	}
+}
+
+func Test_Decode_COSE_Headers(t *testing.T) {
+
+	decoder := helper.NewDecoder(false, false)
+
+	de, err := decoder.FromFileQRCode("../testfiles/dcc-testdata/DE/2DCode/png/1.png")
+	require.NoError(t, err)
+	payload := de.SignedCWT.Payload
+
+	//sign1 a COSE_Sign1 with the headers, the signature is not checked
+	sign1 := func(t *testing.T, protected map[int]interface{}, unprotected map[int]interface{}) []byte {
+		protectedB, err := cbor.Marshal(protected)
+		require.NoError(t, err)
+		coseB, err := cbor.Marshal(cbor.Tag{Number: datamodel.COSESign1Tag,
+			Content: []interface{}{protectedB, unprotected, payload, []byte{1}}})
+		require.NoError(t, err)
+		return prefixed(t, deflate(t, coseB))
+	}
+
+	certificate := []byte{0x30, 0x01}
+	certificateB, err := cbor.Marshal(certificate)
+	require.NoError(t, err)
+	chainB, err := cbor.Marshal([][]byte{certificate, certificate})
+	require.NoError(t, err)
+	x5t := &datamodel.X5T{Alg: datamodel.COSEAlgorithm{Int: -16}, Hash: []byte{1, 2, 3}}
+
+	type testCase struct {
+		name     string
+		contents []byte
+
+		expectedError     bool
+		expectedKidBucket datamodel.HeaderBucket
+		expectedMerged    *datamodel.COSEHeader
+		expectedX5Chain   [][]byte
+	}
+
+	testCases := []testCase{
+		{
+			name:              "should read the kid from the protected header",
+			contents:          sign1(t, map[int]interface{}{1: -7, 4: []byte{1}}, map[int]interface{}{}),
+			expectedKidBucket: datamodel.HeaderBucketProtected,
+			expectedMerged:    &datamodel.COSEHeader{Alg: datamodel.IntAlgorithm(-7), Kid: []byte{1}},
+		},
+		{
+			name:              "should report a kid only in the unprotected header",
+			contents:          sign1(t, map[int]interface{}{1: -7}, map[int]interface{}{4: []byte{2}}),
+			expectedKidBucket: datamodel.HeaderBucketUnprotected,
+			expectedMerged:    &datamodel.COSEHeader{Alg: datamodel.IntAlgorithm(-7), Kid: []byte{2}},
+		},
+		{
+			name:              "should use the protected value if in both headers",
+			contents:          sign1(t, map[int]interface{}{1: -7, 4: []byte{1}}, map[int]interface{}{1: -37, 4: []byte{2}}),
+			expectedKidBucket: datamodel.HeaderBucketProtected,
+			expectedMerged:    &datamodel.COSEHeader{Alg: datamodel.IntAlgorithm(-7), Kid: []byte{1}},
+		},
+		{
+			name:              "should have no kid bucket if there is no kid",
+			contents:          sign1(t, map[int]interface{}{1: -7}, map[int]interface{}{}),
+			expectedKidBucket: datamodel.HeaderBucketNone,
+			expectedMerged:    &datamodel.COSEHeader{Alg: datamodel.IntAlgorithm(-7)},
+		},
+		{
+			name: "should read all the header parameters",
+			contents: sign1(t, map[int]interface{}{1: -7, 2: []interface{}{4}, 3: "application/cwt", 4: []byte{1},
+				33: [][]byte{certificate, certificate}, 34: x5t},
+				map[int]interface{}{5: []byte{5}, 6: []byte{6}}),
+			expectedKidBucket: datamodel.HeaderBucketProtected,
+			expectedMerged: &datamodel.COSEHeader{Alg: datamodel.IntAlgorithm(-7), Crit: []interface{}{uint64(4)}, ContentType: "application/cwt",
+				Kid: []byte{1}, IV: []byte{5}, PartialIV: []byte{6}, X5Chain: chainB, X5T: x5t},
+			expectedX5Chain: [][]byte{certificate, certificate},
+		},
+		{
+			name:              "should read an x5chain of one certificate",
+			contents:          sign1(t, map[int]interface{}{1: -7, 33: certificate}, map[int]interface{}{}),
+			expectedKidBucket: datamodel.HeaderBucketNone,
+			expectedMerged:    &datamodel.COSEHeader{Alg: datamodel.IntAlgorithm(-7), X5Chain: certificateB},
+			expectedX5Chain:   [][]byte{certificate},
+		},
+		{
+			name:              "should read a tstr alg",
+			contents:          sign1(t, map[int]interface{}{1: "ES256"}, map[int]interface{}{}),
+			expectedKidBucket: datamodel.HeaderBucketNone,
+			expectedMerged:    &datamodel.COSEHeader{Alg: &datamodel.COSEAlgorithm{Text: "ES256"}},
+		},
+		{
+			name:          "should reject an alg that is not an int or a tstr",
+			contents:      sign1(t, map[int]interface{}{1: []byte{7}}, map[int]interface{}{}),
+			expectedError: true,
+		},
+		{
+			name:          "should reject an x5chain that is not a bstr or an array of bstr",
+			contents:      sign1(t, map[int]interface{}{1: -7, 33: 5}, map[int]interface{}{}),
+			expectedError: true,
+		},
+		{
+			name:          "should reject a crit label that is not understood",
+			contents:      sign1(t, map[int]interface{}{1: -7, 2: []interface{}{99}, 99: 1}, map[int]interface{}{}),
+			expectedError: true,
+		},
+		{
+			name:          "should reject a text crit label",
+			contents:      sign1(t, map[int]interface{}{1: -7, 2: []interface{}{"reserved"}}, map[int]interface{}{}),
+			expectedError: true,
+		},
+		{
+			name:          "should reject an empty crit",
+			contents:      sign1(t, map[int]interface{}{1: -7, 2: []interface{}{}}, map[int]interface{}{}),
+			expectedError: true,
+		},
+		{
+			name:          "should reject crit in the unprotected header",
+			contents:      sign1(t, map[int]interface{}{1: -7}, map[int]interface{}{2: []interface{}{1}}),
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			output, err := decoder.FromQRCodeContents(tc.contents)
+			if tc.expectedError {
+				require.Error(t, err)
+				require.Equal(t, helper.StageProtectedHeader, helper.StageOf(err))
+				return
+			}
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedKidBucket, output.Headers.KidBucket())
+			require.Equal(t, tc.expectedMerged, output.Headers.Merged())
+
+			decMode, err := helper.DefaultDecoderOptions().DecMode()
+			require.NoError(t, err)
+			x5chain, err := output.Headers.Merged().X5ChainCertificates(decMode)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedX5Chain, x5chain)
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package helper
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/dasio/base45"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/webshield-dev/eudvcdecoder/datamodel"
+)
+
+//
+// Encode a EU Digital COVID Certificate, the inverse of the Decoder
+//
+
+/*
+The encoding steps are as follows
+1. CBOR encode the common payload, iss 1, exp 4, iat 6, and the DCC under -260/1
+2. Build the protected header with the algorithm and KeyID and sign the Sig_structure, ES256 for an ECDSA P-256 key
+   and PS256 for an RSA key
+3. CBOR encode the COSE_Sign1 as tag 18
+4. ZLIB compress the COSE_Sign1
+5. Base45 encode and prefix with HC1:
+*/
+
+//NewEncoder make an encoder
+func NewEncoder() Encoder {
+	return &encoderImpl{}
+}
+
+//Encoder methods to encode a EU covid certificate
+type Encoder interface {
+
+	//ToQRCodeContents encode and sign the payload, the QR code contents that start with HC1: are in the
+	//output DecodedQRCode. The output is populated as the Decoder would populate it when decoding the contents
+	ToQRCodeContents(payload *datamodel.DGCCommonPayload, signer crypto.Signer, kid []byte) (*Output, error)
+
+	//CBOREncodePayload CBOR encode the common payload, the COSE_Sign1 payload
+	CBOREncodePayload(payload *datamodel.DGCCommonPayload) ([]byte, error)
+}
+
+type encoderImpl struct{}
+
+//encodeMode deterministic so the same payload always encodes to the same bytes
+var encodeMode, _ = cbor.CanonicalEncOptions().EncMode()
+
+func (ei *encoderImpl) ToQRCodeContents(payload *datamodel.DGCCommonPayload, signer crypto.Signer,
+	kid []byte) (*Output, error) {
+
+	output := &Output{
+		DiagnoseLines: make([]string, 0),
+	}
+
+	//
+	//1. CBOR encode the payload
+	//
+	payloadB, err := ei.CBOREncodePayload(payload)
+	if err != nil {
+		return output, err
+	}
+	output.CBORUnmarshalledPayload = payloadB
+	output.CommonPayload = payload
+
+	//
+	//2. Protected header and signature
+	//
+	alg, err := coseAlgorithm(signer)
+	if err != nil {
+		return output, err
+	}
+
+	protectedHeader := map[int]interface{}{1: alg}
+	if len(kid) != 0 {
+		protectedHeader[4] = kid
+	}
+	protectedB, err := encodeMode.Marshal(protectedHeader)
+	if err != nil {
+		return output, fmt.Errorf("error cbor encoding protected header err=%s", err)
+	}
+	output.ProtectedHeader = protectedHeader
+
+	signedCWT := &datamodel.SignedCWT{
+		Protected: protectedB,
+		Payload:   payloadB,
+	}
+	signedCWT.Signature, err = signCOSESign1(signedCWT, signer)
+	if err != nil {
+		return output, err
+	}
+	output.SignedCWT = signedCWT
+	output.UnProtectedHeader = &signedCWT.Unprotected
+	output.Headers = &datamodel.COSEHeaders{Protected: &datamodel.COSEHeader{Alg: datamodel.IntAlgorithm(alg), Kid: kid},
+		Unprotected: &signedCWT.Unprotected}
+	output.COSESignature = signedCWT.Signature
+	output.Signatures = []*datamodel.COSESignature{{Protected: protectedB, Signature: signedCWT.Signature}}
+	output.SignatureHeaders = []*datamodel.COSEHeaders{output.Headers}
+
+	//
+	//3. COSE_Sign1 tag 18
+	//
+	output.COSeCBORTag = datamodel.COSESign1Tag
+	output.COSETagged = true
+	coseB, err := encodeMode.Marshal(cbor.Tag{Number: output.COSeCBORTag, Content: signedCWT})
+	if err != nil {
+		return output, fmt.Errorf("error cbor encoding COSE_Sign1 err=%s", err)
+	}
+	output.Inflated = coseB
+
+	//
+	//4. Compress
+	//
+	compressed := &bytes.Buffer{}
+	zlibWriter, err := zlib.NewWriterLevel(compressed, zlib.BestCompression)
+	if err != nil {
+		return output, err
+	}
+	if _, err := zlibWriter.Write(coseB); err != nil {
+		return output, fmt.Errorf("error compressing COSE_Sign1 err=%s", err)
+	}
+	if err := zlibWriter.Close(); err != nil {
+		return output, fmt.Errorf("error compressing COSE_Sign1 err=%s", err)
+	}
+	output.Base45Decoded = compressed.Bytes()
+
+	//
+	//5. Base45 and prefix
+	//
+	output.DecodedQRCode = []byte(datamodel.QRCodePrefix + ":" + base45.EncodeToString(output.Base45Decoded))
+	output.Decoded = true
+
+	return output, nil
+}
+
+func (ei *encoderImpl) CBOREncodePayload(payload *datamodel.DGCCommonPayload) ([]byte, error) {
+
+	if payload == nil || payload.HCERT.DCC() == nil {
+		return nil, fmt.Errorf("error encoding payload must contain a DCC")
+	}
+
+	dccI, err := cborDCC(payload.HCERT.DCC())
+	if err != nil {
+		return nil, err
+	}
+
+	cwt := map[int]interface{}{
+		-260: map[uint64]interface{}{datamodel.HCERTMapKeyOne: dccI},
+	}
+	if payload.ISS != "" {
+		cwt[1] = payload.ISS
+	}
+	if payload.EXP != 0 {
+		cwt[4] = payload.EXP
+	}
+	if payload.IAT != 0 {
+		cwt[6] = payload.IAT
+	}
+
+	payloadB, err := encodeMode.Marshal(cwt)
+	if err != nil {
+		return nil, fmt.Errorf("error cbor encoding payload err=%s", err)
+	}
+
+	return payloadB, nil
+}
+
+//cborDCC the DCC as the generic value to CBOR encode, going through JSON so the field names and formats are
+//the same as the JSON schema, dates and times are strings and whole numbers such as dn are integers
+func cborDCC(dcc *datamodel.DCC) (interface{}, error) {
+
+	jsonB, err := json.Marshal(dcc)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding DCC err=%s", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonB))
+	decoder.UseNumber()
+	var dccI interface{}
+	if err := decoder.Decode(&dccI); err != nil {
+		return nil, fmt.Errorf("error encoding DCC err=%s", err)
+	}
+
+	return cborNumbers(dccI), nil
+}
+
+//cborNumbers replace each json.Number with an int64 if whole otherwise a float64
+func cborNumbers(v interface{}) interface{} {
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, e := range value {
+			value[k] = cborNumbers(e)
+		}
+		return value
+	case []interface{}:
+		for i, e := range value {
+			value[i] = cborNumbers(e)
+		}
+		return value
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	default:
+		return v
+	}
+}
+
+//coseAlgorithm ES256 for an ECDSA P-256 key and PS256 for an RSA key, the algorithms the EU DCC allows
+func coseAlgorithm(signer crypto.Signer) (int, error) {
+
+	if signer == nil {
+		return 0, fmt.Errorf("error encoding no signer")
+	}
+
+	switch publicKey := signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		if publicKey.Curve != elliptic.P256() {
+			return 0, fmt.Errorf("error ES256 requires a P-256 key got=%s", publicKey.Curve.Params().Name)
+		}
+		return datamodel.COSEAlgES256, nil
+	case *rsa.PublicKey:
+		return datamodel.COSEAlgPS256, nil
+	default:
+		return 0, fmt.Errorf("error unsupported signing key type=%T", publicKey)
+	}
+}
+
+//signCOSESign1 sign the Sig_structure, an ES256 signature is r||s not ASN.1 DER,
+//see https://datatracker.ietf.org/doc/html/rfc8152#section-8.1
+func signCOSESign1(signedCWT *datamodel.SignedCWT, signer crypto.Signer) ([]byte, error) {
+
+	tbs, err := encodeMode.Marshal(signedCWT.SigStructure(nil))
+	if err != nil {
+		return nil, fmt.Errorf("error cbor encoding Sig_structure err=%s", err)
+	}
+	digest := sha256.Sum256(tbs)
+
+	alg, err := coseAlgorithm(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	if alg == datamodel.COSEAlgPS256 {
+		signature, err := signer.Sign(rand.Reader, digest[:],
+			&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256})
+		if err != nil {
+			return nil, fmt.Errorf("error PS256 signing err=%s", err)
+		}
+		return signature, nil
+	}
+
+	derSignature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("error ES256 signing err=%s", err)
+	}
+
+	var ecdsaSignature struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(derSignature, &ecdsaSignature); err != nil {
+		return nil, fmt.Errorf("error reading ES256 signature err=%s", err)
+	}
+
+	signature := make([]byte, 64)
+	ecdsaSignature.R.FillBytes(signature[:32])
+	ecdsaSignature.S.FillBytes(signature[32:])
+
+	return signature, nil
+}
@@ -0,0 +1,114 @@
+package helper_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+func Test_Encode_Round_Trip(t *testing.T) {
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	type testCase struct {
+		name string
+
+		qrCodePath  string
+		signer      crypto.Signer
+		expectedAlg int64
+	}
+
+	testCases := []testCase{
+		{
+			name:        "should round trip a vaccination signed ES256",
+			qrCodePath:  "../testfiles/dcc-testdata/DE/2DCode/png/1.png",
+			signer:      ecKey,
+			expectedAlg: -7,
+		},
+		{
+			name:        "should round trip a test signed PS256",
+			qrCodePath:  "../testfiles/dcc-testdata/DE/2DCode/png/2.png",
+			signer:      rsaKey,
+			expectedAlg: -37,
+		},
+		{
+			name:        "should round trip a recovery",
+			qrCodePath:  "../testfiles/dcc-testdata/NL/png/094-NL-recovery.png",
+			signer:      ecKey,
+			expectedAlg: -7,
+		},
+		{
+			name:        "should round trip a test with a sample time zone",
+			qrCodePath:  "../testfiles/dcc-testdata/IE/png/2_qr.png",
+			signer:      ecKey,
+			expectedAlg: -7,
+		},
+	}
+
+	vcDecoder := helper.NewDecoder(false, false)
+	vcEncoder := helper.NewEncoder()
+	kid := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			source, err := vcDecoder.FromFileQRCode(tc.qrCodePath)
+			require.NoError(t, err)
+
+			encodeOutput, err := vcEncoder.ToQRCodeContents(source.CommonPayload, tc.signer, kid)
+			require.NoError(t, err)
+			require.True(t, vcDecoder.IsDGCFromQRCodeContents(encodeOutput.DecodedQRCode), "should be a DGC")
+
+			decodeOutput, err := vcDecoder.FromQRCodeContents(encodeOutput.DecodedQRCode)
+			require.NoError(t, err)
+
+			//every stage should decode to what was encoded
+			require.Equal(t, encodeOutput.Base45Decoded, decodeOutput.Base45Decoded)
+			require.Equal(t, encodeOutput.Inflated, decodeOutput.Inflated)
+			require.Equal(t, encodeOutput.CBORUnmarshalledPayload, decodeOutput.CBORUnmarshalledPayload)
+			require.Equal(t, uint64(18), decodeOutput.COSeCBORTag)
+			require.Equal(t, tc.expectedAlg, decodeOutput.ProtectedHeader[1])
+			require.Equal(t, kid, decodeOutput.ProtectedHeader[4])
+
+			require.Equal(t, source.CommonPayload.ISS, decodeOutput.CommonPayload.ISS)
+			require.Equal(t, source.CommonPayload.IAT, decodeOutput.CommonPayload.IAT)
+			require.Equal(t, source.CommonPayload.EXP, decodeOutput.CommonPayload.EXP)
+			require.Equal(t, source.DCC(), decodeOutput.DCC())
+			require.Equal(t, source.SchemaViolations, decodeOutput.SchemaViolations)
+
+			valid, err := verifier.VerifyCOSESign1(decodeOutput.SignedCWT, tc.signer.Public())
+			require.NoError(t, err)
+			require.True(t, valid, "signature should verify")
+		})
+	}
+}
+
+func Test_Encode_Errors(t *testing.T) {
+
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	source, err := helper.NewDecoder(false, false).FromFileQRCode("../testfiles/dcc-testdata/DE/2DCode/png/1.png")
+	require.NoError(t, err)
+
+	vcEncoder := helper.NewEncoder()
+
+	_, err = vcEncoder.ToQRCodeContents(source.CommonPayload, p384Key, nil)
+	require.Error(t, err, "should only sign ES256 with a P-256 key")
+
+	_, err = vcEncoder.ToQRCodeContents(source.CommonPayload, nil, nil)
+	require.Error(t, err, "should need a signer")
+
+	_, err = vcEncoder.CBOREncodePayload(nil)
+	require.Error(t, err, "should need a payload with a DCC")
+}
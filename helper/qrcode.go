@@ -0,0 +1,158 @@
+package helper
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"github.com/makiuchi-d/gozxing/qrcode/decoder"
+	"github.com/makiuchi-d/gozxing/qrcode/encoder"
+)
+
+//
+// Render the HC1: QR code contents to a QR code image, see section 3 of
+// https://ec.europa.eu/health/sites/default/files/ehealth/docs/digital-green-certificates_v3_en.pdf
+// the contents are base45 so always fit the QR alphanumeric mode, and error correction level Q is recommended
+//
+
+const (
+	//DefaultModuleSize the pixels per QR code module (square) when not set in the QRCodeOptions
+	DefaultModuleSize = 4
+
+	//DefaultQuietZone the modules of white border when not set in the QRCodeOptions, 4 is the QR code minimum
+	DefaultQuietZone = 4
+)
+
+//QRCodeOptions how to render the QR code image, nil uses the defaults
+type QRCodeOptions struct {
+
+	//ModuleSize the pixels per module for a PNG, or the SVG user units per module, DefaultModuleSize if 0
+	ModuleSize int
+
+	//QuietZone the modules of white border around the QR code, DefaultQuietZone if 0, use a negative
+	//value for no border
+	QuietZone int
+}
+
+func (o *QRCodeOptions) moduleSize() int {
+	if o == nil || o.ModuleSize <= 0 {
+		return DefaultModuleSize
+	}
+	return o.ModuleSize
+}
+
+func (o *QRCodeOptions) quietZone() int {
+	if o == nil || o.QuietZone == 0 {
+		return DefaultQuietZone
+	}
+	if o.QuietZone < 0 {
+		return 0
+	}
+	return o.QuietZone
+}
+
+//ToQRCodePNGBytes render the QR code contents, such as the HC1: string from the Encoder, to a PNG that
+//FromQRCodePNGBytes can decode
+func ToQRCodePNGBytes(qrCodeContents []byte, opts *QRCodeOptions) ([]byte, error) {
+
+	matrix, err := qrCodeMatrix(qrCodeContents)
+	if err != nil {
+		return nil, err
+	}
+
+	moduleSize := opts.moduleSize()
+	quietZone := opts.quietZone()
+	modules := matrix.GetWidth()
+	size := (modules + 2*quietZone) * moduleSize
+
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for y := 0; y < modules; y++ {
+		for x := 0; x < modules; x++ {
+			if matrix.Get(x, y) != 1 {
+				continue
+			}
+			x0 := (x + quietZone) * moduleSize
+			y0 := (y + quietZone) * moduleSize
+			for py := y0; py < y0+moduleSize; py++ {
+				for px := x0; px < x0+moduleSize; px++ {
+					img.SetGray(px, py, color.Gray{Y: 0})
+				}
+			}
+		}
+	}
+
+	pngB := &bytes.Buffer{}
+	if err := png.Encode(pngB, img); err != nil {
+		return nil, fmt.Errorf("error encoding QR code PNG err=%s", err)
+	}
+
+	return pngB.Bytes(), nil
+}
+
+//ToQRCodeSVGBytes render the QR code contents to an SVG, each module is ModuleSize user units, adjacent dark
+//modules in a row are drawn as one rectangle
+func ToQRCodeSVGBytes(qrCodeContents []byte, opts *QRCodeOptions) ([]byte, error) {
+
+	matrix, err := qrCodeMatrix(qrCodeContents)
+	if err != nil {
+		return nil, err
+	}
+
+	moduleSize := opts.moduleSize()
+	quietZone := opts.quietZone()
+	modules := matrix.GetWidth()
+	size := (modules + 2*quietZone) * moduleSize
+
+	path := &strings.Builder{}
+	for y := 0; y < modules; y++ {
+		for x := 0; x < modules; {
+			if matrix.Get(x, y) != 1 {
+				x++
+				continue
+			}
+			run := 1
+			for x+run < modules && matrix.Get(x+run, y) == 1 {
+				run++
+			}
+			_, _ = fmt.Fprintf(path, "M%d %dh%dv%dh-%dz",
+				(x+quietZone)*moduleSize, (y+quietZone)*moduleSize, run*moduleSize, moduleSize, run*moduleSize)
+			x += run
+		}
+	}
+
+	svg := &bytes.Buffer{}
+	_, _ = fmt.Fprintf(svg, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	_, _ = fmt.Fprintf(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		size, size, size, size)
+	_, _ = fmt.Fprintf(svg, `<rect width="%d" height="%d" fill="#ffffff"/>`+"\n", size, size)
+	_, _ = fmt.Fprintf(svg, `<path fill="#000000" d="%s"/>`+"\n", path.String())
+	_, _ = fmt.Fprintf(svg, "</svg>\n")
+
+	return svg.Bytes(), nil
+}
+
+//qrCodeMatrix encode with error correction level Q, the contents must fit the alphanumeric mode
+func qrCodeMatrix(qrCodeContents []byte) (*encoder.ByteMatrix, error) {
+
+	if len(qrCodeContents) == 0 {
+		return nil, fmt.Errorf("error rendering QR code no contents")
+	}
+
+	qrCode, err := encoder.Encoder_encodeWithoutHint(string(qrCodeContents), decoder.ErrorCorrectionLevel_Q)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding QR code err=%s", err)
+	}
+
+	if qrCode.GetMode() != decoder.Mode_ALPHANUMERIC {
+		return nil, fmt.Errorf("error QR code contents must be alphanumeric, base45 with an upper case prefix, mode=%s",
+			qrCode.GetMode())
+	}
+
+	return qrCode.GetMatrix(), nil
+}
@@ -0,0 +1,132 @@
+package helper_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/xml"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+)
+
+func Test_QRCode_PNG_Round_Trip(t *testing.T) {
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	vcDecoder := helper.NewDecoder(false, false)
+	source, err := vcDecoder.FromFileQRCode("../testfiles/dcc-testdata/DE/2DCode/png/1.png")
+	require.NoError(t, err)
+
+	encodeOutput, err := helper.NewEncoder().ToQRCodeContents(source.CommonPayload, ecKey, []byte{1, 2, 3, 4})
+	require.NoError(t, err)
+
+	type testCase struct {
+		name string
+
+		opts              *helper.QRCodeOptions
+		expectedQuietZone int
+		expectedModule    int
+	}
+
+	testCases := []testCase{
+		{
+			name:              "should render with the defaults",
+			expectedQuietZone: helper.DefaultQuietZone,
+			expectedModule:    helper.DefaultModuleSize,
+		},
+		{
+			name:              "should render with a larger module and quiet zone",
+			opts:              &helper.QRCodeOptions{ModuleSize: 6, QuietZone: 8},
+			expectedQuietZone: 8,
+			expectedModule:    6,
+		},
+		{
+			name:              "should render with no quiet zone",
+			opts:              &helper.QRCodeOptions{ModuleSize: 3, QuietZone: -1},
+			expectedQuietZone: 0,
+			expectedModule:    3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			pngB, err := helper.ToQRCodePNGBytes(encodeOutput.DecodedQRCode, tc.opts)
+			require.NoError(t, err)
+
+			img, err := png.Decode(bytes.NewReader(pngB))
+			require.NoError(t, err)
+			size := img.Bounds().Dx()
+			require.Equal(t, size, img.Bounds().Dy(), "should be square")
+			require.Zero(t, size%tc.expectedModule, "size should be a whole number of modules")
+
+			//QR code versions are 21 modules plus 4 per version
+			modules := size/tc.expectedModule - 2*tc.expectedQuietZone
+			require.Zero(t, (modules-21)%4, "modules=%d", modules)
+
+			//the top left finder pattern starts after the quiet zone
+			r, _, _, _ := img.At(tc.expectedQuietZone*tc.expectedModule, tc.expectedQuietZone*tc.expectedModule).RGBA()
+			require.Zero(t, r, "finder pattern should be dark")
+			if tc.expectedQuietZone != 0 {
+				r, _, _, _ = img.At(0, 0).RGBA()
+				require.Equal(t, uint32(0xffff), r, "quiet zone should be light")
+			}
+
+			if tc.expectedQuietZone == 0 {
+				//gozxing needs a quiet zone to find the code
+				return
+			}
+
+			decodeOutput, err := vcDecoder.FromQRCodePNGBytes(pngB)
+			require.NoError(t, err)
+			require.Equal(t, encodeOutput.DecodedQRCode, decodeOutput.DecodedQRCode)
+			require.Equal(t, source.DCC(), decodeOutput.DCC())
+		})
+	}
+}
+
+func Test_QRCode_SVG(t *testing.T) {
+
+	source, err := helper.NewDecoder(false, false).FromFileQRCode("../testfiles/dcc-testdata/DE/2DCode/png/1.png")
+	require.NoError(t, err)
+	contents := source.DecodedQRCode
+
+	svgB, err := helper.ToQRCodeSVGBytes(contents, &helper.QRCodeOptions{ModuleSize: 5})
+	require.NoError(t, err)
+
+	var svg struct {
+		XMLName xml.Name `xml:"svg"`
+		Width   int      `xml:"width,attr"`
+		Height  int      `xml:"height,attr"`
+		Path    struct {
+			D string `xml:"d,attr"`
+		} `xml:"path"`
+	}
+	require.NoError(t, xml.Unmarshal(svgB, &svg), "should be well formed")
+	require.Equal(t, svg.Width, svg.Height)
+	require.NotEmpty(t, svg.Path.D)
+
+	//same size as the PNG with the same options
+	pngB, err := helper.ToQRCodePNGBytes(contents, &helper.QRCodeOptions{ModuleSize: 5})
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(pngB))
+	require.NoError(t, err)
+	require.Equal(t, img.Bounds().Dx(), svg.Width)
+}
+
+func Test_QRCode_Errors(t *testing.T) {
+
+	_, err := helper.ToQRCodePNGBytes(nil, nil)
+	require.Error(t, err, "should need contents")
+
+	_, err = helper.ToQRCodePNGBytes([]byte("hc1:lowercase"), nil)
+	require.Error(t, err, "should only render alphanumeric contents")
+
+	_, err = helper.ToQRCodeSVGBytes([]byte("HC1:{}"), nil)
+	require.Error(t, err, "should only render alphanumeric contents")
+}
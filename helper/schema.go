@@ -0,0 +1,73 @@
+package helper
+
+import (
+	"fmt"
+
+	"github.com/webshield-dev/eudvcdecoder/schema"
+)
+
+//hcertClaimKey the CWT claim key holding the HCERT, see DGCPayloadCBORMapping
+const hcertClaimKey int64 = -260
+
+//ValidateSchema validate the DCC as decoded from the CBOR payload, not the datamodel.DCC, so that values the
+//datamodel would coerce, such as a dn of 1.5, are still reported. The violations are added to the output,
+//an error is returned only if the DCC could not be validated
+func ValidateSchema(output *Output) error {
+
+	if output == nil {
+		return fmt.Errorf("error validating schema no output")
+	}
+
+	rawDCC := payloadDCC(output.PayloadI)
+	if rawDCC == nil {
+		return fmt.Errorf("error validating schema no DCC in the CWT payload")
+	}
+
+	result, err := schema.Validate(rawDCC)
+	if err != nil {
+		return fmt.Errorf("error validating schema err=%s", err)
+	}
+
+	output.SchemaValidated = true
+	output.SchemaVersion = result.Version
+	output.SchemaViolations = result.Violations
+
+	return nil
+}
+
+//payloadDCC the DCC within the CBOR decoded CWT payload, nil if not found
+func payloadDCC(payloadI interface{}) interface{} {
+
+	payload, ok := payloadI.(map[interface{}]interface{})
+	if !ok {
+		return nil
+	}
+
+	var hcert map[interface{}]interface{}
+	for k, v := range payload {
+		if claimKey(k) == hcertClaimKey {
+			hcert, _ = v.(map[interface{}]interface{})
+		}
+	}
+
+	for k, v := range hcert {
+		if claimKey(k) == 1 {
+			return v
+		}
+	}
+
+	return nil
+}
+
+//claimKey CBOR decodes positive ints as uint64 and negative as int64
+func claimKey(k interface{}) int64 {
+
+	switch key := k.(type) {
+	case uint64:
+		return int64(key)
+	case int64:
+		return key
+	default:
+		return 0
+	}
+}
@@ -2,7 +2,12 @@ package helper
 
 import (
 	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/webshield-dev/eudvcdecoder/datamodel"
+	"github.com/webshield-dev/eudvcdecoder/valuesetdata"
 )
 
 // Routines to get value sets that are loaded from files that are located  at
@@ -12,13 +17,36 @@ const (
 	maFileName string = "/vaccine-mah-manf.json"
 	mpFileName string = "/vaccine-medicinal-product.json"
 	vpFileName string = "/vaccine-prophylaxis.json"
+	ttFileName string = "/test-type.json"
+	trFileName string = "/test-result.json"
+
+	//codeMappingsFileName the versioned mapping from the value set codes to CVX, MVX, SNOMED and ATC
+	codeMappingsFileName string = "/vaccine-code-mappings.json"
 )
 
 //NewValueSetMapper create and initialize all its internal data
 func NewValueSetMapper(vsDataPath string) (*ValueSetMapper, error) {
 
 	vsm := &ValueSetMapper{
-		vsDataPath: vsDataPath,
+		readData: func(fileName string) ([]byte, error) {
+			return ReadData(vsDataPath + fileName)
+		},
+	}
+	if err := vsm.init(); err != nil {
+		return nil, err
+	}
+
+	return vsm, nil
+}
+
+//NewEmbeddedValueSetMapper create from the value set files embedded in the valuesetdata package, so the files do
+//not need to be on disk
+func NewEmbeddedValueSetMapper() (*ValueSetMapper, error) {
+
+	vsm := &ValueSetMapper{
+		readData: func(fileName string) ([]byte, error) {
+			return valuesetdata.Files.ReadFile(strings.TrimPrefix(fileName, "/"))
+		},
 	}
 	if err := vsm.init(); err != nil {
 		return nil, err
@@ -29,10 +57,14 @@ func NewValueSetMapper(vsDataPath string) (*ValueSetMapper, error) {
 
 //ValueSetMapper maps codes to metadata value sets
 type ValueSetMapper struct {
-	vsDataPath string
-	maCodes    *datamodel.ValueSet
-	mpCodes    *datamodel.ValueSet
-	vpCodes    *datamodel.ValueSet
+	readData func(fileName string) ([]byte, error)
+	maCodes  *datamodel.ValueSet
+	mpCodes  *datamodel.ValueSet
+	vpCodes  *datamodel.ValueSet
+	ttCodes  *datamodel.ValueSet
+	trCodes  *datamodel.ValueSet
+
+	codeMappings *datamodel.CodeMappings
 }
 
 //DecodeMA decode the Marketing authorisation holder or manufacturer, a coded value
@@ -56,10 +88,106 @@ func (vsm *ValueSetMapper) DecodeVP(code string) *datamodel.ValueSetValue {
 	return &result
 }
 
+//DecodeTT decode the type of test, a coded value from the value set test-type.json
+func (vsm *ValueSetMapper) DecodeTT(code string) *datamodel.ValueSetValue {
+	result := vsm.ttCodes.ValueSetValues[code]
+	return &result
+}
+
+//DecodeTR decode the result of the test, a coded value from the value set test-result.json
+func (vsm *ValueSetMapper) DecodeTR(code string) *datamodel.ValueSetValue {
+	result := vsm.trCodes.ValueSetValues[code]
+	return &result
+}
+
+//ValueSets the codes in each value set by value set id, as the business rules external valueSets
+func (vsm *ValueSetMapper) ValueSets() map[string][]string {
+
+	valueSets := make(map[string][]string)
+	for _, vs := range []*datamodel.ValueSet{vsm.maCodes, vsm.mpCodes, vsm.vpCodes, vsm.ttCodes, vsm.trCodes} {
+		codes := make([]string, 0, len(vs.ValueSetValues))
+		for code := range vs.ValueSetValues {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		valueSets[vs.ValueSetID] = codes
+	}
+
+	return valueSets
+}
+
+//CodeMappingsVersion the version of the code mappings used by the MPTo, VPTo and MATo methods
+func (vsm *ValueSetMapper) CodeMappingsVersion() string {
+	return vsm.codeMappings.Version
+}
+
+//MPToCVX the CVX code for a vaccine-medicinal-product.json code, an error if the code is unknown or has no CVX code
+func (vsm *ValueSetMapper) MPToCVX(code string) (string, error) {
+	return vsm.mapCode(vsm.codeMappings.MedicinalProducts, "mp", code, "CVX", func(m datamodel.CodeMapping) string {
+		return m.CVX
+	})
+}
+
+//MPToSNOMED the SNOMED vaccine type for a vaccine-medicinal-product.json code, an error if the code is unknown
+//or has no SNOMED code
+func (vsm *ValueSetMapper) MPToSNOMED(code string) (string, error) {
+	return vsm.mapCode(vsm.codeMappings.MedicinalProducts, "mp", code, "SNOMED", func(m datamodel.CodeMapping) string {
+		return m.SNOMED
+	})
+}
+
+//MPToATC the ATC code for a vaccine-medicinal-product.json code, an error if the code is unknown or has no ATC code
+func (vsm *ValueSetMapper) MPToATC(code string) (string, error) {
+	return vsm.mapCode(vsm.codeMappings.MedicinalProducts, "mp", code, "ATC", func(m datamodel.CodeMapping) string {
+		return m.ATC
+	})
+}
+
+//VPToCVX the CVX code for a vaccine-prophylaxis.json SNOMED code, the CVX code is for an unspecified formulation
+//as the vaccine type does not say which product was used. An error if the code is unknown or has no CVX code
+func (vsm *ValueSetMapper) VPToCVX(code string) (string, error) {
+	return vsm.mapCode(vsm.codeMappings.Prophylaxis, "vp", code, "CVX", func(m datamodel.CodeMapping) string {
+		return m.CVX
+	})
+}
+
+//VPToATC the ATC code for a vaccine-prophylaxis.json SNOMED code, an error if the code is unknown or has no ATC code
+func (vsm *ValueSetMapper) VPToATC(code string) (string, error) {
+	return vsm.mapCode(vsm.codeMappings.Prophylaxis, "vp", code, "ATC", func(m datamodel.CodeMapping) string {
+		return m.ATC
+	})
+}
+
+//MAToMVX the MVX code for a vaccine-mah-manf.json code, an error if the code is unknown or has no MVX code
+func (vsm *ValueSetMapper) MAToMVX(code string) (string, error) {
+	return vsm.mapCode(vsm.codeMappings.MarketingAuthorisationHolders, "ma", code, "MVX",
+		func(m datamodel.CodeMapping) string {
+			return m.MVX
+		})
+}
+
+//mapCode the target code, unknown codes are errors rather than an empty code
+func (vsm *ValueSetMapper) mapCode(mappings map[string]datamodel.CodeMapping, field string, code string,
+	target string, targetCode func(datamodel.CodeMapping) string) (string, error) {
+
+	mapping, ok := mappings[code]
+	if !ok {
+		return "", fmt.Errorf("error unknown %s=%s no %s mapping version=%s", field, code, target,
+			vsm.codeMappings.Version)
+	}
+
+	mapped := targetCode(mapping)
+	if mapped == "" {
+		return "", fmt.Errorf("error %s=%s has no %s code version=%s", field, code, target, vsm.codeMappings.Version)
+	}
+
+	return mapped, nil
+}
+
 func (vsm *ValueSetMapper) init() error {
 
 	//setup ma
-	data, err := ReadData(vsm.vsDataPath + maFileName)
+	data, err := vsm.readData(maFileName)
 	if err != nil {
 		return err
 	}
@@ -70,7 +198,7 @@ func (vsm *ValueSetMapper) init() error {
 	vsm.maCodes = &maCodes
 
 	//setup mp
-	data, err = ReadData(vsm.vsDataPath + mpFileName)
+	data, err = vsm.readData(mpFileName)
 	if err != nil {
 		return err
 	}
@@ -81,7 +209,7 @@ func (vsm *ValueSetMapper) init() error {
 	vsm.mpCodes = &mpCodes
 
 	//setup vp
-	data, err = ReadData(vsm.vsDataPath + vpFileName)
+	data, err = vsm.readData(vpFileName)
 	if err != nil {
 		return err
 	}
@@ -91,6 +219,39 @@ func (vsm *ValueSetMapper) init() error {
 	}
 	vsm.vpCodes = &vpCodes
 
+	//setup tt
+	data, err = vsm.readData(ttFileName)
+	if err != nil {
+		return err
+	}
+	var ttCodes datamodel.ValueSet
+	if err = json.Unmarshal(data, &ttCodes); err != nil {
+		return err
+	}
+	vsm.ttCodes = &ttCodes
+
+	//setup tr
+	data, err = vsm.readData(trFileName)
+	if err != nil {
+		return err
+	}
+	var trCodes datamodel.ValueSet
+	if err = json.Unmarshal(data, &trCodes); err != nil {
+		return err
+	}
+	vsm.trCodes = &trCodes
+
+	//setup code mappings
+	data, err = vsm.readData(codeMappingsFileName)
+	if err != nil {
+		return err
+	}
+	var codeMappings datamodel.CodeMappings
+	if err = json.Unmarshal(data, &codeMappings); err != nil {
+		return fmt.Errorf("error reading code mappings err=%s", err)
+	}
+	vsm.codeMappings = &codeMappings
+
 	return nil
 
 }
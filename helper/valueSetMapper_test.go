@@ -2,6 +2,7 @@ package helper_test
 
 import (
     "github.com/stretchr/testify/require"
+    "github.com/webshield-dev/eudvcdecoder/datamodel"
     "github.com/webshield-dev/eudvcdecoder/helper"
     "testing"
 )
@@ -91,3 +92,175 @@ func Test_VPValueSet_Mapper(t *testing.T) {
 		})
 	}
 }
+
+func Test_Test_ValueSet_Mapper(t *testing.T) {
+
+	vsMapper, err := helper.NewValueSetMapper(vsDataPath)
+	require.NoError(t, err)
+
+	type testCase struct {
+		name                string
+		decode              func(code string) *datamodel.ValueSetValue
+		code                string
+		expectedDisplayName string
+	}
+
+	testCases := []testCase{
+		{
+			name:                "should find a NAAT test type",
+			decode:              vsMapper.DecodeTT,
+			code:                "LP6464-4",
+			expectedDisplayName: "Nucleic acid amplification with probe detection",
+		},
+		{
+			name:                "should find a RAT test type",
+			decode:              vsMapper.DecodeTT,
+			code:                "LP217198-3",
+			expectedDisplayName: "Rapid immunoassay",
+		},
+		{
+			name:                "should find not detected",
+			decode:              vsMapper.DecodeTR,
+			code:                "260415000",
+			expectedDisplayName: "Not detected",
+		},
+		{
+			name:                "should have no display for an unknown code",
+			decode:              vsMapper.DecodeTR,
+			code:                "999",
+			expectedDisplayName: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expectedDisplayName, tc.decode(tc.code).Display)
+		})
+	}
+
+	valueSets := vsMapper.ValueSets()
+	require.Equal(t, []string{"LP217198-3", "LP6464-4"}, valueSets["covid-19-lab-test-type"])
+	require.Equal(t, []string{"260373001", "260415000"}, valueSets["covid-19-lab-result"])
+}
+
+func Test_Code_Mappings(t *testing.T) {
+
+	vsMapper, err := helper.NewValueSetMapper(vsDataPath)
+	require.NoError(t, err)
+	require.Equal(t, "1.0.0", vsMapper.CodeMappingsVersion())
+
+	type testCase struct {
+		name          string
+		mapCode       func(code string) (string, error)
+		code          string
+		expectedCode  string
+		expectedError bool
+	}
+
+	testCases := []testCase{
+		{
+			name:         "should map Moderna mp to CVX",
+			mapCode:      vsMapper.MPToCVX,
+			code:         "EU/1/20/1507",
+			expectedCode: "207",
+		},
+		{
+			name:         "should map Comirnaty mp to SNOMED",
+			mapCode:      vsMapper.MPToSNOMED,
+			code:         "EU/1/20/1528",
+			expectedCode: "1119349007",
+		},
+		{
+			name:         "should map Janssen mp to ATC",
+			mapCode:      vsMapper.MPToATC,
+			code:         "EU/1/20/1525",
+			expectedCode: "J07BX03",
+		},
+		{
+			name:         "should map mRNA vp to CVX unspecified formulation",
+			mapCode:      vsMapper.VPToCVX,
+			code:         "1119349007",
+			expectedCode: "213",
+		},
+		{
+			name:         "should map vp to ATC",
+			mapCode:      vsMapper.VPToATC,
+			code:         "1119305005",
+			expectedCode: "J07BX03",
+		},
+		{
+			name:         "should map Moderna ma to MVX",
+			mapCode:      vsMapper.MAToMVX,
+			code:         "ORG-100031184",
+			expectedCode: "MOD",
+		},
+		{
+			name:          "should report an unknown mp",
+			mapCode:       vsMapper.MPToCVX,
+			code:          "EU/1/99/9999",
+			expectedError: true,
+		},
+		{
+			name:          "should report an mp with no CVX code",
+			mapCode:       vsMapper.MPToCVX,
+			code:          "CVnCoV",
+			expectedError: true,
+		},
+		{
+			name:          "should report an ma with no MVX code",
+			mapCode:       vsMapper.MAToMVX,
+			code:          "Gamaleya-Research-Institute",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			mapped, err := tc.mapCode(tc.code)
+			if tc.expectedError {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.code, "should say which code")
+				require.Empty(t, mapped)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedCode, mapped)
+		})
+	}
+}
+
+func Test_Code_Mappings_Cover_Value_Sets(t *testing.T) {
+
+	vsMapper, err := helper.NewValueSetMapper(vsDataPath)
+	require.NoError(t, err)
+
+	valueSets := vsMapper.ValueSets()
+	require.NotEmpty(t, valueSets["vaccines-covid-19-names"])
+	require.NotEmpty(t, valueSets["sct-vaccines-covid-19"])
+
+	//every product and vaccine type has an ATC code, so a new value set code without a mapping is found
+	for _, code := range valueSets["vaccines-covid-19-names"] {
+		_, err := vsMapper.MPToATC(code)
+		require.NoError(t, err, "should map mp=%s", code)
+	}
+	for _, code := range valueSets["sct-vaccines-covid-19"] {
+		_, err := vsMapper.VPToATC(code)
+		require.NoError(t, err, "should map vp=%s", code)
+	}
+}
+
+func Test_Embedded_Value_Set_Mapper(t *testing.T) {
+
+	vsMapper, err := helper.NewValueSetMapper(vsDataPath)
+	require.NoError(t, err)
+	embedded, err := helper.NewEmbeddedValueSetMapper()
+	require.NoError(t, err)
+
+	require.Equal(t, vsMapper.CodeMappingsVersion(), embedded.CodeMappingsVersion())
+	require.Equal(t, vsMapper.ValueSets(), embedded.ValueSets(), "should embed the same value sets")
+
+	cvx, err := embedded.MPToCVX("EU/1/20/1528")
+	require.NoError(t, err)
+	require.Equal(t, "208", cvx)
+}
@@ -0,0 +1,48 @@
+package testvectors
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+)
+
+//
+// Reads the dcc-testdata raw files, see https://github.com/eu-digital-green-certificates/dgc-testdata, shared by the
+// package tests
+//
+
+//Vector the parts of a dcc-testdata raw file the tests need
+type Vector struct {
+	Prefix  string `json:"PREFIX"`
+	TestCtx struct {
+		Certificate     string `json:"CERTIFICATE"`
+		ValidationClock string `json:"VALIDATIONCLOCK"`
+	} `json:"TESTCTX"`
+}
+
+//Read the vector and its TESTCTX.CERTIFICATE, fails the test if either cannot be read
+func Read(t *testing.T, path string) (*Vector, *x509.Certificate) {
+
+	jsonB, err := helper.ReadData(path)
+	require.NoError(t, err)
+
+	var tv Vector
+	require.NoError(t, json.Unmarshal(jsonB, &tv))
+
+	der, err := base64.StdEncoding.DecodeString(tv.TestCtx.Certificate)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &tv, cert
+}
+
+//ReadCertificate the TESTCTX.CERTIFICATE of the vector, fails the test if it cannot be read
+func ReadCertificate(t *testing.T, path string) *x509.Certificate {
+	_, cert := Read(t, path)
+	return cert
+}
@@ -0,0 +1,593 @@
+package rules
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//
+// CertLogic, the subset of JsonLogic the EU uses for business rules, see
+// https://github.com/ehn-dcc-development/dgc-business-rules/blob/main/certlogic/specification/README.md
+//
+// An expression is a JSON value, either a literal or an object with a single operator key whose value is the
+// array of operands. Expressions are as decoded by encoding/json so numbers are float64, CertLogic only allows
+// integers. Dates are only made by plusTime, and only compared by after, before, not-after and not-before
+//
+
+//operators the supported CertLogic operations
+const (
+	opVar             = "var"
+	opIf              = "if"
+	opStrictEquals    = "==="
+	opAnd             = "and"
+	opLess            = "<"
+	opGreater         = ">"
+	opLessOrEqual     = "<="
+	opGreaterOrEqual  = ">="
+	opIn              = "in"
+	opPlus            = "+"
+	opAfter           = "after"
+	opBefore          = "before"
+	opNotAfter        = "not-after"
+	opNotBefore       = "not-before"
+	opPlusTime        = "plusTime"
+	opReduce          = "reduce"
+	opNot             = "!"
+	opExtractFromUVCI = "extractFromUVCI"
+)
+
+//Evaluate evaluate the CertLogic expression against the data, an error if the expression is not valid
+//CertLogic or the operands have the wrong types
+func Evaluate(expr interface{}, data interface{}) (interface{}, error) {
+
+	switch value := expr.(type) {
+	case nil, bool, string:
+		return value, nil
+	case float64:
+		return value, nil
+	case int:
+		return float64(value), nil
+	case []interface{}:
+		evaluated := make([]interface{}, len(value))
+		for i, e := range value {
+			result, err := Evaluate(e, data)
+			if err != nil {
+				return nil, err
+			}
+			evaluated[i] = result
+		}
+		return evaluated, nil
+	case map[string]interface{}:
+		return evaluateOperation(value, data)
+	default:
+		return nil, fmt.Errorf("error CertLogic unsupported expression type=%T", expr)
+	}
+}
+
+func evaluateOperation(expr map[string]interface{}, data interface{}) (interface{}, error) {
+
+	if len(expr) != 1 {
+		return nil, fmt.Errorf("error CertLogic operation must have exactly one operator got=%d", len(expr))
+	}
+
+	var operator string
+	var operandsI interface{}
+	for key, value := range expr {
+		operator, operandsI = key, value
+	}
+
+	if operator == opVar {
+		path, ok := operandsI.(string)
+		if !ok {
+			return nil, fmt.Errorf("error CertLogic var operand must be a string got=%T", operandsI)
+		}
+		return evaluateVar(path, data), nil
+	}
+
+	operands, ok := operandsI.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error CertLogic %s operands must be an array got=%T", operator, operandsI)
+	}
+
+	switch operator {
+	case opIf:
+		return evaluateIf(operands, data)
+	case opStrictEquals:
+		return evaluateStrictEquals(operands, data)
+	case opAnd:
+		return evaluateAnd(operands, data)
+	case opLess, opGreater, opLessOrEqual, opGreaterOrEqual:
+		return evaluateIntegerComparison(operator, operands, data)
+	case opIn:
+		return evaluateIn(operands, data)
+	case opPlus:
+		return evaluatePlus(operands, data)
+	case opAfter, opBefore, opNotAfter, opNotBefore:
+		return evaluateDateComparison(operator, operands, data)
+	case opPlusTime:
+		return evaluatePlusTime(operands, data)
+	case opReduce:
+		return evaluateReduce(operands, data)
+	case opNot:
+		return evaluateNot(operands, data)
+	case opExtractFromUVCI:
+		return evaluateExtractFromUVCI(operands, data)
+	default:
+		return nil, fmt.Errorf("error CertLogic unsupported operator=%s", operator)
+	}
+}
+
+//evaluateVar the value at the dot separated path, an integer fragment indexes an array. Null if any part
+//of the path does not exist, the empty path is the data
+func evaluateVar(path string, data interface{}) interface{} {
+
+	if path == "" {
+		return data
+	}
+
+	current := data
+	for _, fragment := range strings.Split(path, ".") {
+		switch value := current.(type) {
+		case map[string]interface{}:
+			current = value[fragment]
+		case []interface{}:
+			index, err := strconv.Atoi(fragment)
+			if err != nil || index < 0 || index >= len(value) {
+				return nil
+			}
+			current = value[index]
+		default:
+			return nil
+		}
+	}
+
+	return current
+}
+
+func evaluateIf(operands []interface{}, data interface{}) (interface{}, error) {
+
+	if err := operandCount(opIf, operands, 3, 3); err != nil {
+		return nil, err
+	}
+
+	guard, err := Evaluate(operands[0], data)
+	if err != nil {
+		return nil, err
+	}
+
+	truthy, err := truthiness(opIf, guard)
+	if err != nil {
+		return nil, err
+	}
+	if truthy {
+		return Evaluate(operands[1], data)
+	}
+
+	return Evaluate(operands[2], data)
+}
+
+func evaluateStrictEquals(operands []interface{}, data interface{}) (interface{}, error) {
+
+	if err := operandCount(opStrictEquals, operands, 2, 2); err != nil {
+		return nil, err
+	}
+
+	values, err := evaluateAll(operands, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return strictEquals(values[0], values[1]), nil
+}
+
+//evaluateAnd the first falsy operand, the later operands are not evaluated, or the last operand if all are truthy
+func evaluateAnd(operands []interface{}, data interface{}) (interface{}, error) {
+
+	if err := operandCount(opAnd, operands, 2, -1); err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	for _, operand := range operands {
+		value, err := Evaluate(operand, data)
+		if err != nil {
+			return nil, err
+		}
+
+		truthy, err := truthiness(opAnd, value)
+		if err != nil {
+			return nil, err
+		}
+		if !truthy {
+			return value, nil
+		}
+		result = value
+	}
+
+	return result, nil
+}
+
+//evaluateIntegerComparison compare two integers, or with three operands that the second is between the first and
+//third, such as 0 < x < 10
+func evaluateIntegerComparison(operator string, operands []interface{}, data interface{}) (interface{}, error) {
+
+	if err := operandCount(operator, operands, 2, 3); err != nil {
+		return nil, err
+	}
+
+	values, err := evaluateAll(operands, data)
+	if err != nil {
+		return nil, err
+	}
+
+	integers := make([]float64, len(values))
+	for i, value := range values {
+		integer, ok := toInteger(value)
+		if !ok {
+			return nil, fmt.Errorf("error CertLogic %s operands must be integers got=%v", operator, value)
+		}
+		integers[i] = integer
+	}
+
+	for i := 0; i+1 < len(integers); i++ {
+		if !compare(operator, integers[i], integers[i+1]) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func evaluateIn(operands []interface{}, data interface{}) (interface{}, error) {
+
+	if err := operandCount(opIn, operands, 2, 2); err != nil {
+		return nil, err
+	}
+
+	values, err := evaluateAll(operands, data)
+	if err != nil {
+		return nil, err
+	}
+
+	list, ok := values[1].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error CertLogic in second operand must be an array got=%T", values[1])
+	}
+	for _, item := range list {
+		if strictEquals(values[0], item) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func evaluatePlus(operands []interface{}, data interface{}) (interface{}, error) {
+
+	if err := operandCount(opPlus, operands, 2, 2); err != nil {
+		return nil, err
+	}
+
+	values, err := evaluateAll(operands, data)
+	if err != nil {
+		return nil, err
+	}
+
+	left, okLeft := toInteger(values[0])
+	right, okRight := toInteger(values[1])
+	if !okLeft || !okRight {
+		return nil, fmt.Errorf("error CertLogic + operands must be integers got=%v", values)
+	}
+
+	return left + right, nil
+}
+
+//evaluateDateComparison compare date-times made by plusTime, with three operands the second is compared to the
+//first and third
+func evaluateDateComparison(operator string, operands []interface{}, data interface{}) (interface{}, error) {
+
+	if err := operandCount(operator, operands, 2, 3); err != nil {
+		return nil, err
+	}
+
+	values, err := evaluateAll(operands, data)
+	if err != nil {
+		return nil, err
+	}
+
+	unixNanos := make([]float64, len(values))
+	for i, value := range values {
+		dateTime, ok := value.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("error CertLogic %s operands must be date-times from plusTime got=%v",
+				operator, value)
+		}
+		unixNanos[i] = float64(dateTime.UnixNano())
+	}
+
+	comparison := map[string]string{
+		opAfter:     opGreater,
+		opBefore:    opLess,
+		opNotAfter:  opLessOrEqual,
+		opNotBefore: opGreaterOrEqual,
+	}[operator]
+	for i := 0; i+1 < len(unixNanos); i++ {
+		if !compare(comparison, unixNanos[i], unixNanos[i+1]) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+//evaluatePlusTime add the integer amount of the unit (year, month, day, hour) to the date or date-time string
+func evaluatePlusTime(operands []interface{}, data interface{}) (interface{}, error) {
+
+	if err := operandCount(opPlusTime, operands, 3, 3); err != nil {
+		return nil, err
+	}
+
+	value, err := Evaluate(operands[0], data)
+	if err != nil {
+		return nil, err
+	}
+	dateTimeString, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("error CertLogic plusTime first operand must be a string got=%v", value)
+	}
+	dateTime, err := ParseDateTime(dateTimeString)
+	if err != nil {
+		return nil, err
+	}
+
+	amountF, ok := toInteger(operands[1])
+	if !ok {
+		return nil, fmt.Errorf("error CertLogic plusTime amount must be an integer literal got=%v", operands[1])
+	}
+	amount := int(amountF)
+
+	switch operands[2] {
+	case "year":
+		return dateTime.AddDate(amount, 0, 0), nil
+	case "month":
+		return dateTime.AddDate(0, amount, 0), nil
+	case "day":
+		return dateTime.AddDate(0, 0, amount), nil
+	case "hour":
+		return dateTime.Add(time.Duration(amount) * time.Hour), nil
+	default:
+		return nil, fmt.Errorf("error CertLogic plusTime unsupported unit=%v", operands[2])
+	}
+}
+
+//evaluateReduce fold the array with the lambda, the lambda sees the current item and the accumulator as
+//var current and var accumulator. A null array reduces to the initial value
+func evaluateReduce(operands []interface{}, data interface{}) (interface{}, error) {
+
+	if err := operandCount(opReduce, operands, 3, 3); err != nil {
+		return nil, err
+	}
+
+	value, err := Evaluate(operands[0], data)
+	if err != nil {
+		return nil, err
+	}
+	accumulator, err := Evaluate(operands[2], data)
+	if err != nil {
+		return nil, err
+	}
+
+	if value == nil {
+		return accumulator, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error CertLogic reduce first operand must be an array or null got=%T", value)
+	}
+
+	for _, current := range list {
+		accumulator, err = Evaluate(operands[1], map[string]interface{}{
+			"accumulator": accumulator,
+			"current":     current,
+			"data":        data,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return accumulator, nil
+}
+
+func evaluateNot(operands []interface{}, data interface{}) (interface{}, error) {
+
+	if err := operandCount(opNot, operands, 1, 1); err != nil {
+		return nil, err
+	}
+
+	value, err := Evaluate(operands[0], data)
+	if err != nil {
+		return nil, err
+	}
+
+	truthy, err := truthiness(opNot, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return !truthy, nil
+}
+
+//uvciSeparators the characters a UVCI is split on by extractFromUVCI
+var uvciSeparators = regexp.MustCompile(`[/#:]`)
+
+//evaluateExtractFromUVCI the fragment of the UVCI at the index, without the optional URN:UVCI: prefix, the UVCI
+//is split on / # and :. Null if the UVCI is null or there is no such fragment
+func evaluateExtractFromUVCI(operands []interface{}, data interface{}) (interface{}, error) {
+
+	if err := operandCount(opExtractFromUVCI, operands, 2, 2); err != nil {
+		return nil, err
+	}
+
+	value, err := Evaluate(operands[0], data)
+	if err != nil {
+		return nil, err
+	}
+	indexF, ok := toInteger(operands[1])
+	if !ok {
+		return nil, fmt.Errorf("error CertLogic extractFromUVCI index must be an integer literal got=%v", operands[1])
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+	uvci, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("error CertLogic extractFromUVCI operand must be a string or null got=%T", value)
+	}
+
+	const prefix = "URN:UVCI:"
+	if strings.HasPrefix(uvci, prefix) {
+		uvci = uvci[len(prefix):]
+	}
+
+	fragments := uvciSeparators.Split(uvci, -1)
+	index := int(indexF)
+	if index < 0 || index >= len(fragments) {
+		return nil, nil
+	}
+
+	return fragments[index], nil
+}
+
+//dateTimeFormat a date, or a date-time with an optional fraction and an optional offset of the form Z, +hh,
+//+hhmm or +hh:mm
+var dateTimeFormat = regexp.MustCompile(
+	`^(\d{4}-\d{2}-\d{2})(?:T(\d{2}:\d{2}:\d{2})(\.\d+)?(Z|[+-]\d{2}(?::?\d{2})?)?)?$`)
+
+//ParseDateTime parse a CertLogic date or date-time, a date is midnight UTC and a date-time without an offset
+//is UTC
+func ParseDateTime(value string) (time.Time, error) {
+
+	match := dateTimeFormat.FindStringSubmatch(value)
+	if match == nil {
+		return time.Time{}, fmt.Errorf("error CertLogic not a date or date-time value=%s", value)
+	}
+
+	clock, fraction, offset := match[2], match[3], match[4]
+	if clock == "" {
+		clock = "00:00:00"
+	}
+	switch {
+	case offset == "" || offset == "Z":
+		offset = "+00:00"
+	case len(offset) == 3:
+		offset += ":00"
+	case len(offset) == 5:
+		offset = offset[:3] + ":" + offset[3:]
+	}
+
+	dateTime, err := time.Parse(time.RFC3339Nano, match[1]+"T"+clock+fraction+offset)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error CertLogic not a date or date-time value=%s err=%s", value, err)
+	}
+
+	return dateTime, nil
+}
+
+func evaluateAll(operands []interface{}, data interface{}) ([]interface{}, error) {
+
+	values := make([]interface{}, len(operands))
+	for i, operand := range operands {
+		value, err := Evaluate(operand, data)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+//operandCount check the number of operands, max -1 for no maximum
+func operandCount(operator string, operands []interface{}, min int, max int) error {
+
+	if len(operands) < min || (max >= 0 && len(operands) > max) {
+		return fmt.Errorf("error CertLogic %s wrong number of operands got=%d", operator, len(operands))
+	}
+
+	return nil
+}
+
+//truthiness CertLogic truthy values are true, a non empty string, array or object and a non zero integer, the
+//falsy values are false, null, the empty string, array or object and 0. Anything else such as a date-time is an
+//error
+func truthiness(operator string, value interface{}) (bool, error) {
+
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	case string:
+		return v != "", nil
+	case float64:
+		if _, ok := toInteger(v); ok {
+			return v != 0, nil
+		}
+	case []interface{}:
+		return len(v) != 0, nil
+	case map[string]interface{}:
+		return len(v) != 0, nil
+	}
+
+	return false, fmt.Errorf("error CertLogic %s operand is neither truthy nor falsy value=%v", operator, value)
+}
+
+//strictEquals literal values of the same type that are equal, date-times at the same instant
+func strictEquals(left interface{}, right interface{}) bool {
+
+	leftTime, leftIsTime := left.(time.Time)
+	rightTime, rightIsTime := right.(time.Time)
+	if leftIsTime || rightIsTime {
+		return leftIsTime && rightIsTime && leftTime.Equal(rightTime)
+	}
+
+	switch left.(type) {
+	case []interface{}, map[string]interface{}:
+		return false
+	}
+
+	return reflect.DeepEqual(left, right)
+}
+
+func toInteger(value interface{}) (float64, bool) {
+
+	switch v := value.(type) {
+	case float64:
+		return v, v == math.Trunc(v) && !math.IsInf(v, 0)
+	case int:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func compare(operator string, left float64, right float64) bool {
+
+	switch operator {
+	case opLess:
+		return left < right
+	case opGreater:
+		return left > right
+	case opLessOrEqual:
+		return left <= right
+	default:
+		return left >= right
+	}
+}
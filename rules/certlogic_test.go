@@ -0,0 +1,138 @@
+package rules_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/rules"
+)
+
+const certLogicData = `{
+  "payload": {
+    "v": [{"dn": 2, "sd": 2, "dt": "2021-05-29", "mp": "EU/1/20/1507", "ci": "URN:UVCI:01:DE:IZ12345A/5CWLU12RNOB9RXSEOP6FG8#W"}],
+    "t": null,
+    "nam": {"fn": "Mustermann"}
+  },
+  "external": {"validationClock": "2021-06-12T10:00:00Z", "countryCode": "DE"}
+}`
+
+func Test_CertLogic_Evaluate(t *testing.T) {
+
+	type testCase struct {
+		name string
+
+		logic string
+
+		expected    interface{}
+		expectedErr bool
+	}
+
+	testCases := []testCase{
+		{name: "should return a literal", logic: `"DE"`, expected: "DE"},
+		{name: "should get a var", logic: `{"var": "external.countryCode"}`, expected: "DE"},
+		{name: "should index an array", logic: `{"var": "payload.v.0.dn"}`, expected: float64(2)},
+		{name: "should be null for a missing var", logic: `{"var": "payload.r.0.fr"}`, expected: nil},
+		{name: "should be null out of bounds", logic: `{"var": "payload.v.1"}`, expected: nil},
+		{name: "should need a string var", logic: `{"var": 1}`, expectedErr: true},
+		{name: "should strictly equal", logic: `{"===": [{"var": "payload.v.0.dn"}, 2]}`, expected: true},
+		{name: "should not equal another type", logic: `{"===": [{"var": "payload.v.0.dn"}, "2"]}`, expected: false},
+		{name: "should take the if branch", logic: `{"if": [{"var": "payload.v.0"}, "v", "none"]}`, expected: "v"},
+		{name: "should take the else branch", logic: `{"if": [{"var": "payload.t"}, "t", "none"]}`, expected: "none"},
+		{name: "should need three if operands", logic: `{"if": [true, 1]}`, expectedErr: true},
+		{name: "should and to the last truthy", logic: `{"and": [true, 1, "x"]}`, expected: "x"},
+		{name: "should and to the first falsy", logic: `{"and": [1, 0, {"var": 1}]}`, expected: float64(0)},
+		{name: "should not", logic: `{"!": [{"var": "payload.t"}]}`, expected: true},
+		{name: "should compare integers", logic: `{"<": [1, {"var": "payload.v.0.dn"}]}`, expected: true},
+		{name: "should compare between", logic: `{"<=": [1, {"var": "payload.v.0.dn"}, 1]}`, expected: false},
+		{name: "should only compare integers", logic: `{">": ["2", 1]}`, expectedErr: true},
+		{name: "should add integers", logic: `{"+": [{"var": "payload.v.0.dn"}, 1]}`, expected: float64(3)},
+		{name: "should be in", logic: `{"in": [{"var": "payload.v.0.mp"}, ["EU/1/20/1528", "EU/1/20/1507"]]}`, expected: true},
+		{name: "should need an array for in", logic: `{"in": ["a", "abc"]}`, expectedErr: true},
+		{
+			name:     "should be 14 days after the last dose",
+			logic:    `{"not-before": [{"plusTime": [{"var": "external.validationClock"}, 0, "day"]}, {"plusTime": [{"var": "payload.v.0.dt"}, 14, "day"]}]}`,
+			expected: true,
+		},
+		{
+			name:     "should not be 15 days after the last dose",
+			logic:    `{"after": [{"plusTime": [{"var": "external.validationClock"}, 0, "day"]}, {"plusTime": [{"var": "payload.v.0.dt"}, 15, "day"]}]}`,
+			expected: false,
+		},
+		{
+			name:     "should compare months and years",
+			logic:    `{"before": [{"plusTime": ["2021-01-31", 1, "month"]}, {"plusTime": ["2020-03-05", 1, "year"]}]}`,
+			expected: true,
+		},
+		{
+			name:     "should compare hours with offsets",
+			logic:    `{"not-after": [{"plusTime": ["2021-06-01T12:00:00+02:00", 2, "hour"]}, {"plusTime": ["2021-06-01T12:00:00Z", 0, "hour"]}]}`,
+			expected: true,
+		},
+		{name: "should only compare date-times", logic: `{"after": ["2021-06-01", "2021-05-01"]}`, expectedErr: true},
+		{name: "should need a unit", logic: `{"plusTime": ["2021-06-01", 1, "week"]}`, expectedErr: true},
+		{name: "should need a date", logic: `{"plusTime": ["June", 1, "day"]}`, expectedErr: true},
+		{
+			name:     "should count the groups",
+			logic:    `{"reduce": [[{"var": "payload.r"}, {"var": "payload.t"}, {"var": "payload.v"}], {"+": [{"var": "accumulator"}, {"if": [{"var": "current.0"}, 1, 0]}]}, 0]}`,
+			expected: float64(1),
+		},
+		{name: "should reduce null to the initial", logic: `{"reduce": [{"var": "payload.t"}, {"var": "current"}, 5]}`, expected: float64(5)},
+		{name: "should extract from a UVCI", logic: `{"extractFromUVCI": [{"var": "payload.v.0.ci"}, 2]}`, expected: "IZ12345A"},
+		{name: "should extract null out of range", logic: `{"extractFromUVCI": [{"var": "payload.v.0.ci"}, 9]}`, expected: nil},
+		{name: "should not support another operator", logic: `{"==": [1, 1]}`, expectedErr: true},
+		{name: "should have one operator", logic: `{"===": [1, 1], "in": [1, [1]]}`, expectedErr: true},
+	}
+
+	var data interface{}
+	require.NoError(t, json.Unmarshal([]byte(certLogicData), &data))
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var logic interface{}
+			require.NoError(t, json.Unmarshal([]byte(tc.logic), &logic))
+
+			result, err := rules.Evaluate(logic, data)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func Test_CertLogic_ParseDateTime(t *testing.T) {
+
+	type testCase struct {
+		value       string
+		expected    time.Time
+		expectedErr bool
+	}
+
+	testCases := []testCase{
+		{value: "2021-05-29", expected: time.Date(2021, 5, 29, 0, 0, 0, 0, time.UTC)},
+		{value: "2021-05-29T10:20:30", expected: time.Date(2021, 5, 29, 10, 20, 30, 0, time.UTC)},
+		{value: "2021-05-29T10:20:30.5Z", expected: time.Date(2021, 5, 29, 10, 20, 30, 5e8, time.UTC)},
+		{value: "2021-05-29T10:20:30+02", expected: time.Date(2021, 5, 29, 8, 20, 30, 0, time.UTC)},
+		{value: "2021-05-29T10:20:30+0200", expected: time.Date(2021, 5, 29, 8, 20, 30, 0, time.UTC)},
+		{value: "2021-05-29T10:20:30-01:30", expected: time.Date(2021, 5, 29, 11, 50, 30, 0, time.UTC)},
+		{value: "2021-05", expectedErr: true},
+		{value: "29/05/2021", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			dateTime, err := rules.ParseDateTime(tc.value)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, tc.expected.Equal(dateTime), "got=%s", dateTime)
+		})
+	}
+}
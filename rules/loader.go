@@ -0,0 +1,206 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/webshield-dev/eudvcdecoder/datamodel"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+)
+
+//
+// Load business rules in the EU gateway rule format from a local directory, for example a copy of the
+// gateway /rules/<country> downloads or the https://github.com/ehn-dcc-development/dgc-business-rules
+// rules directory. Each .json file is either a single rule or a list of rules, other JSON files such as
+// rule tests are skipped
+//
+
+//RuleSet the rules loaded, all countries and all versions
+type RuleSet struct {
+	Rules []*Rule
+}
+
+//LoadRuleSet load every rule under the directory, or in the file
+func LoadRuleSet(path string) (*RuleSet, error) {
+
+	path = os.ExpandEnv(path)
+	ruleSet := &RuleSet{}
+
+	err := filepath.WalkDir(path, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(filePath), ".json") {
+			return nil
+		}
+
+		fileRules, err := readRules(filePath)
+		if err != nil {
+			return err
+		}
+		ruleSet.Rules = append(ruleSet.Rules, fileRules...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading rules path=%s err=%s", path, err)
+	}
+
+	return ruleSet, nil
+}
+
+//readRules the rules in a file, a file that is not a rule or a list of rules has none
+func readRules(path string) ([]*Rule, error) {
+
+	data, err := helper.ReadData(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileRules []*Rule
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) != 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &fileRules)
+	} else {
+		rule := &Rule{}
+		err = json.Unmarshal(trimmed, rule)
+		fileRules = []*Rule{rule}
+	}
+	if err != nil {
+		//only an error if it looks like a rule
+		if bytes.Contains(trimmed, []byte(`"Identifier"`)) {
+			return nil, fmt.Errorf("error reading rule file=%s err=%s", path, err)
+		}
+		return nil, nil
+	}
+
+	var loaded []*Rule
+	for _, rule := range fileRules {
+		if rule == nil || rule.Identifier == "" || rule.Logic == nil {
+			continue
+		}
+		loaded = append(loaded, rule)
+	}
+
+	return loaded, nil
+}
+
+//Select the rules to evaluate for a DCC arriving in the country, the acceptance rules of the arrival country
+//and the invalidation rules of the issuing country, for the DCC certificate type, that are valid at the
+//validation clock. Only the newest version of each rule that is valid is selected. The rules are sorted by
+//Identifier
+func (rs *RuleSet) Select(arrivalCountry string, issuerCountry string, dcc *datamodel.DCC,
+	validationClock time.Time) []*Rule {
+
+	certType := groupCertificateTypes[dcc.Group()]
+
+	newest := make(map[string]*Rule)
+	for _, rule := range rs.Rules {
+
+		switch {
+		case strings.EqualFold(rule.Type, RuleTypeAcceptance):
+			if !strings.EqualFold(rule.Country, arrivalCountry) {
+				continue
+			}
+		case strings.EqualFold(rule.Type, RuleTypeInvalidation):
+			if issuerCountry == "" || !strings.EqualFold(rule.Country, issuerCountry) {
+				continue
+			}
+		default:
+			continue
+		}
+
+		if rule.Engine != "" && !strings.EqualFold(rule.Engine, EngineCertLogic) {
+			continue
+		}
+		if !strings.EqualFold(rule.CertificateType, CertificateTypeGeneral) &&
+			!strings.EqualFold(rule.CertificateType, certType) {
+			continue
+		}
+		if !rule.ValidAt(validationClock) {
+			continue
+		}
+
+		key := strings.ToUpper(rule.Type) + "/" + rule.Identifier
+		if current, ok := newest[key]; !ok || compareVersions(rule.Version, current.Version) > 0 {
+			newest[key] = rule
+		}
+	}
+
+	selected := make([]*Rule, 0, len(newest))
+	for _, rule := range newest {
+		selected = append(selected, rule)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].Identifier < selected[j].Identifier
+	})
+
+	return selected
+}
+
+//Countries the countries with acceptance rules
+func (rs *RuleSet) Countries() []string {
+
+	seen := make(map[string]bool)
+	var countries []string
+	for _, rule := range rs.Rules {
+		country := strings.ToUpper(rule.Country)
+		if strings.EqualFold(rule.Type, RuleTypeAcceptance) && !seen[country] {
+			seen[country] = true
+			countries = append(countries, country)
+		}
+	}
+	sort.Strings(countries)
+
+	return countries
+}
+
+//ValidAt true if the rule is in effect at the time, from ValidFrom up to but not including ValidTo. A zero
+//ValidTo has no end
+func (r *Rule) ValidAt(t time.Time) bool {
+
+	if t.Before(r.ValidFrom) {
+		return false
+	}
+
+	return r.ValidTo.IsZero() || t.Before(r.ValidTo)
+}
+
+//groupCertificateTypes the rule CertificateType of each DCC group
+var groupCertificateTypes = map[datamodel.DCCGroup]string{
+	datamodel.DCCGroupVaccination: CertificateTypeVaccination,
+	datamodel.DCCGroupTest:        CertificateTypeTest,
+	datamodel.DCCGroupRecovery:    CertificateTypeRecovery,
+}
+
+//compareVersions compare semantic versions such as 1.0.10 numerically, -1 if a is older, 0 if the same and
+//1 if newer
+func compareVersions(a string, b string) int {
+
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart int
+		if i < len(aParts) {
+			aPart, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bPart, _ = strconv.Atoi(bParts[i])
+		}
+		switch {
+		case aPart < bPart:
+			return -1
+		case aPart > bPart:
+			return 1
+		}
+	}
+
+	return 0
+}
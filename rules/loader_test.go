@@ -0,0 +1,152 @@
+package rules_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+	"github.com/webshield-dev/eudvcdecoder/rules"
+)
+
+func Test_LoadRuleSet_Select(t *testing.T) {
+
+	ruleSet, err := rules.LoadRuleSet("../testfiles/rules")
+	require.NoError(t, err)
+	require.Len(t, ruleSet.Rules, 7, "should skip the rule tests file")
+	require.Equal(t, []string{"AT", "DE"}, ruleSet.Countries())
+
+	vcDecoder := helper.NewDecoder(false, false)
+	vaccination, err := vcDecoder.FromFileQRCode("../testfiles/dcc-testdata/DE/2DCode/png/1.png")
+	require.NoError(t, err)
+	test, err := vcDecoder.FromFileQRCode("../testfiles/dcc-testdata/DE/2DCode/png/2.png")
+	require.NoError(t, err)
+
+	type testCase struct {
+		name string
+
+		arrivalCountry  string
+		issuerCountry   string
+		output          *helper.Output
+		validationClock time.Time
+
+		expectedRules []string
+	}
+
+	testCases := []testCase{
+		{
+			name:            "should select the DE vaccination rules and the DE invalidation rules",
+			arrivalCountry:  "DE",
+			issuerCountry:   "DE",
+			output:          vaccination,
+			validationClock: time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC),
+			expectedRules:   []string{"GR-DE-0001@1.0.0", "IR-DE-0001@1.0.0", "VR-DE-0001@1.0.0", "VR-DE-0002@1.0.0"},
+		},
+		{
+			name:            "should select the newest version valid at the validation clock",
+			arrivalCountry:  "de",
+			output:          vaccination,
+			validationClock: time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC),
+			expectedRules:   []string{"GR-DE-0001@1.0.0", "VR-DE-0001@1.0.1", "VR-DE-0002@1.0.0"},
+		},
+		{
+			name:            "should select the DE test rules",
+			arrivalCountry:  "DE",
+			output:          test,
+			validationClock: time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC),
+			expectedRules:   []string{"GR-DE-0001@1.0.0", "TR-DE-0001@1.0.0"},
+		},
+		{
+			name:            "should select the AT rules and the issuer invalidation rules",
+			arrivalCountry:  "AT",
+			issuerCountry:   "DE",
+			output:          vaccination,
+			validationClock: time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC),
+			expectedRules:   []string{"IR-DE-0001@1.0.0", "VR-AT-0001@1.0.0"},
+		},
+		{
+			name:            "should select nothing before the rules are valid",
+			arrivalCountry:  "DE",
+			output:          vaccination,
+			validationClock: time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var selected []string
+			for _, rule := range ruleSet.Select(tc.arrivalCountry, tc.issuerCountry, tc.output.DCC(), tc.validationClock) {
+				selected = append(selected, rule.Identifier+"@"+rule.Version)
+			}
+			require.Equal(t, tc.expectedRules, selected)
+		})
+	}
+}
+
+func Test_LoadRuleSet_Evaluate(t *testing.T) {
+
+	ruleSet, err := rules.LoadRuleSet("../testfiles/rules")
+	require.NoError(t, err)
+
+	vsMapper, err := helper.NewValueSetMapper("../valuesetdata")
+	require.NoError(t, err)
+
+	vaccination, err := helper.NewDecoder(false, false).FromFileQRCode("../testfiles/dcc-testdata/DE/2DCode/png/1.png")
+	require.NoError(t, err)
+
+	type testCase struct {
+		name string
+
+		validationClock time.Time
+		expectedFailed  []string
+	}
+
+	testCases := []testCase{
+		{name: "should pass 14 days after the last dose", validationClock: time.Date(2021, 6, 12, 0, 0, 0, 0, time.UTC)},
+		{
+			name:            "should fail 13 days after the last dose",
+			validationClock: time.Date(2021, 6, 11, 0, 0, 0, 0, time.UTC),
+			expectedFailed:  []string{"VR-DE-0001"},
+		},
+		{
+			name:            "should fail the newer version more than 365 days after the last dose",
+			validationClock: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+			expectedFailed:  []string{"VR-DE-0001"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			selected := ruleSet.Select("DE", "DE", vaccination.DCC(), tc.validationClock)
+			results, err := rules.EvaluateRules(selected, vaccination.DCC(), &rules.External{
+				ValidationClock: tc.validationClock,
+				ValueSets:       vsMapper.ValueSets(),
+				CountryCode:     "DE",
+			}, "de")
+			require.NoError(t, err)
+			require.Empty(t, rules.Open(results))
+
+			var failed []string
+			for _, result := range rules.Failed(results) {
+				failed = append(failed, result.Rule.Identifier)
+			}
+			require.Equal(t, tc.expectedFailed, failed)
+		})
+	}
+}
+
+func Test_LoadRuleSet_Errors(t *testing.T) {
+
+	_, err := rules.LoadRuleSet("../testfiles/rules/none")
+	require.Error(t, err, "should need the directory")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bad.json"),
+		[]byte(`{"Identifier": "VR-XX-0001", "ValidFrom": "1 June 2021"}`), 0600))
+	_, err = rules.LoadRuleSet(dir)
+	require.Error(t, err, "should report a rule that cannot be read")
+}
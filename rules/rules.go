@@ -0,0 +1,271 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/webshield-dev/eudvcdecoder/datamodel"
+)
+
+//
+// Business rules decide if a DCC is accepted, for example by a country of arrival, see
+// https://github.com/ehn-dcc-development/dgc-business-rules. A rule is a CertLogic expression evaluated against
+//
+//  {
+//    "payload": <the DCC as JSON>,
+//    "external": {"validationClock", "valueSets", "countryCode", "exp", "iat"}
+//  }
+//
+// A rule passes if it evaluates to true, fails if false, and is open if it cannot be evaluated or does not
+// evaluate to a boolean
+//
+
+//Rule types
+const (
+	//RuleTypeAcceptance a rule set by the country of arrival
+	RuleTypeAcceptance = "Acceptance"
+
+	//RuleTypeInvalidation a rule set by the issuing country to invalidate certificates
+	RuleTypeInvalidation = "Invalidation"
+)
+
+//Outcome the result of evaluating a rule
+type Outcome string
+
+const (
+	//OutcomePassed the rule evaluated to true
+	OutcomePassed Outcome = "passed"
+
+	//OutcomeFailed the rule evaluated to false
+	OutcomeFailed Outcome = "failed"
+
+	//OutcomeOpen the rule could not be evaluated, or did not evaluate to a boolean
+	OutcomeOpen Outcome = "open"
+)
+
+//EngineCertLogic the only rule engine supported
+const EngineCertLogic = "CERTLOGIC"
+
+//Rule certificate types
+const (
+	//CertificateTypeGeneral the rule applies to all DCCs
+	CertificateTypeGeneral = "General"
+
+	//CertificateTypeVaccination the rule applies to a DCC with a vaccination entry
+	CertificateTypeVaccination = "Vaccination"
+
+	//CertificateTypeTest the rule applies to a DCC with a test entry
+	CertificateTypeTest = "Test"
+
+	//CertificateTypeRecovery the rule applies to a DCC with a recovery entry
+	CertificateTypeRecovery = "Recovery"
+)
+
+//DefaultLanguage the description language used when the requested one is not available
+const DefaultLanguage = "en"
+
+//Description a rule description in a language
+type Description struct {
+	Lang string `json:"lang"`
+	Desc string `json:"desc"`
+}
+
+//Rule a business rule, the JSON names are as in the EU gateway rule format
+type Rule struct {
+
+	//Identifier such as GR-DE-0001
+	Identifier string `json:"Identifier"`
+
+	//Type RuleTypeAcceptance or RuleTypeInvalidation
+	Type string `json:"Type"`
+
+	//Country the country that set the rule, the country of arrival for an acceptance rule and the issuing
+	//country for an invalidation rule
+	Country string `json:"Country"`
+
+	//Region optional region of the country the rule is for
+	Region string `json:"Region,omitempty"`
+
+	//Version the semantic version of the rule, a newer version of the same Identifier replaces an older one
+	Version string `json:"Version"`
+
+	//SchemaVersion the DCC schema version the rule was written for
+	SchemaVersion string `json:"SchemaVersion"`
+
+	//Engine the rule engine, EngineCertLogic
+	Engine string `json:"Engine"`
+
+	//EngineVersion the version of the rule engine
+	EngineVersion string `json:"EngineVersion"`
+
+	//CertificateType which DCCs the rule applies to, one of the CertificateType constants
+	CertificateType string `json:"CertificateType"`
+
+	//ValidFrom when the rule takes effect
+	ValidFrom time.Time `json:"ValidFrom"`
+
+	//ValidTo when the rule stops being used
+	ValidTo time.Time `json:"ValidTo"`
+
+	//Description what the rule checks in one or more languages
+	Description []*Description `json:"Description"`
+
+	//AffectedFields the DCC fields the rule uses, such as t.0.sc
+	AffectedFields []string `json:"AffectedFields"`
+
+	//Logic the CertLogic expression
+	Logic interface{} `json:"Logic"`
+}
+
+//DescriptionFor the description in the language, otherwise in the DefaultLanguage, otherwise the first
+func (r *Rule) DescriptionFor(lang string) string {
+
+	for _, wanted := range []string{lang, DefaultLanguage} {
+		for _, description := range r.Description {
+			if description != nil && description.Lang == wanted {
+				return description.Desc
+			}
+		}
+	}
+
+	if len(r.Description) != 0 && r.Description[0] != nil {
+		return r.Description[0].Desc
+	}
+
+	return ""
+}
+
+//External the values from outside the DCC the rules can use
+type External struct {
+
+	//ValidationClock the time to check the DCC is valid at
+	ValidationClock time.Time
+
+	//ValueSets the codes in each value set by value set id, such as vaccines-covid-19-names
+	ValueSets map[string][]string
+
+	//CountryCode the country of arrival, such as DE
+	CountryCode string
+
+	//Exp the CWT exp
+	Exp time.Time
+
+	//Iat the CWT iat
+	Iat time.Time
+}
+
+//Result the outcome of a rule
+type Result struct {
+	Rule    *Rule
+	Outcome Outcome
+
+	//Description the rule description in the requested language
+	Description string
+
+	//Err why the rule is open, nil if it passed or failed
+	Err error
+}
+
+//EvaluateRules evaluate each rule against the DCC, the descriptions are in the language lang. An error only
+//if the DCC cannot be made into the CertLogic payload
+func EvaluateRules(rules []*Rule, dcc *datamodel.DCC, external *External, lang string) ([]*Result, error) {
+
+	data, err := certLogicData(dcc, external)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*Result, 0, len(rules))
+	for _, rule := range rules {
+		if rule == nil {
+			continue
+		}
+
+		result := &Result{Rule: rule, Description: rule.DescriptionFor(lang)}
+		value, err := Evaluate(rule.Logic, data)
+		switch {
+		case err != nil:
+			result.Outcome = OutcomeOpen
+			result.Err = fmt.Errorf("error evaluating rule=%s err=%s", rule.Identifier, err)
+		case value == true:
+			result.Outcome = OutcomePassed
+		case value == false:
+			result.Outcome = OutcomeFailed
+		default:
+			result.Outcome = OutcomeOpen
+			result.Err = fmt.Errorf("error rule=%s did not evaluate to a boolean value=%v", rule.Identifier, value)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+//Failed the results that failed
+func Failed(results []*Result) []*Result {
+	return withOutcome(results, OutcomeFailed)
+}
+
+//Open the results that are open
+func Open(results []*Result) []*Result {
+	return withOutcome(results, OutcomeOpen)
+}
+
+func withOutcome(results []*Result, outcome Outcome) []*Result {
+	var matching []*Result
+	for _, result := range results {
+		if result.Outcome == outcome {
+			matching = append(matching, result)
+		}
+	}
+	return matching
+}
+
+//certLogicData the payload and external data, as JSON values so the CertLogic var paths match the DCC JSON
+func certLogicData(dcc *datamodel.DCC, external *External) (map[string]interface{}, error) {
+
+	if dcc == nil {
+		return nil, fmt.Errorf("error evaluating rules no DCC")
+	}
+	if external == nil {
+		external = &External{}
+	}
+
+	dccB, err := json.Marshal(dcc)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding DCC for rules err=%s", err)
+	}
+	var payload interface{}
+	if err := json.Unmarshal(dccB, &payload); err != nil {
+		return nil, fmt.Errorf("error decoding DCC for rules err=%s", err)
+	}
+
+	valueSets := make(map[string]interface{}, len(external.ValueSets))
+	for id, codes := range external.ValueSets {
+		values := make([]interface{}, len(codes))
+		for i, code := range codes {
+			values[i] = code
+		}
+		valueSets[id] = values
+	}
+
+	return map[string]interface{}{
+		"payload": payload,
+		"external": map[string]interface{}{
+			"validationClock": formatTime(external.ValidationClock),
+			"valueSets":       valueSets,
+			"countryCode":     external.CountryCode,
+			"exp":             formatTime(external.Exp),
+			"iat":             formatTime(external.Iat),
+		},
+	}, nil
+}
+
+//formatTime RFC3339 or null if not set
+func formatTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Format(time.RFC3339)
+}
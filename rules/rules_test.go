@@ -0,0 +1,130 @@
+package rules_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/datamodel"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+	"github.com/webshield-dev/eudvcdecoder/rules"
+)
+
+const testRules = `[
+  {
+    "Identifier": "VR-EU-0002",
+    "Type": "Acceptance",
+    "Description": [{"lang": "en", "desc": "Only vaccines in the allowed valueset that have been approved by the EMA are allowed."},
+                    {"lang": "de", "desc": "Nur von der EMA zugelassene Impfstoffe sind erlaubt."}],
+    "AffectedFields": ["v.0", "v.0.mp"],
+    "Logic": {"if": [{"var": "payload.v.0"}, {"in": [{"var": "payload.v.0.mp"}, {"var": "external.valueSets.vaccines-covid-19-names"}]}, true]}
+  },
+  {
+    "Identifier": "VR-EU-0003",
+    "Type": "Acceptance",
+    "Description": [{"lang": "en", "desc": "At least 14 days must have passed since the last dose."}],
+    "AffectedFields": ["v.0", "v.0.dt"],
+    "Logic": {"if": [{"var": "payload.v.0"}, {"not-before": [{"plusTime": [{"var": "external.validationClock"}, 0, "day"]}, {"plusTime": [{"var": "payload.v.0.dt"}, 14, "day"]}]}, true]}
+  },
+  {
+    "Identifier": "GR-EU-0001",
+    "Type": "Acceptance",
+    "Description": [{"lang": "en", "desc": "The DCC must contain exactly one group."}],
+    "AffectedFields": ["r", "t", "v"],
+    "Logic": {"===": [{"reduce": [[{"var": "payload.r"}, {"var": "payload.t"}, {"var": "payload.v"}], {"+": [{"var": "accumulator"}, {"if": [{"var": "current.0"}, 1, 0]}]}, 0]}, 1]}
+  },
+  {
+    "Identifier": "GR-XX-0001",
+    "Type": "Acceptance",
+    "Description": [{"lang": "en", "desc": "Date of birth compared as a date, not valid CertLogic."}],
+    "AffectedFields": ["dob"],
+    "Logic": {"after": [{"var": "payload.dob"}, "1900-01-01"]}
+  },
+  {
+    "Identifier": "GR-XX-0002",
+    "Type": "Acceptance",
+    "Description": [{"lang": "en", "desc": "Not a boolean."}],
+    "AffectedFields": ["nam.fn"],
+    "Logic": {"var": "payload.nam.fn"}
+  }
+]`
+
+func Test_EvaluateRules(t *testing.T) {
+
+	var testRuleList []*rules.Rule
+	require.NoError(t, json.Unmarshal([]byte(testRules), &testRuleList))
+
+	decodeOutput, err := helper.NewDecoder(false, false).FromFileQRCode("../testfiles/dcc-testdata/DE/2DCode/png/1.png")
+	require.NoError(t, err)
+
+	vsMapper, err := helper.NewValueSetMapper("../valuesetdata")
+	require.NoError(t, err)
+
+	type testCase struct {
+		name string
+
+		validationClock time.Time
+		lang            string
+
+		expectedOutcomes    []rules.Outcome
+		expectedDescription string
+	}
+
+	testCases := []testCase{
+		{
+			name:            "should fail the waiting period 13 days after the last dose",
+			validationClock: time.Date(2021, 6, 11, 10, 0, 0, 0, time.UTC),
+			expectedOutcomes: []rules.Outcome{rules.OutcomePassed, rules.OutcomeFailed, rules.OutcomePassed,
+				rules.OutcomeOpen, rules.OutcomeOpen},
+			expectedDescription: "Only vaccines in the allowed valueset that have been approved by the EMA are allowed.",
+		},
+		{
+			name:            "should pass the waiting period 14 days after the last dose",
+			validationClock: time.Date(2021, 6, 12, 0, 0, 0, 0, time.UTC),
+			lang:            "de",
+			expectedOutcomes: []rules.Outcome{rules.OutcomePassed, rules.OutcomePassed, rules.OutcomePassed,
+				rules.OutcomeOpen, rules.OutcomeOpen},
+			expectedDescription: "Nur von der EMA zugelassene Impfstoffe sind erlaubt.",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			results, err := rules.EvaluateRules(testRuleList, decodeOutput.DCC(), &rules.External{
+				ValidationClock: tc.validationClock,
+				ValueSets:       vsMapper.ValueSets(),
+				CountryCode:     "DE",
+			}, tc.lang)
+			require.NoError(t, err)
+
+			var outcomes []rules.Outcome
+			for _, result := range results {
+				outcomes = append(outcomes, result.Outcome)
+				require.Equal(t, result.Outcome == rules.OutcomeOpen, result.Err != nil, "%s", result.Rule.Identifier)
+			}
+			require.Equal(t, tc.expectedOutcomes, outcomes)
+			require.Equal(t, tc.expectedDescription, results[0].Description)
+			require.Len(t, rules.Open(results), 2)
+		})
+	}
+
+	_, err = rules.EvaluateRules(testRuleList, nil, nil, "")
+	require.Error(t, err, "should need a DCC")
+}
+
+func Test_EvaluateRules_Groups(t *testing.T) {
+
+	var testRuleList []*rules.Rule
+	require.NoError(t, json.Unmarshal([]byte(testRules), &testRuleList))
+	oneGroup := []*rules.Rule{testRuleList[2]}
+
+	dcc := &datamodel.DCC{
+		Vaccine:  []datamodel.Vaccine{{DN: 1, SD: 2}},
+		Recovery: []datamodel.Recovery{{}},
+	}
+	results, err := rules.EvaluateRules(oneGroup, dcc, nil, "")
+	require.NoError(t, err)
+	require.Len(t, rules.Failed(results), 1, "should fail with a vaccination and a recovery")
+}
@@ -0,0 +1,233 @@
+package schema
+
+import (
+	"bytes"
+	"embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//
+// Validate a DCC against the JSON schema for its version, see https://github.com/ehn-dcc-development/ehn-dcc-schema
+// The schemas are embedded, one per major.minor version, as patch releases only changed descriptions.
+//
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+//Versions the major.minor schema versions that are embedded, oldest first
+var Versions = []string{"1.0", "1.1", "1.2", "1.3"}
+
+//Violation a field that does not match the schema
+type Violation struct {
+
+	//Path JSON pointer to the field within the DCC, for example /v/0/dn
+	Path string `json:"path"`
+
+	//Message why the field is not valid
+	Message string `json:"message"`
+}
+
+//String path and message
+func (v *Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Path, v.Message)
+}
+
+//Result the outcome of validating a DCC
+type Result struct {
+
+	//Version the major.minor schema version the DCC was validated against
+	Version string
+
+	//Violations the fields that do not match the schema, empty if valid
+	Violations []*Violation
+}
+
+//Valid true if there were no violations
+func (r *Result) Valid() bool {
+	return len(r.Violations) == 0
+}
+
+//SchemaVersion the embedded schema version for a DCC ver, such as 1.0.4 uses 1.0. A version newer than the
+//embedded schemas uses the newest as minor versions are backwards compatible
+func SchemaVersion(dccVersion string) (string, error) {
+
+	parts := strings.Split(dccVersion, ".")
+	if len(parts) < 2 {
+		return "", fmt.Errorf("error DCC version must be major.minor.patch ver=%s", dccVersion)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("error DCC version major not a number ver=%s", dccVersion)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("error DCC version minor not a number ver=%s", dccVersion)
+	}
+
+	if major != 1 {
+		return "", fmt.Errorf("error unsupported DCC major version ver=%s", dccVersion)
+	}
+	if minor >= len(Versions) {
+		return Versions[len(Versions)-1], nil
+	}
+
+	return Versions[minor], nil
+}
+
+//Validate the DCC, either as JSON decoded or CBOR decoded, against the schema for its ver. Returns an error
+//if the DCC cannot be validated, such as no ver, the schema violations are in the result
+func Validate(dcc interface{}) (*Result, error) {
+
+	instance, err := toJSONValue(dcc)
+	if err != nil {
+		return nil, err
+	}
+
+	dccMap, ok := instance.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error DCC must be a map got=%T", dcc)
+	}
+	dccVersion, _ := dccMap["ver"].(string)
+
+	version, err := SchemaVersion(dccVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled, err := compiled(version)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Version: version}
+	if err := compiled.Validate(instance); err != nil {
+		validationErr, ok := err.(*jsonschema.ValidationError)
+		if !ok {
+			return nil, fmt.Errorf("error validating DCC err=%s", err)
+		}
+		result.Violations = violations(validationErr)
+	}
+
+	return result, nil
+}
+
+//compiledSchemas cache of version to *jsonschema.Schema, a compiled schema is safe for concurrent use
+var compiledSchemas sync.Map
+
+func compiled(version string) (*jsonschema.Schema, error) {
+
+	if cached, ok := compiledSchemas.Load(version); ok {
+		return cached.(*jsonschema.Schema), nil
+	}
+
+	name := "schemas/DCC.combined-schema-" + version + ".json"
+	schemaB, err := schemaFiles.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema version=%s err=%s", version, err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat = true
+	if err := compiler.AddResource(name, bytes.NewReader(schemaB)); err != nil {
+		return nil, fmt.Errorf("error adding schema version=%s err=%s", version, err)
+	}
+
+	compiledSchema, err := compiler.Compile(name)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling schema version=%s err=%s", version, err)
+	}
+	compiledSchemas.Store(version, compiledSchema)
+
+	return compiledSchema, nil
+}
+
+//violations the leaf causes are the field level violations
+func violations(validationErr *jsonschema.ValidationError) []*Violation {
+
+	seen := make(map[string]bool)
+	var result []*Violation
+
+	var walk func(ve *jsonschema.ValidationError)
+	walk = func(ve *jsonschema.ValidationError) {
+		if len(ve.Causes) == 0 {
+			v := &Violation{Path: ve.InstanceLocation, Message: ve.Message}
+			if v.Path == "" {
+				v.Path = "/"
+			}
+			if !seen[v.String()] {
+				seen[v.String()] = true
+				result = append(result, v)
+			}
+			return
+		}
+		for _, cause := range ve.Causes {
+			walk(cause)
+		}
+	}
+	walk(validationErr)
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+
+	return result
+}
+
+//toJSONValue converts a CBOR or JSON decoded value to the types the validator expects, CBOR maps have
+//interface{} keys and numbers are uint64 or int64 so round trip through JSON
+func toJSONValue(v interface{}) (interface{}, error) {
+
+	jsonB, err := json.Marshal(jsonCompatible(v))
+	if err != nil {
+		return nil, fmt.Errorf("error JSON encoding DCC err=%s", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonB))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("error JSON decoding DCC err=%s", err)
+	}
+
+	return value, nil
+}
+
+func jsonCompatible(v interface{}) interface{} {
+
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			m[fmt.Sprint(k)] = jsonCompatible(e)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			m[k] = jsonCompatible(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(value))
+		for i, e := range value {
+			s[i] = jsonCompatible(e)
+		}
+		return s
+	case []byte:
+		return base64.StdEncoding.EncodeToString(value)
+	case time.Time:
+		return value.Format(time.RFC3339)
+	case cbor.Tag:
+		return jsonCompatible(value.Content)
+	default:
+		return v
+	}
+}
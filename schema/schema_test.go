@@ -0,0 +1,155 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/schema"
+)
+
+const validVaccinationDCC = `{
+  "ver": "1.3.0",
+  "nam": {"fn": "Mustermann", "fnt": "MUSTERMANN", "gn": "Erika", "gnt": "ERIKA"},
+  "dob": "1964-08-12",
+  "v": [{"tg": "840539006", "vp": "1119349007", "mp": "EU/1/20/1507", "ma": "ORG-100031184", "dn": 2, "sd": 2,
+         "dt": "2021-05-29", "co": "DE", "is": "Robert Koch-Institut", "ci": "URN:UVCI:01DE/IZ12345A/5CWLU12RNOB9RXSEOP6FG8#W"}]
+}`
+
+func Test_Validate(t *testing.T) {
+
+	type testCase struct {
+		name string
+
+		//change modifies the valid DCC
+		change func(dcc map[string]interface{})
+
+		expectedVersion string
+		expectedPaths   []string
+	}
+
+	vaccine := func(dcc map[string]interface{}) map[string]interface{} {
+		return dcc["v"].([]interface{})[0].(map[string]interface{})
+	}
+
+	testCases := []testCase{
+		{
+			name:            "should be valid",
+			change:          func(dcc map[string]interface{}) {},
+			expectedVersion: "1.3",
+		},
+		{
+			name:            "should report dn not an integer",
+			change:          func(dcc map[string]interface{}) { vaccine(dcc)["dn"] = 1.5 },
+			expectedVersion: "1.3",
+			expectedPaths:   []string{"/v/0/dn"},
+		},
+		{
+			name: "should report fnt containing lowercase",
+			change: func(dcc map[string]interface{}) {
+				dcc["nam"].(map[string]interface{})["fnt"] = "Mustermann"
+			},
+			expectedVersion: "1.3",
+			expectedPaths:   []string{"/nam/fnt"},
+		},
+		{
+			name:            "should report a date that is not a full date",
+			change:          func(dcc map[string]interface{}) { vaccine(dcc)["dt"] = "2021-05" },
+			expectedVersion: "1.3",
+			expectedPaths:   []string{"/v/0/dt"},
+		},
+		{
+			name:            "should report a missing required field",
+			change:          func(dcc map[string]interface{}) { delete(vaccine(dcc), "ci") },
+			expectedVersion: "1.3",
+			expectedPaths:   []string{"/v/0"},
+		},
+		{
+			name: "should report more than one group in 1.3",
+			change: func(dcc map[string]interface{}) {
+				dcc["r"] = []interface{}{map[string]interface{}{"tg": "840539006", "fr": "2021-03-25", "co": "DE",
+					"is": "Robert Koch-Institut", "df": "2021-04-12", "du": "2021-06-01", "ci": "URN:UVCI:01DE/1"}}
+			},
+			expectedVersion: "1.3",
+			expectedPaths:   []string{"/"},
+		},
+		{
+			name:            "should use the 1.0 schema for a 1.0.x DCC",
+			change:          func(dcc map[string]interface{}) { dcc["ver"] = "1.0.4"; vaccine(dcc)["co"] = "" },
+			expectedVersion: "1.0",
+		},
+		{
+			name:            "should use the newest schema for a newer minor version",
+			change:          func(dcc map[string]interface{}) { dcc["ver"] = "1.4.0" },
+			expectedVersion: "1.3",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var dcc map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(validVaccinationDCC), &dcc))
+			tc.change(dcc)
+
+			result, err := schema.Validate(dcc)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedVersion, result.Version)
+
+			var paths []string
+			for _, violation := range result.Violations {
+				paths = append(paths, violation.Path)
+			}
+			require.Equal(t, tc.expectedPaths, paths, "violations=%v", result.Violations)
+			require.Equal(t, len(tc.expectedPaths) == 0, result.Valid())
+		})
+	}
+}
+
+func Test_Validate_CBOR(t *testing.T) {
+
+	var dcc map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(validVaccinationDCC), &dcc))
+	cborB, err := cbor.Marshal(dcc)
+	require.NoError(t, err)
+
+	//CBOR decodes into map[interface{}]interface{} with uint64 numbers
+	var cborDCC interface{}
+	require.NoError(t, cbor.Unmarshal(cborB, &cborDCC))
+
+	result, err := schema.Validate(cborDCC)
+	require.NoError(t, err)
+	require.True(t, result.Valid(), "violations=%v", result.Violations)
+}
+
+func Test_SchemaVersion(t *testing.T) {
+
+	type testCase struct {
+		name string
+
+		dccVersion      string
+		expectedVersion string
+		expectedErr     bool
+	}
+
+	testCases := []testCase{
+		{name: "should select by major.minor", dccVersion: "1.2.1", expectedVersion: "1.2"},
+		{name: "should select 1.0 for 1.0.4", dccVersion: "1.0.4", expectedVersion: "1.0"},
+		{name: "should select newest for newer minor", dccVersion: "1.9.0", expectedVersion: "1.3"},
+		{name: "should not support another major version", dccVersion: "2.0.0", expectedErr: true},
+		{name: "should not support an empty version", dccVersion: "", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			version, err := schema.SchemaVersion(tc.dccVersion)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedVersion, version)
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package trust
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/webshield-dev/eudvcdecoder/helper"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+//
+// A local trust store of EU DCC Document Signer Certificates (DSC), the certificates are indexed by
+// their KID so the verifier can find the key that signed a credential.
+//
+// Supported files
+//  - .pem, .crt, .cer one or more PEM encoded certificates, or a single DER certificate
+//  - .der a single DER encoded certificate
+//  - .json either
+//      - a list of {"kid", "country", "rawData"} entries, as in the DCCG /trustList/DSC format
+//      - an object with a "certificates" list of the same entries, as in the body of the German DSC list
+//      - a dcc-testdata raw file, the TESTCTX.CERTIFICATE is loaded
+//
+// see https://ec.europa.eu/health/sites/default/files/ehealth/docs/digital-green-certificates_v1_en.pdf
+// section 4 for the KID
+//
+
+//KIDLength the KID is the first 8 bytes of the SHA-256 of the DER encoded certificate
+const KIDLength = 8
+
+//KID calculate the key identifier for a DSC
+func KID(cert *x509.Certificate) []byte {
+	hash := sha256.Sum256(cert.Raw)
+	return hash[:KIDLength]
+}
+
+//NewStore make an empty trust store
+func NewStore() *Store {
+	return &Store{certs: make(map[string][]*storedCertificate)}
+}
+
+//LoadStore make a trust store from a directory or a single file
+func LoadStore(path string) (*Store, error) {
+
+	store := NewStore()
+
+	info, err := os.Stat(os.ExpandEnv(path))
+	if err != nil {
+		return nil, fmt.Errorf("error reading trust store path=%s err=%s", path, err)
+	}
+
+	if info.IsDir() {
+		err = store.LoadDir(path)
+	} else {
+		err = store.LoadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+//Store holds DSCs indexed by KID, it implements verifier.KeyResolver and is safe for concurrent use
+type Store struct {
+	mutex sync.RWMutex
+	certs map[string][]*storedCertificate //hex(kid) -> certificates
+}
+
+//storedCertificate a DSC and the country it is trusted for, empty if any country
+type storedCertificate struct {
+	cert    *x509.Certificate
+	country string
+}
+
+//AddCertificate add a certificate for the country in its subject, returns its kid. Adding the same certificate
+//twice is a no-op
+func (s *Store) AddCertificate(cert *x509.Certificate) []byte {
+	return s.AddCountryCertificate(cert, "")
+}
+
+//AddCountryCertificate add a certificate for the country it was published under, such as the country of a trust
+//list entry, returns its kid. If country is empty uses the country in the certificate subject. Adding the same
+//certificate for the same country twice is a no-op
+func (s *Store) AddCountryCertificate(cert *x509.Certificate, country string) []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if country == "" && len(cert.Subject.Country) != 0 {
+		country = cert.Subject.Country[0]
+	}
+
+	kid := KID(cert)
+	kidHex := hex.EncodeToString(kid)
+	for _, existing := range s.certs[kidHex] {
+		if existing.cert.Equal(cert) && strings.EqualFold(existing.country, country) {
+			return kid
+		}
+	}
+	s.certs[kidHex] = append(s.certs[kidHex], &storedCertificate{cert: cert, country: country})
+
+	return kid
+}
+
+//AddDER add a DER encoded certificate, returns its kid
+func (s *Store) AddDER(der []byte) ([]byte, error) {
+	return s.AddCountryDER(der, "")
+}
+
+//AddCountryDER add a DER encoded certificate for a country see AddCountryCertificate, returns its kid
+func (s *Store) AddCountryDER(der []byte, country string) ([]byte, error) {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing DSC certificate err=%s", err)
+	}
+
+	return s.AddCountryCertificate(cert, country), nil
+}
+
+//Len the number of certificates in the store
+func (s *Store) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	count := 0
+	for _, certs := range s.certs {
+		count += len(certs)
+	}
+
+	return count
+}
+
+//Certificates returns the certificates for a kid, nil if none
+func (s *Store) Certificates(kid []byte) []*x509.Certificate {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var certs []*x509.Certificate
+	for _, stored := range s.certs[hex.EncodeToString(kid)] {
+		certs = append(certs, stored.cert)
+	}
+
+	return certs
+}
+
+//ResolveKeys see verifier.KeyResolver, only matches certificates added for the country. A certificate added
+//with no country, and no country in its subject, matches any country
+func (s *Store) ResolveKeys(ctx context.Context, kid []byte, country string) ([]*verifier.SigningKey, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := make([]*verifier.SigningKey, 0)
+	for _, stored := range s.certs[hex.EncodeToString(kid)] {
+
+		if stored.country != "" && country != "" && !strings.EqualFold(stored.country, country) {
+			continue
+		}
+
+		result = append(result, &verifier.SigningKey{
+			Kid:         kid,
+			Country:     stored.country,
+			PublicKey:   stored.cert.PublicKey,
+			Certificate: stored.cert,
+		})
+	}
+
+	return result, nil
+}
+
+//LoadDir load all the supported files in a directory and its sub directories
+func (s *Store) LoadDir(path string) error {
+
+	return filepath.WalkDir(os.ExpandEnv(path), func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isSupportedFile(filePath) {
+			return nil
+		}
+
+		return s.LoadFile(filePath)
+	})
+}
+
+//LoadFile load the certificates in a file, see above for supported files
+func (s *Store) LoadFile(path string) error {
+
+	data, err := helper.ReadData(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+
+	case ".json":
+		err = s.loadJSON(data)
+
+	case ".der":
+		_, err = s.AddDER(data)
+
+	case ".pem", ".crt", ".cer":
+		err = s.loadPEM(data)
+
+	default:
+		err = fmt.Errorf("error unsupported trust store file extension")
+	}
+
+	if err != nil {
+		return fmt.Errorf("error loading trust store file=%s err=%s", path, err)
+	}
+
+	return nil
+}
+
+func isSupportedFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".der", ".pem", ".crt", ".cer":
+		return true
+	}
+
+	return false
+}
+
+//loadPEM loads all the CERTIFICATE blocks, if there are none assumes is a single DER certificate
+func (s *Store) loadPEM(data []byte) error {
+
+	found := false
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if _, err := s.AddDER(block.Bytes); err != nil {
+			return err
+		}
+		found = true
+	}
+
+	if !found {
+		_, err := s.AddDER(data)
+		return err
+	}
+
+	return nil
+}
+
+//Entry a certificate in a JSON bundle, uses the DCCG trust list field names
+type Entry struct {
+	//Kid base64 encoded kid, informational the kid is always calculated from the certificate
+	Kid string `json:"kid,omitempty"`
+
+	//Country 2-letter ISO3166 code of the issuing country
+	Country string `json:"country,omitempty"`
+
+	//RawData base64 DER encoded certificate
+	RawData string `json:"rawData"`
+}
+
+//jsonBundle the JSON object formats that are supported
+type jsonBundle struct {
+	Certificates []*Entry `json:"certificates"`
+
+	TestCtx *struct {
+		Certificate string `json:"CERTIFICATE"`
+	} `json:"TESTCTX"`
+}
+
+func (s *Store) loadJSON(data []byte) error {
+
+	trimmed := strings.TrimSpace(string(data))
+
+	if strings.HasPrefix(trimmed, "[") {
+		var entries []*Entry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return err
+		}
+		return s.AddEntries(entries)
+	}
+
+	var bundle jsonBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return err
+	}
+
+	if bundle.TestCtx != nil && bundle.TestCtx.Certificate != "" {
+		return s.AddEntries([]*Entry{{RawData: bundle.TestCtx.Certificate}})
+	}
+
+	if len(bundle.Certificates) == 0 {
+		return fmt.Errorf("error no certificates found in JSON")
+	}
+
+	return s.AddEntries(bundle.Certificates)
+}
+
+//AddEntries add JSON bundle entries for their country
+func (s *Store) AddEntries(entries []*Entry) error {
+
+	for _, entry := range entries {
+		der, err := base64.StdEncoding.DecodeString(entry.RawData)
+		if err != nil {
+			return fmt.Errorf("error base64 decoding certificate kid=%s err=%s", entry.Kid, err)
+		}
+		if _, err := s.AddCountryDER(der, entry.Country); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
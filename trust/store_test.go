@@ -0,0 +1,159 @@
+package trust_test
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/internal/testvectors"
+	"github.com/webshield-dev/eudvcdecoder/trust"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+//Test_Store_DCC_TestData load all the test data certificates and verify a vector from each country
+func Test_Store_DCC_TestData(t *testing.T) {
+
+	store, err := trust.LoadStore("../testfiles/dcc-testdata")
+	require.NoError(t, err)
+	require.NotZero(t, store.Len(), "should have loaded certificates")
+
+	dgVerifier, err := verifier.NewVerifier(true, true, store)
+	require.NoError(t, err)
+
+	vectors := []string{
+		"../testfiles/dcc-testdata/AT/2DCode/raw/1.json",
+		"../testfiles/dcc-testdata/DE/2DCode/raw/2.json",
+		"../testfiles/dcc-testdata/GR/2DCode/raw/3.json",
+		"../testfiles/dcc-testdata/IE/2DCode/Raw/4.json",
+		"../testfiles/dcc-testdata/NL/2DCode/raw/291-NL-test+recovery+vaccination.json",
+	}
+
+	for _, vectorPath := range vectors {
+		t.Run(vectorPath, func(t *testing.T) {
+
+			tv, cert := testvectors.Read(t, vectorPath)
+			require.NotEmpty(t, store.Certificates(trust.KID(cert)), "should index by kid")
+
+			verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), []byte(tv.Prefix), nil)
+			require.NoError(t, err)
+			require.True(t, verifierOutput.Results.CardStructure.FetchedKey, "should find key")
+			require.True(t, verifierOutput.Results.CardStructure.SignatureValid, "should be valid")
+			require.True(t, cert.Equal(verifierOutput.SigningKey.Certificate), "should be signed by DSC")
+		})
+	}
+}
+
+func Test_Store_Bundles(t *testing.T) {
+
+	_, deCert := testvectors.Read(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+	_, grCert := testvectors.Read(t, "../testfiles/dcc-testdata/GR/2DCode/raw/1.json")
+
+	dir := t.TempDir()
+
+	//PEM bundle with both
+	pemB := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: deCert.Raw})
+	pemB = append(pemB, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: grCert.Raw})...)
+	pemPath := filepath.Join(dir, "bundle.pem")
+	require.NoError(t, os.WriteFile(pemPath, pemB, 0600))
+
+	//DCCG style JSON list
+	listB, err := json.Marshal([]*trust.Entry{
+		{Country: "DE", RawData: base64.StdEncoding.EncodeToString(deCert.Raw)},
+	})
+	require.NoError(t, err)
+	listPath := filepath.Join(dir, "list.json")
+	require.NoError(t, os.WriteFile(listPath, listB, 0600))
+
+	//object with certificates
+	objectB, err := json.Marshal(map[string]interface{}{
+		"certificates": []*trust.Entry{{Country: "GR", RawData: base64.StdEncoding.EncodeToString(grCert.Raw)}},
+	})
+	require.NoError(t, err)
+	objectPath := filepath.Join(dir, "object.json")
+	require.NoError(t, os.WriteFile(objectPath, objectB, 0600))
+
+	//single DER
+	derPath := filepath.Join(dir, "de.der")
+	require.NoError(t, os.WriteFile(derPath, deCert.Raw, 0600))
+
+	type testCase struct {
+		name          string
+		path          string
+		expectedCerts []*x509.Certificate
+	}
+
+	testCases := []testCase{
+		{name: "should load PEM bundle", path: pemPath, expectedCerts: []*x509.Certificate{deCert, grCert}},
+		{name: "should load JSON list", path: listPath, expectedCerts: []*x509.Certificate{deCert}},
+		{name: "should load JSON object", path: objectPath, expectedCerts: []*x509.Certificate{grCert}},
+		{name: "should load DER", path: derPath, expectedCerts: []*x509.Certificate{deCert}},
+		{name: "should load directory without duplicates", path: dir, expectedCerts: []*x509.Certificate{deCert, grCert}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			store, err := trust.LoadStore(tc.path)
+			require.NoError(t, err)
+			require.Equal(t, len(tc.expectedCerts), store.Len())
+
+			for _, cert := range tc.expectedCerts {
+				country := cert.Subject.Country[0]
+				keys, err := store.ResolveKeys(context.TODO(), trust.KID(cert), country)
+				require.NoError(t, err)
+				require.Len(t, keys, 1)
+				require.Equal(t, cert.PublicKey, keys[0].PublicKey)
+
+				keys, err = store.ResolveKeys(context.TODO(), trust.KID(cert), "XX")
+				require.NoError(t, err)
+				require.Empty(t, keys, "should not match another country")
+			}
+		})
+	}
+}
+
+//Test_Store_Entry_Country a JSON entry is only trusted for its country, not the country in the certificate subject
+func Test_Store_Entry_Country(t *testing.T) {
+
+	_, deCert := testvectors.Read(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+
+	listB, err := json.Marshal([]*trust.Entry{
+		{Country: "FR", RawData: base64.StdEncoding.EncodeToString(deCert.Raw)},
+	})
+	require.NoError(t, err)
+	listPath := filepath.Join(t.TempDir(), "list.json")
+	require.NoError(t, os.WriteFile(listPath, listB, 0600))
+
+	store, err := trust.LoadStore(listPath)
+	require.NoError(t, err)
+
+	type testCase struct {
+		name         string
+		country      string
+		expectedKeys int
+	}
+
+	testCases := []testCase{
+		{name: "should match the entry country", country: "FR", expectedKeys: 1},
+		{name: "should not match the certificate subject country", country: "DE", expectedKeys: 0},
+		{name: "should match if no country", country: "", expectedKeys: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			keys, err := store.ResolveKeys(context.TODO(), trust.KID(deCert), tc.country)
+			require.NoError(t, err)
+			require.Len(t, keys, tc.expectedKeys)
+			if tc.expectedKeys != 0 {
+				require.Equal(t, "FR", keys[0].Country)
+			}
+		})
+	}
+}
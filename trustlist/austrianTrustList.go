@@ -0,0 +1,134 @@
+package trustlist
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/webshield-dev/eudvcdecoder/datamodel"
+	"github.com/webshield-dev/eudvcdecoder/trust"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+//
+// Austrian trust list, is two documents
+//  - the list, CBOR encoded {"c": [{"i": kid, "c": DER certificate}, ...]}
+//  - the signature, a COSE_Sign1 signed by the trust anchor whose payload is a CWT with
+//        2 => bstr  SHA-256 of the list
+//        4 => int   expires at, seconds since epoch
+//        5 => int   not before, seconds since epoch
+//
+
+//AustrianTrustListLoader loads the Austrian trust list
+type AustrianTrustListLoader struct {
+
+	//ListURL where to fetch the list from
+	ListURL string
+
+	//SignatureURL where to fetch the signature from
+	SignatureURL string
+
+	//Anchor the public key the signature is signed with
+	Anchor crypto.PublicKey
+
+	//Now returns the time to check the signature validity against, if nil uses time.Now
+	Now func() time.Time
+}
+
+//Fetch see Loader
+func (l *AustrianTrustListLoader) Fetch(ctx context.Context, client *http.Client) (*Download, error) {
+
+	list, err := fetch(ctx, client, l.ListURL)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := fetch(ctx, client, l.SignatureURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Download{List: list, Signature: signature}, nil
+}
+
+//Verify see Loader
+func (l *AustrianTrustListLoader) Verify(download *Download) (*trust.Store, error) {
+
+	now := time.Now()
+	if l.Now != nil {
+		now = l.Now()
+	}
+
+	return ParseAustrianTrustList(download.List, download.Signature, l.Anchor, now)
+}
+
+//AustrianTrustList the CBOR list
+type AustrianTrustList struct {
+	Certificates []*AustrianTrustListEntry `cbor:"c"`
+}
+
+//AustrianTrustListEntry a certificate in the list
+type AustrianTrustListEntry struct {
+	Kid         []byte `cbor:"i"`
+	Certificate []byte `cbor:"c"`
+}
+
+//AustrianTrustListClaims the payload of the signature
+type AustrianTrustListClaims struct {
+	ListHash  []byte `cbor:"2,keyasint"`
+	ExpiresAt int64  `cbor:"4,keyasint"`
+	NotBefore int64  `cbor:"5,keyasint"`
+}
+
+//ParseAustrianTrustList verify the signature with the anchor, that it is valid at now, and that it is for
+//this list then return the DSCs
+func ParseAustrianTrustList(list []byte, signature []byte, anchor crypto.PublicKey,
+	now time.Time) (*trust.Store, error) {
+
+	var signedCWT datamodel.SignedCWT
+	if err := cbor.Unmarshal(signature, &signedCWT); err != nil {
+		return nil, fmt.Errorf("error unmarshalling Austrian trust list signature COSE_Sign1 err=%s", err)
+	}
+
+	valid, err := verifier.VerifyCOSESign1(&signedCWT, anchor)
+	if err != nil {
+		return nil, fmt.Errorf("error checking Austrian trust list signature err=%s", err)
+	}
+	if !valid {
+		return nil, fmt.Errorf("error Austrian trust list signature is not valid")
+	}
+
+	var claims AustrianTrustListClaims
+	if err := cbor.Unmarshal(signedCWT.Payload, &claims); err != nil {
+		return nil, fmt.Errorf("error unmarshalling Austrian trust list signature claims err=%s", err)
+	}
+
+	listHash := sha256.Sum256(list)
+	if !bytes.Equal(listHash[:], claims.ListHash) {
+		return nil, fmt.Errorf("error Austrian trust list signature is for a different list")
+	}
+
+	if now.Before(time.Unix(claims.NotBefore, 0)) || now.After(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, fmt.Errorf("error Austrian trust list signature is only valid from %s to %s",
+			time.Unix(claims.NotBefore, 0).UTC(), time.Unix(claims.ExpiresAt, 0).UTC())
+	}
+
+	var trustList AustrianTrustList
+	if err := cbor.Unmarshal(list, &trustList); err != nil {
+		return nil, fmt.Errorf("error unmarshalling Austrian trust list err=%s", err)
+	}
+
+	store := trust.NewStore()
+	for _, entry := range trustList.Certificates {
+		if _, err := store.AddDER(entry.Certificate); err != nil {
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
@@ -0,0 +1,173 @@
+package trustlist
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+)
+
+//
+// Minimal CMS (RFC 5652) SignedData verification, just enough to check the trust anchor
+// signatures on the DCCG trust list. Only checks the signature against a given key, it does not
+// look at any certificates in the CMS.
+//
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type cmsSignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo cmsEncapContentInfo
+	Certificates     asn1.RawValue   `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue   `asn1:"optional,tag:1"`
+	SignerInfos      []cmsSignerInfo `asn1:"set"`
+}
+
+type cmsEncapContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"optional,explicit,tag:0"`
+}
+
+type cmsSignerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type cmsAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+//verifyCMS check the CMS SignedData has a signer that signed content with the anchor. The content is
+//either embedded, in which case it must match, or detached
+func verifyCMS(cms []byte, content []byte, anchor crypto.PublicKey) error {
+
+	var contentInfo cmsContentInfo
+	if rest, err := asn1.Unmarshal(cms, &contentInfo); err != nil {
+		return fmt.Errorf("error unmarshalling CMS err=%s", err)
+	} else if len(rest) != 0 {
+		return fmt.Errorf("error CMS has trailing data")
+	}
+
+	if !contentInfo.ContentType.Equal(oidSignedData) {
+		return fmt.Errorf("error CMS content type=%s is not SignedData", contentInfo.ContentType)
+	}
+
+	var signedData cmsSignedData
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return fmt.Errorf("error unmarshalling CMS SignedData err=%s", err)
+	}
+
+	if signedData.EncapContentInfo.EContent != nil &&
+		!bytes.Equal(signedData.EncapContentInfo.EContent, content) {
+		return fmt.Errorf("error CMS signed content does not match")
+	}
+
+	if len(signedData.SignerInfos) == 0 {
+		return fmt.Errorf("error CMS has no signers")
+	}
+
+	var lastErr error
+	for i := range signedData.SignerInfos {
+		if lastErr = verifySignerInfo(&signedData.SignerInfos[i], content, anchor); lastErr == nil {
+			return nil
+		}
+	}
+
+	return lastErr
+}
+
+//verifySignerInfo check one signer, if there are signed attributes the signature is over them and they
+//include the content digest, otherwise the signature is over the content
+func verifySignerInfo(signerInfo *cmsSignerInfo, content []byte, anchor crypto.PublicKey) error {
+
+	hash, err := cmsHash(signerInfo.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	h := hash.New()
+	h.Write(content)
+	contentDigest := h.Sum(nil)
+
+	if len(signerInfo.SignedAttrs.FullBytes) == 0 {
+		return verifySignature(anchor, hash, contentDigest, signerInfo.Signature)
+	}
+
+	messageDigest, err := cmsMessageDigest(signerInfo.SignedAttrs.Bytes)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(messageDigest, contentDigest) {
+		return fmt.Errorf("error CMS message digest does not match content")
+	}
+
+	//the signature is over the DER encoding of the attributes as a SET not the [0] IMPLICIT
+	signedAttrs := append([]byte{}, signerInfo.SignedAttrs.FullBytes...)
+	signedAttrs[0] = 0x31
+
+	h = hash.New()
+	h.Write(signedAttrs)
+
+	return verifySignature(anchor, hash, h.Sum(nil), signerInfo.Signature)
+}
+
+//cmsMessageDigest find the message digest in the signed attributes
+func cmsMessageDigest(attrs []byte) ([]byte, error) {
+
+	for len(attrs) > 0 {
+
+		var attr cmsAttribute
+		rest, err := asn1.Unmarshal(attrs, &attr)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling CMS signed attribute err=%s", err)
+		}
+		attrs = rest
+
+		if !attr.Type.Equal(oidMessageDigest) {
+			continue
+		}
+
+		var digest []byte
+		if _, err := asn1.Unmarshal(attr.Values.Bytes, &digest); err != nil {
+			return nil, fmt.Errorf("error unmarshalling CMS message digest err=%s", err)
+		}
+
+		return digest, nil
+	}
+
+	return nil, fmt.Errorf("error CMS signed attributes have no message digest")
+}
+
+func cmsHash(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+
+	switch {
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("error unsupported CMS digest algorithm=%s", oid)
+	}
+}
@@ -0,0 +1,92 @@
+package trustlist
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/webshield-dev/eudvcdecoder/trust"
+)
+
+//
+// DCCG /trustList/DSC, a JSON array where each entry carries a detached CMS signature from the trust
+// anchor over the DER encoded certificate
+//   [{"kid":"...","country":"DE","certificateType":"DSC","thumbprint":"...","timestamp":"...",
+//     "rawData":"...","signature":"..."}]
+//
+
+//DCCGLoader loads the DSCs from an EU DCC Gateway
+type DCCGLoader struct {
+
+	//URL the /trustList/DSC endpoint
+	URL string
+
+	//Anchor the public key of the trust anchor that signs each entry
+	Anchor crypto.PublicKey
+}
+
+//Fetch see Loader
+func (l *DCCGLoader) Fetch(ctx context.Context, client *http.Client) (*Download, error) {
+
+	list, err := fetch(ctx, client, l.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Download{List: list}, nil
+}
+
+//Verify see Loader
+func (l *DCCGLoader) Verify(download *Download) (*trust.Store, error) {
+	return ParseDCCGTrustList(download.List, l.Anchor)
+}
+
+//DCCGEntry an entry in the DCCG trust list
+type DCCGEntry struct {
+	trust.Entry
+	CertificateType string `json:"certificateType,omitempty"`
+	Thumbprint      string `json:"thumbprint,omitempty"`
+	Timestamp       string `json:"timestamp,omitempty"`
+	Signature       string `json:"signature"`
+}
+
+//ParseDCCGTrustList verify the trust anchor signature on every entry and return the DSCs, if any entry
+//is not signed by the anchor the whole list is rejected
+func ParseDCCGTrustList(data []byte, anchor crypto.PublicKey) (*trust.Store, error) {
+
+	var entries []*DCCGEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("error unmarshalling DCCG trust list err=%s", err)
+	}
+
+	store := trust.NewStore()
+	for _, entry := range entries {
+
+		if entry.CertificateType != "" && entry.CertificateType != "DSC" {
+			continue
+		}
+
+		der, err := base64.StdEncoding.DecodeString(entry.RawData)
+		if err != nil {
+			return nil, fmt.Errorf("error base64 decoding certificate kid=%s err=%s", entry.Kid, err)
+		}
+
+		signature, err := base64.StdEncoding.DecodeString(entry.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("error base64 decoding signature kid=%s err=%s", entry.Kid, err)
+		}
+
+		if err := verifyCMS(signature, der, anchor); err != nil {
+			return nil, fmt.Errorf("error DCCG trust anchor signature kid=%s err=%s", entry.Kid, err)
+		}
+
+		if _, err := store.AddCountryDER(der, entry.Country); err != nil {
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
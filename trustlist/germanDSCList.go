@@ -0,0 +1,93 @@
+package trustlist
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/webshield-dev/eudvcdecoder/trust"
+)
+
+//
+// German DSC list, the first line is the base64 encoded ECDSA signature of the trust anchor over the
+// rest of the document which is the JSON
+//   {"certificates":[{"certificateType":"DSC","country":"DE","kid":"...","rawData":"...", ...}]}
+//
+
+//GermanDSCListLoader loads the German DSC list
+type GermanDSCListLoader struct {
+
+	//URL where to fetch the list from
+	URL string
+
+	//Anchor the public key the list is signed with
+	Anchor crypto.PublicKey
+}
+
+//Fetch see Loader
+func (l *GermanDSCListLoader) Fetch(ctx context.Context, client *http.Client) (*Download, error) {
+
+	list, err := fetch(ctx, client, l.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Download{List: list}, nil
+}
+
+//Verify see Loader
+func (l *GermanDSCListLoader) Verify(download *Download) (*trust.Store, error) {
+	return ParseGermanDSCList(download.List, l.Anchor)
+}
+
+//germanDSCListBody the JSON body
+type germanDSCListBody struct {
+	Certificates []*germanDSCEntry `json:"certificates"`
+}
+
+type germanDSCEntry struct {
+	trust.Entry
+	CertificateType string `json:"certificateType,omitempty"`
+}
+
+//ParseGermanDSCList verify the signature line with the anchor and return the DSCs
+func ParseGermanDSCList(data []byte, anchor crypto.PublicKey) (*trust.Store, error) {
+
+	newLine := bytes.IndexByte(data, '\n')
+	if newLine == -1 {
+		return nil, fmt.Errorf("error German DSC list has no signature line")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data[:newLine])))
+	if err != nil {
+		return nil, fmt.Errorf("error base64 decoding German DSC list signature err=%s", err)
+	}
+
+	body := data[newLine+1:]
+	digest := sha256.Sum256(body)
+	if err := verifySignature(anchor, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, fmt.Errorf("error German DSC list signature err=%s", err)
+	}
+
+	var dscList germanDSCListBody
+	if err := json.Unmarshal(body, &dscList); err != nil {
+		return nil, fmt.Errorf("error unmarshalling German DSC list err=%s", err)
+	}
+
+	store := trust.NewStore()
+	for _, entry := range dscList.Certificates {
+		if entry.CertificateType != "" && entry.CertificateType != "DSC" {
+			continue
+		}
+		if err := store.AddEntries([]*trust.Entry{&entry.Entry}); err != nil {
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
@@ -0,0 +1,136 @@
+package trustlist
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+
+	"github.com/webshield-dev/eudvcdecoder/trust"
+)
+
+//
+// Loaders for the published lists of Document Signer Certificates (DSC). Each list is signed by an
+// operator (the trust anchor), the loaders verify that signature before returning the certificates
+// as a trust.Store that can be passed to the verifier.
+//
+// Supported formats
+//  - German DSC list, signature line followed by a JSON body
+//    see https://github.com/Digitaler-Impfnachweis/certification-apis/blob/master/dsc-update/README.md
+//  - Austrian trust list, a CBOR list with a detached COSE_Sign1 signature
+//    see https://github.com/Federal-Ministry-of-Health-AT/green-pass-overview
+//  - EU DCC Gateway (DCCG) /trustList/DSC, a JSON array where each entry has a CMS signature from the trust anchor
+//    see https://github.com/eu-digital-green-certificates/dgc-gateway
+//
+
+//maxDownloadSize trust lists are a few MB, limit in case the server misbehaves
+const maxDownloadSize = 32 * 1024 * 1024
+
+//Download the documents that make up a trust list as downloaded, kept as is so can be cached and re-verified
+type Download struct {
+
+	//List the trust list
+	List []byte `json:"list"`
+
+	//Signature the detached signature, only used by formats that sign separately such as the Austrian list
+	Signature []byte `json:"signature,omitempty"`
+}
+
+//Loader fetches and verifies one trust list format
+type Loader interface {
+
+	//Fetch download the trust list, does not verify
+	Fetch(ctx context.Context, client *http.Client) (*Download, error)
+
+	//Verify check the trust list signature against the trust anchor and return its certificates
+	Verify(download *Download) (*trust.Store, error)
+}
+
+//Load fetch and verify a trust list
+func Load(ctx context.Context, client *http.Client, loader Loader) (*trust.Store, error) {
+
+	download, err := loader.Fetch(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return loader.Verify(download)
+}
+
+//fetch GET the url and return the body, an error if not a 200
+func fetch(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching trust list url=%s err=%s", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching trust list url=%s status=%d", url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDownloadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading trust list url=%s err=%s", url, err)
+	}
+	if len(body) > maxDownloadSize {
+		return nil, fmt.Errorf("error trust list url=%s is larger than %d bytes", url, maxDownloadSize)
+	}
+
+	return body, nil
+}
+
+//verifySignature check a signature over a digest with the anchor key. ECDSA signatures can be either
+//ASN.1 DER or the fixed length r|s encoding, RSA signatures either PKCS #1 v1.5 or PSS
+func verifySignature(anchor crypto.PublicKey, hash crypto.Hash, digest []byte, signature []byte) error {
+
+	switch key := anchor.(type) {
+
+	case *ecdsa.PublicKey:
+		{
+			if ecdsa.VerifyASN1(key, digest, signature) {
+				return nil
+			}
+
+			keySize := (key.Curve.Params().BitSize + 7) / 8
+			if len(signature) == 2*keySize {
+				r := new(big.Int).SetBytes(signature[:keySize])
+				s := new(big.Int).SetBytes(signature[keySize:])
+				if ecdsa.Verify(key, digest, r, s) {
+					return nil
+				}
+			}
+
+			return fmt.Errorf("error trust list ECDSA signature is not valid")
+		}
+
+	case *rsa.PublicKey:
+		{
+			if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err == nil {
+				return nil
+			}
+			if err := rsa.VerifyPSS(key, hash, digest, signature, nil); err == nil {
+				return nil
+			}
+
+			return fmt.Errorf("error trust list RSA signature is not valid")
+		}
+
+	default:
+		return fmt.Errorf("error unsupported trust anchor key type=%T", anchor)
+	}
+}
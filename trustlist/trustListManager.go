@@ -0,0 +1,264 @@
+package trustlist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/webshield-dev/eudvcdecoder/trust"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+//DefaultRefreshInterval how often the trust list is refreshed if not set in the options
+const DefaultRefreshInterval = 6 * time.Hour
+
+//ManagerOptions configure a TrustListManager
+type ManagerOptions struct {
+
+	//Loader fetches and verifies the trust list, its URL is where the list is downloaded from
+	Loader Loader
+
+	//Client the http client, if nil uses http.DefaultClient
+	Client *http.Client
+
+	//CachePath file the last good trust list is persisted to, if empty the list is not persisted
+	CachePath string
+
+	//RefreshInterval how often to refresh in the background, if zero uses DefaultRefreshInterval
+	RefreshInterval time.Duration
+
+	//Now returns the current time, if nil uses time.Now
+	Now func() time.Time
+}
+
+//TrustListManager keeps a verified trust list up to date for the verifier
+//  - the last good list is persisted with its fetch time, so can start when offline, failing to persist it is
+//    only a warning see CacheError
+//  - refreshes in the background, if a refresh fails the last good list is kept
+//  - is a verifier.KeyResolver that is safe to use while a refresh swaps the list
+//  - reports the age of the list so the verifier can flag it as stale
+type TrustListManager struct {
+	opts ManagerOptions
+
+	mutex     sync.RWMutex
+	store     *trust.Store
+	fetchedAt time.Time
+	lastErr   error
+	cacheErr  error
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	running  sync.WaitGroup
+}
+
+//cachedTrustList what is persisted to the CachePath
+type cachedTrustList struct {
+	FetchedAt time.Time `json:"fetchedAt"`
+	Download  *Download `json:"download"`
+}
+
+//NewTrustListManager make a manager, call Start to load the list and begin refreshing
+func NewTrustListManager(opts ManagerOptions) (*TrustListManager, error) {
+
+	if opts.Loader == nil {
+		return nil, fmt.Errorf("error trust list manager requires a Loader")
+	}
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = DefaultRefreshInterval
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	return &TrustListManager{opts: opts, stop: make(chan struct{})}, nil
+}
+
+//Start loads the persisted list, then refreshes it and keeps refreshing in the background until Stop is
+//called or the ctx is done. Returns an error only if there is no usable list at all, the background
+//refresh is still started so the manager recovers once the list can be fetched
+func (m *TrustListManager) Start(ctx context.Context) error {
+
+	cacheErr := m.loadCache()
+
+	err := m.Refresh(ctx)
+	if err != nil && cacheErr == nil {
+		//offline but have the last good list
+		err = nil
+	}
+
+	m.running.Add(1)
+	go m.refreshLoop(ctx)
+
+	return err
+}
+
+//Stop the background refresh, waits for any refresh in progress
+func (m *TrustListManager) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+	m.running.Wait()
+}
+
+func (m *TrustListManager) refreshLoop(ctx context.Context) {
+
+	defer m.running.Done()
+
+	ticker := time.NewTicker(m.opts.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			//the error is kept for LastError, the last good list continues to be used
+			_ = m.Refresh(ctx)
+		}
+	}
+}
+
+//Refresh fetch and verify the trust list now, if it fails the current list is kept. Once the new list is in use
+//failing to persist it does not fail the refresh, see CacheError
+func (m *TrustListManager) Refresh(ctx context.Context) error {
+
+	fetchedAt := m.opts.Now()
+
+	download, err := m.opts.Loader.Fetch(ctx, m.opts.Client)
+	if err == nil {
+		err = m.swap(download, fetchedAt)
+	}
+
+	var cacheErr error
+	if err == nil && m.opts.CachePath != "" {
+		cacheErr = m.saveCache(&cachedTrustList{FetchedAt: fetchedAt, Download: download})
+	}
+
+	m.mutex.Lock()
+	m.lastErr = err
+	if err == nil {
+		m.cacheErr = cacheErr
+	}
+	m.mutex.Unlock()
+
+	return err
+}
+
+//swap verify the download and if good make it the current list
+func (m *TrustListManager) swap(download *Download, fetchedAt time.Time) error {
+
+	store, err := m.opts.Loader.Verify(download)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.store = store
+	m.fetchedAt = fetchedAt
+
+	return nil
+}
+
+func (m *TrustListManager) loadCache() error {
+
+	if m.opts.CachePath == "" {
+		return fmt.Errorf("error no trust list cache")
+	}
+
+	data, err := os.ReadFile(m.opts.CachePath)
+	if err != nil {
+		return err
+	}
+
+	var cached cachedTrustList
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return fmt.Errorf("error unmarshalling trust list cache path=%s err=%s", m.opts.CachePath, err)
+	}
+	if cached.Download == nil {
+		return fmt.Errorf("error trust list cache path=%s has no list", m.opts.CachePath)
+	}
+
+	//verify again as the file could have been changed
+	return m.swap(cached.Download, cached.FetchedAt)
+}
+
+//saveCache write to a temporary file then rename so a crash never leaves a partial file
+func (m *TrustListManager) saveCache(cached *cachedTrustList) error {
+
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.opts.CachePath), filepath.Base(m.opts.CachePath)+".*")
+	if err != nil {
+		return fmt.Errorf("error saving trust list cache path=%s err=%s", m.opts.CachePath, err)
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), m.opts.CachePath)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("error saving trust list cache path=%s err=%s", m.opts.CachePath, err)
+	}
+
+	return nil
+}
+
+//ResolveKeys see verifier.KeyResolver
+func (m *TrustListManager) ResolveKeys(ctx context.Context, kid []byte, country string) ([]*verifier.SigningKey, error) {
+
+	m.mutex.RLock()
+	store := m.store
+	m.mutex.RUnlock()
+
+	if store == nil {
+		return nil, nil
+	}
+
+	return store.ResolveKeys(ctx, kid, country)
+}
+
+//TrustListFetchedAt see verifier.TrustListStatus
+func (m *TrustListManager) TrustListFetchedAt() time.Time {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.fetchedAt
+}
+
+//TrustListAge see verifier.TrustListStatus
+func (m *TrustListManager) TrustListAge() time.Duration {
+
+	fetchedAt := m.TrustListFetchedAt()
+	if fetchedAt.IsZero() {
+		return 0
+	}
+
+	return m.opts.Now().Sub(fetchedAt)
+}
+
+//LastError the error from the last refresh, nil if it succeeded
+func (m *TrustListManager) LastError() error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.lastErr
+}
+
+//CacheError the error persisting the list from the last successful refresh, nil if it was persisted. The list is
+//still used but if the manager restarts offline it is not there to fall back to
+func (m *TrustListManager) CacheError() error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.cacheErr
+}
@@ -0,0 +1,141 @@
+package trustlist_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/internal/testvectors"
+	"github.com/webshield-dev/eudvcdecoder/trust"
+	"github.com/webshield-dev/eudvcdecoder/trustlist"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+func Test_TrustListManager(t *testing.T) {
+
+	tv, deCert := testvectors.Read(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+	grCert := testvectors.ReadCertificate(t, "../testfiles/dcc-testdata/GR/2DCode/raw/1.json")
+
+	anchor, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	server := testServer(t, map[string][]byte{"/dsc": germanDSCList(t, anchor, deCert, grCert)})
+	cachePath := filepath.Join(t.TempDir(), "dsc.json")
+
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	//
+	// online, fetches and persists the list
+	//
+	manager, err := trustlist.NewTrustListManager(trustlist.ManagerOptions{
+		Loader:          &trustlist.GermanDSCListLoader{URL: server.URL + "/dsc", Anchor: &anchor.PublicKey},
+		Client:          server.Client(),
+		CachePath:       cachePath,
+		RefreshInterval: time.Millisecond,
+		Now:             clock,
+	})
+	require.NoError(t, err)
+	require.NoError(t, manager.Start(context.TODO()))
+	require.Equal(t, now, manager.TrustListFetchedAt())
+
+	dgVerifier, err := verifier.NewVerifier(false, false, manager)
+	require.NoError(t, err)
+
+	//verify while the background refresh is swapping the list
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), []byte(tv.Prefix), nil)
+				assert.NoError(t, err)
+				assert.True(t, verifierOutput.Results.CardStructure.SignatureValid, "should be valid")
+			}
+		}()
+	}
+	wg.Wait()
+	manager.Stop()
+	require.NoError(t, manager.LastError())
+	require.NoError(t, manager.CacheError())
+
+	//
+	// offline, uses the persisted list and reports its age
+	//
+	server.Close()
+	later := now.Add(30 * time.Hour)
+
+	offlineManager, err := trustlist.NewTrustListManager(trustlist.ManagerOptions{
+		Loader:    &trustlist.GermanDSCListLoader{URL: server.URL + "/dsc", Anchor: &anchor.PublicKey},
+		CachePath: cachePath,
+		Now:       func() time.Time { return later },
+	})
+	require.NoError(t, err)
+	require.NoError(t, offlineManager.Start(context.TODO()), "should start from cache")
+	defer offlineManager.Stop()
+	require.Error(t, offlineManager.LastError(), "should have failed to refresh")
+	require.Equal(t, now, offlineManager.TrustListFetchedAt().UTC(), "should keep fetch time")
+	require.Equal(t, 30*time.Hour, offlineManager.TrustListAge())
+
+	dgVerifier, err = verifier.NewVerifier(false, false, offlineManager)
+	require.NoError(t, err)
+
+	verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), []byte(tv.Prefix),
+		&verifier.VerifyOptions{MaxTrustListAge: 24 * time.Hour})
+	require.NoError(t, err)
+	require.True(t, verifierOutput.Results.CardStructure.SignatureValid, "should be valid")
+	require.True(t, verifierOutput.TrustListStale, "should be stale")
+
+	verifierOutput, err = dgVerifier.FromQRCodeContents(context.TODO(), []byte(tv.Prefix),
+		&verifier.VerifyOptions{MaxTrustListAge: 48 * time.Hour})
+	require.NoError(t, err)
+	require.False(t, verifierOutput.TrustListStale, "should not be stale")
+
+	//
+	// offline with no cache
+	//
+	noCacheManager, err := trustlist.NewTrustListManager(trustlist.ManagerOptions{
+		Loader:    &trustlist.GermanDSCListLoader{URL: server.URL + "/dsc", Anchor: &anchor.PublicKey},
+		CachePath: filepath.Join(t.TempDir(), "missing.json"),
+	})
+	require.NoError(t, err)
+	require.Error(t, noCacheManager.Start(context.TODO()), "should have no list")
+	noCacheManager.Stop()
+}
+
+func Test_TrustListManager_Cache_Write_Error(t *testing.T) {
+
+	deCert := testvectors.ReadCertificate(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+
+	anchor, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	server := testServer(t, map[string][]byte{"/dsc": germanDSCList(t, anchor, deCert)})
+	defer server.Close()
+
+	//cold start with a cache that cannot be written, the fetched list is still used
+	manager, err := trustlist.NewTrustListManager(trustlist.ManagerOptions{
+		Loader:    &trustlist.GermanDSCListLoader{URL: server.URL + "/dsc", Anchor: &anchor.PublicKey},
+		Client:    server.Client(),
+		CachePath: filepath.Join(t.TempDir(), "missing", "dsc.json"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, manager.Start(context.TODO()), "should start with the fetched list")
+	defer manager.Stop()
+
+	require.NoError(t, manager.LastError())
+	require.Error(t, manager.CacheError(), "should report the cache was not written")
+	require.False(t, manager.TrustListFetchedAt().IsZero())
+
+	keys, err := manager.ResolveKeys(context.TODO(), trust.KID(deCert), "DE")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+}
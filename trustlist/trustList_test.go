@@ -0,0 +1,358 @@
+package trustlist_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/datamodel"
+	"github.com/webshield-dev/eudvcdecoder/internal/testvectors"
+	"github.com/webshield-dev/eudvcdecoder/trust"
+	"github.com/webshield-dev/eudvcdecoder/trustlist"
+)
+
+//testServer serves the documents by path
+func testServer(t *testing.T, documents map[string][]byte) *httptest.Server {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		document, ok := documents[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(document)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func requireCertificates(t *testing.T, store *trust.Store, certs ...*x509.Certificate) {
+
+	require.Equal(t, len(certs), store.Len())
+	for _, cert := range certs {
+		keys, err := store.ResolveKeys(context.TODO(), trust.KID(cert), cert.Subject.Country[0])
+		require.NoError(t, err)
+		require.Len(t, keys, 1, "should have the DSC")
+	}
+}
+
+func germanDSCList(t *testing.T, anchor *ecdsa.PrivateKey, certs ...*x509.Certificate) []byte {
+
+	type entry struct {
+		trust.Entry
+		CertificateType string `json:"certificateType"`
+	}
+
+	entries := make([]*entry, 0, len(certs))
+	for _, cert := range certs {
+		entries = append(entries, &entry{
+			Entry: trust.Entry{
+				Kid:     base64.StdEncoding.EncodeToString(trust.KID(cert)),
+				Country: cert.Subject.Country[0],
+				RawData: base64.StdEncoding.EncodeToString(cert.Raw),
+			},
+			CertificateType: "DSC",
+		})
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"certificates": entries})
+	require.NoError(t, err)
+
+	digest := sha256.Sum256(body)
+	signature, err := ecdsa.SignASN1(rand.Reader, anchor, digest[:])
+	require.NoError(t, err)
+
+	list := []byte(base64.StdEncoding.EncodeToString(signature) + "\n")
+	return append(list, body...)
+}
+
+func Test_GermanDSCList(t *testing.T) {
+
+	deCert := testvectors.ReadCertificate(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+	grCert := testvectors.ReadCertificate(t, "../testfiles/dcc-testdata/GR/2DCode/raw/1.json")
+
+	anchor, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherAnchor, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	list := germanDSCList(t, anchor, deCert, grCert)
+	tampered := germanDSCList(t, anchor, deCert)
+	tampered = append(tampered[:len(tampered)-1], []byte(` `)...)
+
+	server := testServer(t, map[string][]byte{
+		"/dsc":      list,
+		"/tampered": tampered,
+	})
+
+	store, err := trustlist.Load(context.TODO(), server.Client(),
+		&trustlist.GermanDSCListLoader{URL: server.URL + "/dsc", Anchor: &anchor.PublicKey})
+	require.NoError(t, err)
+	requireCertificates(t, store, deCert, grCert)
+
+	_, err = trustlist.Load(context.TODO(), server.Client(),
+		&trustlist.GermanDSCListLoader{URL: server.URL + "/dsc", Anchor: &otherAnchor.PublicKey})
+	require.Error(t, err, "should fail if not signed by anchor")
+
+	_, err = trustlist.Load(context.TODO(), server.Client(),
+		&trustlist.GermanDSCListLoader{URL: server.URL + "/tampered", Anchor: &anchor.PublicKey})
+	require.Error(t, err, "should fail if list changed")
+
+	_, err = trustlist.Load(context.TODO(), server.Client(),
+		&trustlist.GermanDSCListLoader{URL: server.URL + "/missing", Anchor: &anchor.PublicKey})
+	require.Error(t, err, "should fail if not found")
+}
+
+//austrianTrustList returns the CBOR list and its COSE_Sign1 signature
+func austrianTrustList(t *testing.T, anchor *ecdsa.PrivateKey, notBefore time.Time, expiresAt time.Time,
+	certs ...*x509.Certificate) ([]byte, []byte) {
+
+	trustList := &trustlist.AustrianTrustList{}
+	for _, cert := range certs {
+		trustList.Certificates = append(trustList.Certificates,
+			&trustlist.AustrianTrustListEntry{Kid: trust.KID(cert), Certificate: cert.Raw})
+	}
+	list, err := cbor.Marshal(trustList)
+	require.NoError(t, err)
+
+	listHash := sha256.Sum256(list)
+	payload, err := cbor.Marshal(&trustlist.AustrianTrustListClaims{
+		ListHash:  listHash[:],
+		ExpiresAt: expiresAt.Unix(),
+		NotBefore: notBefore.Unix(),
+	})
+	require.NoError(t, err)
+
+	protected, err := cbor.Marshal(map[int]interface{}{1: datamodel.COSEAlgES256})
+	require.NoError(t, err)
+
+	signedCWT := &datamodel.SignedCWT{Protected: protected, Payload: payload}
+	tbs, err := cbor.Marshal(signedCWT.SigStructure(nil))
+	require.NoError(t, err)
+
+	digest := sha256.Sum256(tbs)
+	r, s, err := ecdsa.Sign(rand.Reader, anchor, digest[:])
+	require.NoError(t, err)
+	signedCWT.Signature = make([]byte, 64)
+	r.FillBytes(signedCWT.Signature[:32])
+	s.FillBytes(signedCWT.Signature[32:])
+
+	signature, err := cbor.Marshal(cbor.Tag{Number: 18, Content: signedCWT})
+	require.NoError(t, err)
+
+	return list, signature
+}
+
+func Test_AustrianTrustList(t *testing.T) {
+
+	deCert := testvectors.ReadCertificate(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+	grCert := testvectors.ReadCertificate(t, "../testfiles/dcc-testdata/GR/2DCode/raw/1.json")
+
+	anchor, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherAnchor, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	now := time.Now()
+	list, signature := austrianTrustList(t, anchor, now.Add(-time.Hour), now.Add(time.Hour), deCert, grCert)
+	otherList, _ := austrianTrustList(t, anchor, now.Add(-time.Hour), now.Add(time.Hour), deCert)
+	_, expiredSignature := austrianTrustList(t, anchor, now.Add(-2*time.Hour), now.Add(-time.Hour),
+		deCert, grCert)
+
+	server := testServer(t, map[string][]byte{
+		"/trustlist":         list,
+		"/trustlist.sig":     signature,
+		"/other":             otherList,
+		"/trustlist.sig.old": expiredSignature,
+	})
+
+	type testCase struct {
+		name         string
+		listPath     string
+		sigPath      string
+		anchor       crypto.PublicKey
+		expectedFail bool
+	}
+
+	testCases := []testCase{
+		{name: "should load", listPath: "/trustlist", sigPath: "/trustlist.sig", anchor: &anchor.PublicKey},
+		{name: "should fail if not signed by anchor", listPath: "/trustlist", sigPath: "/trustlist.sig",
+			anchor: &otherAnchor.PublicKey, expectedFail: true},
+		{name: "should fail if signature for another list", listPath: "/other", sigPath: "/trustlist.sig",
+			anchor: &anchor.PublicKey, expectedFail: true},
+		{name: "should fail if signature expired", listPath: "/trustlist", sigPath: "/trustlist.sig.old",
+			anchor: &anchor.PublicKey, expectedFail: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			store, err := trustlist.Load(context.TODO(), server.Client(), &trustlist.AustrianTrustListLoader{
+				ListURL:      server.URL + tc.listPath,
+				SignatureURL: server.URL + tc.sigPath,
+				Anchor:       tc.anchor,
+			})
+
+			if tc.expectedFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			requireCertificates(t, store, deCert, grCert)
+		})
+	}
+}
+
+//
+// CMS structures used to build the DCCG trust anchor signatures
+//
+
+type cmsAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values [][]byte `asn1:"set"`
+}
+
+type cmsSignerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+type cmsSignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo struct {
+		EContentType asn1.ObjectIdentifier
+	}
+	SignerInfos []cmsSignerInfo `asn1:"set"`
+}
+
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+//cmsDetachedSignature a detached CMS SignedData over content with signed attributes
+func cmsDetachedSignature(t *testing.T, signer *ecdsa.PrivateKey, content []byte) []byte {
+
+	sha256Alg := pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}}
+	ecdsaWithSHA256 := pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}}
+	data := asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+
+	contentDigest := sha256.Sum256(content)
+	attrs, err := asn1.MarshalWithParams([]cmsAttribute{
+		{Type: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}, Values: [][]byte{contentDigest[:]}},
+	}, "set")
+	require.NoError(t, err)
+
+	attrsDigest := sha256.Sum256(attrs)
+	signature, err := ecdsa.SignASN1(rand.Reader, signer, attrsDigest[:])
+	require.NoError(t, err)
+
+	//in the SignerInfo the attributes are [0] IMPLICIT
+	attrs[0] = 0xa0
+
+	signedData := cmsSignedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256Alg},
+		SignerInfos: []cmsSignerInfo{{
+			Version:            3,
+			SID:                asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: []byte{1, 2, 3, 4}},
+			DigestAlgorithm:    sha256Alg,
+			SignedAttrs:        asn1.RawValue{FullBytes: attrs},
+			SignatureAlgorithm: ecdsaWithSHA256,
+			Signature:          signature,
+		}},
+	}
+	signedData.EncapContentInfo.EContentType = data
+
+	signedDataB, err := asn1.Marshal(signedData)
+	require.NoError(t, err)
+
+	cms, err := asn1.Marshal(cmsContentInfo{
+		ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2},
+		Content: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true,
+			Bytes: signedDataB},
+	})
+	require.NoError(t, err)
+
+	return cms
+}
+
+func dccgTrustList(t *testing.T, signers map[*x509.Certificate]*ecdsa.PrivateKey) []byte {
+
+	entries := make([]*trustlist.DCCGEntry, 0, len(signers))
+	for cert, signer := range signers {
+		thumbprint := sha256.Sum256(cert.Raw)
+		entries = append(entries, &trustlist.DCCGEntry{
+			Entry: trust.Entry{
+				Kid:     base64.StdEncoding.EncodeToString(trust.KID(cert)),
+				Country: cert.Subject.Country[0],
+				RawData: base64.StdEncoding.EncodeToString(cert.Raw),
+			},
+			CertificateType: "DSC",
+			Thumbprint:      base64.StdEncoding.EncodeToString(thumbprint[:]),
+			Timestamp:       "2021-06-01T00:00:00Z",
+			Signature:       base64.StdEncoding.EncodeToString(cmsDetachedSignature(t, signer, cert.Raw)),
+		})
+	}
+
+	list, err := json.Marshal(entries)
+	require.NoError(t, err)
+
+	return list
+}
+
+func Test_DCCGTrustList(t *testing.T) {
+
+	deCert := testvectors.ReadCertificate(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+	grCert := testvectors.ReadCertificate(t, "../testfiles/dcc-testdata/GR/2DCode/raw/1.json")
+
+	anchor, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherAnchor, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	server := testServer(t, map[string][]byte{
+		"/trustList/DSC": dccgTrustList(t, map[*x509.Certificate]*ecdsa.PrivateKey{
+			deCert: anchor,
+			grCert: anchor,
+		}),
+		"/mixed/trustList/DSC": dccgTrustList(t, map[*x509.Certificate]*ecdsa.PrivateKey{
+			deCert: anchor,
+			grCert: otherAnchor,
+		}),
+	})
+
+	store, err := trustlist.Load(context.TODO(), server.Client(),
+		&trustlist.DCCGLoader{URL: server.URL + "/trustList/DSC", Anchor: &anchor.PublicKey})
+	require.NoError(t, err)
+	requireCertificates(t, store, deCert, grCert)
+
+	_, err = trustlist.Load(context.TODO(), server.Client(),
+		&trustlist.DCCGLoader{URL: server.URL + "/trustList/DSC", Anchor: &otherAnchor.PublicKey})
+	require.Error(t, err, "should fail if not signed by anchor")
+
+	_, err = trustlist.Load(context.TODO(), server.Client(),
+		&trustlist.DCCGLoader{URL: server.URL + "/mixed/trustList/DSC", Anchor: &anchor.PublicKey})
+	require.Error(t, err, "should fail if any entry not signed by anchor")
+}
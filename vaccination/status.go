@@ -0,0 +1,235 @@
+package vaccination
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/webshield-dev/eudvcdecoder/datamodel"
+)
+
+//
+// Vaccination status answers "is this person fully vaccinated at a time", from the DCC vaccination entry
+// dose number (dn), total doses (sd), date (dt) and medicinal product (mp)
+//
+//  - partial          the series is not complete, or the waiting period after the final dose has not passed
+//  - complete         the series is complete and the waiting period has passed
+//  - booster          a dose after a complete series, such as 3/3 or 2/1, or 2/2 of a single dose product
+//  - expired-complete the series, or booster, was complete but is older than the maximum validity
+//
+// The defaults follow the EU acceptance rules, see https://github.com/ehn-dcc-development/dgc-business-rules
+//
+
+//Status the vaccination status
+type Status string
+
+const (
+	//StatusPartial the series is not complete, or is still in the waiting period
+	StatusPartial Status = "partial"
+
+	//StatusComplete the series is complete
+	StatusComplete Status = "complete"
+
+	//StatusBooster a booster dose was given after a complete series
+	StatusBooster Status = "booster"
+
+	//StatusExpiredComplete the series was complete but is older than the maximum validity
+	StatusExpiredComplete Status = "expired-complete"
+)
+
+const (
+	day = 24 * time.Hour
+
+	//DefaultWaitingPeriod the time after the final dose of the series before it is complete
+	DefaultWaitingPeriod = 14 * day
+
+	//DefaultMaxValidity the time after the final dose of the series that it is accepted
+	DefaultMaxValidity = 270 * day
+
+	//boosterDoses a dn of at least 3 that completes the series is a booster, as used by issuers that record
+	//a booster as 3/3
+	boosterDoses = 3
+)
+
+//EU authorised medicinal products, the mp values from vaccine-medicinal-product.json
+const (
+	ProductComirnaty = "EU/1/20/1528"
+	ProductSpikevax  = "EU/1/20/1507"
+	ProductVaxzevria = "EU/1/21/1529"
+	ProductJanssen   = "EU/1/20/1525"
+	ProductNuvaxovid = "EU/1/21/1618"
+)
+
+//ProductRule how a medicinal product completes a series
+type ProductRule struct {
+
+	//Doses the doses in a complete series, a dose after it is a booster. Used if less than the DCC sd,
+	//zero to use the sd
+	Doses int
+
+	//WaitingPeriod replaces the Options WaitingPeriod for the product, zero to use the Options WaitingPeriod
+	WaitingPeriod time.Duration
+}
+
+//Options configure the status evaluation
+type Options struct {
+
+	//WaitingPeriod the time after the final dose of the series before it is complete, zero for none
+	WaitingPeriod time.Duration
+
+	//MaxValidity how long after the final dose of the series it is complete, zero for no limit
+	MaxValidity time.Duration
+
+	//BoosterMaxValidity how long after a booster dose it is valid, zero for no limit
+	BoosterMaxValidity time.Duration
+
+	//Products the rules for medicinal products by mp, a product that is not present uses the DCC sd
+	Products map[string]*ProductRule
+}
+
+//DefaultOptions a 14 day waiting period, complete for 270 days, boosters do not expire, and the EU
+//authorised products, Janssen is a single dose
+func DefaultOptions() *Options {
+	return &Options{
+		WaitingPeriod: DefaultWaitingPeriod,
+		MaxValidity:   DefaultMaxValidity,
+		Products: map[string]*ProductRule{
+			ProductComirnaty: {Doses: 2},
+			ProductSpikevax:  {Doses: 2},
+			ProductVaxzevria: {Doses: 2},
+			ProductJanssen:   {Doses: 1},
+			ProductNuvaxovid: {Doses: 2},
+		},
+	}
+}
+
+//Evaluation the status and why
+type Evaluation struct {
+	Status Status
+
+	//Explanation why, in a sentence that can be displayed
+	Explanation string
+
+	//DoseDate the date of the dose, the dt
+	DoseDate time.Time
+
+	//ValidFrom when the status is complete or booster, zero if the series is not complete
+	ValidFrom time.Time
+
+	//ValidUntil when complete or booster ends, zero if there is no limit or the series is not complete
+	ValidUntil time.Time
+}
+
+//Evaluate the status of the vaccination at the time, nil options uses the DefaultOptions. An error if the dn,
+//sd or dt are not valid
+func Evaluate(vaccine *datamodel.Vaccine, at time.Time, opts *Options) (*Evaluation, error) {
+
+	if vaccine == nil {
+		return nil, fmt.Errorf("error evaluating vaccination status no vaccination")
+	}
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+
+	doseNumber := int(vaccine.DN)
+	seriesDoses := int(vaccine.SD)
+	if doseNumber < 1 || seriesDoses < 1 {
+		return nil, fmt.Errorf("error evaluating vaccination status invalid dn=%v sd=%v", vaccine.DN, vaccine.SD)
+	}
+
+	doseDate, err := datamodel.ParseDate(strings.TrimSpace(vaccine.DT))
+	if err != nil {
+		return nil, fmt.Errorf("error evaluating vaccination status err=%s", err)
+	}
+
+	requiredDoses := seriesDoses
+	waitingPeriod := opts.WaitingPeriod
+	if product := opts.Products[vaccine.MP]; product != nil {
+		if product.Doses > 0 && product.Doses < requiredDoses {
+			requiredDoses = product.Doses
+		}
+		if product.WaitingPeriod != 0 {
+			waitingPeriod = product.WaitingPeriod
+		}
+	}
+
+	evaluation := &Evaluation{DoseDate: doseDate.Time}
+	given := fmt.Sprintf("dose %d of %d given %s", doseNumber, seriesDoses, doseDate)
+	at = at.UTC()
+
+	switch {
+	case doseNumber < requiredDoses:
+		evaluation.Status = StatusPartial
+		evaluation.Explanation = fmt.Sprintf("%s, %d doses are required to complete the series", given,
+			requiredDoses)
+
+	case doseNumber > requiredDoses || doseNumber >= boosterDoses:
+		evaluation.ValidFrom = doseDate.Time
+		if opts.BoosterMaxValidity != 0 {
+			evaluation.ValidUntil = doseDate.Add(opts.BoosterMaxValidity)
+		}
+		if !evaluation.ValidUntil.IsZero() && !at.Before(evaluation.ValidUntil) {
+			evaluation.Status = StatusExpiredComplete
+			evaluation.Explanation = fmt.Sprintf("%s, the booster expired on %s after %d days", given,
+				formatDay(evaluation.ValidUntil), days(opts.BoosterMaxValidity))
+			break
+		}
+		evaluation.Status = StatusBooster
+		evaluation.Explanation = fmt.Sprintf("%s, a booster after a %d dose series%s", given, requiredDoses,
+			until(evaluation.ValidUntil))
+
+	default:
+		evaluation.ValidFrom = doseDate.Add(waitingPeriod)
+		if opts.MaxValidity != 0 {
+			evaluation.ValidUntil = doseDate.Add(opts.MaxValidity)
+		}
+		switch {
+		case at.Before(evaluation.ValidFrom):
+			evaluation.Status = StatusPartial
+			evaluation.Explanation = fmt.Sprintf("%s, complete from %s after the %d day waiting period", given,
+				formatDay(evaluation.ValidFrom), days(waitingPeriod))
+		case !evaluation.ValidUntil.IsZero() && !at.Before(evaluation.ValidUntil):
+			evaluation.Status = StatusExpiredComplete
+			evaluation.Explanation = fmt.Sprintf("%s, complete expired on %s after %d days", given,
+				formatDay(evaluation.ValidUntil), days(opts.MaxValidity))
+		default:
+			evaluation.Status = StatusComplete
+			evaluation.Explanation = fmt.Sprintf("%s, complete since %s%s", given,
+				formatDay(evaluation.ValidFrom), until(evaluation.ValidUntil))
+		}
+	}
+
+	return evaluation, nil
+}
+
+//EvaluateDCC the status of the most recent dose in the DCC vaccination entries
+func EvaluateDCC(dcc *datamodel.DCC, at time.Time, opts *Options) (*Evaluation, error) {
+
+	if dcc == nil || len(dcc.Vaccine) == 0 {
+		return nil, fmt.Errorf("error evaluating vaccination status no vaccination")
+	}
+
+	latest := &dcc.Vaccine[0]
+	for i := range dcc.Vaccine {
+		if dcc.Vaccine[i].DN > latest.DN {
+			latest = &dcc.Vaccine[i]
+		}
+	}
+
+	return Evaluate(latest, at, opts)
+}
+
+func until(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf(", valid until %s", formatDay(t))
+}
+
+func formatDay(t time.Time) string {
+	return t.Format(datamodel.DateLayout)
+}
+
+func days(d time.Duration) int {
+	return int(d / day)
+}
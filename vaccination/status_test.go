@@ -0,0 +1,178 @@
+package vaccination_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/datamodel"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+	"github.com/webshield-dev/eudvcdecoder/vaccination"
+)
+
+func Test_Evaluate(t *testing.T) {
+
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		require.NoError(t, err)
+		return parsed
+	}
+
+	type testCase struct {
+		name                string
+		vaccine             *datamodel.Vaccine
+		at                  time.Time
+		opts                *vaccination.Options
+		expectedStatus      vaccination.Status
+		expectedExplanation string
+		expectedValidUntil  string
+	}
+
+	testCases := []testCase{
+		{
+			name:                "should be partial if not all doses given",
+			vaccine:             &datamodel.Vaccine{MP: vaccination.ProductComirnaty, DN: 1, SD: 2, DT: "2021-06-01"},
+			at:                  at("2021-08-01T00:00:00Z"),
+			expectedStatus:      vaccination.StatusPartial,
+			expectedExplanation: "dose 1 of 2 given 2021-06-01, 2 doses are required to complete the series",
+		},
+		{
+			name:                "should be partial during the waiting period",
+			vaccine:             &datamodel.Vaccine{MP: vaccination.ProductComirnaty, DN: 2, SD: 2, DT: "2021-06-01"},
+			at:                  at("2021-06-14T23:59:59Z"),
+			expectedStatus:      vaccination.StatusPartial,
+			expectedExplanation: "dose 2 of 2 given 2021-06-01, complete from 2021-06-15 after the 14 day waiting period",
+			expectedValidUntil:  "2022-02-26",
+		},
+		{
+			name:                "should be complete after the waiting period",
+			vaccine:             &datamodel.Vaccine{MP: vaccination.ProductComirnaty, DN: 2, SD: 2, DT: "2021-06-01"},
+			at:                  at("2021-06-15T00:00:00Z"),
+			expectedStatus:      vaccination.StatusComplete,
+			expectedExplanation: "dose 2 of 2 given 2021-06-01, complete since 2021-06-15, valid until 2022-02-26",
+			expectedValidUntil:  "2022-02-26",
+		},
+		{
+			name:                "should be expired complete after the max validity",
+			vaccine:             &datamodel.Vaccine{MP: vaccination.ProductComirnaty, DN: 2, SD: 2, DT: "2021-06-01"},
+			at:                  at("2022-02-26T00:00:00Z"),
+			expectedStatus:      vaccination.StatusExpiredComplete,
+			expectedExplanation: "dose 2 of 2 given 2021-06-01, complete expired on 2022-02-26 after 270 days",
+			expectedValidUntil:  "2022-02-26",
+		},
+		{
+			name:                "should be complete after one dose of a single dose product",
+			vaccine:             &datamodel.Vaccine{MP: vaccination.ProductJanssen, DN: 1, SD: 1, DT: "2021-06-01"},
+			at:                  at("2021-07-01T00:00:00Z"),
+			expectedStatus:      vaccination.StatusComplete,
+			expectedExplanation: "dose 1 of 1 given 2021-06-01, complete since 2021-06-15, valid until 2022-02-26",
+			expectedValidUntil:  "2022-02-26",
+		},
+		{
+			name:                "should be booster after the second dose of a single dose product",
+			vaccine:             &datamodel.Vaccine{MP: vaccination.ProductJanssen, DN: 2, SD: 2, DT: "2021-12-01"},
+			at:                  at("2021-12-01T00:00:00Z"),
+			expectedStatus:      vaccination.StatusBooster,
+			expectedExplanation: "dose 2 of 2 given 2021-12-01, a booster after a 1 dose series",
+		},
+		{
+			name:                "should be booster if 3 of 3",
+			vaccine:             &datamodel.Vaccine{MP: "UNKNOWN", DN: 3, SD: 3, DT: "2021-12-01"},
+			at:                  at("2023-12-01T00:00:00Z"),
+			expectedStatus:      vaccination.StatusBooster,
+			expectedExplanation: "dose 3 of 3 given 2021-12-01, a booster after a 3 dose series",
+		},
+		{
+			name:                "should be booster if more doses than the series",
+			vaccine:             &datamodel.Vaccine{MP: vaccination.ProductSpikevax, DN: 3, SD: 2, DT: "2021-12-01"},
+			at:                  at("2021-12-02T00:00:00Z"),
+			expectedStatus:      vaccination.StatusBooster,
+			expectedExplanation: "dose 3 of 2 given 2021-12-01, a booster after a 2 dose series",
+		},
+		{
+			name:    "should expire a booster if configured",
+			vaccine: &datamodel.Vaccine{MP: vaccination.ProductSpikevax, DN: 3, SD: 3, DT: "2021-12-01"},
+			at:      at("2022-12-01T00:00:00Z"),
+			opts: &vaccination.Options{
+				BoosterMaxValidity: 365 * 24 * time.Hour,
+			},
+			expectedStatus:      vaccination.StatusExpiredComplete,
+			expectedExplanation: "dose 3 of 3 given 2021-12-01, the booster expired on 2022-12-01 after 365 days",
+			expectedValidUntil:  "2022-12-01",
+		},
+		{
+			name:    "should use the product waiting period and no max validity",
+			vaccine: &datamodel.Vaccine{MP: vaccination.ProductJanssen, DN: 1, SD: 1, DT: "2021-06-01"},
+			at:      at("2021-06-20T00:00:00Z"),
+			opts: &vaccination.Options{
+				WaitingPeriod: 14 * 24 * time.Hour,
+				Products: map[string]*vaccination.ProductRule{
+					vaccination.ProductJanssen: {Doses: 1, WaitingPeriod: 28 * 24 * time.Hour},
+				},
+			},
+			expectedStatus:      vaccination.StatusPartial,
+			expectedExplanation: "dose 1 of 1 given 2021-06-01, complete from 2021-06-29 after the 28 day waiting period",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			opts := tc.opts
+			if opts == nil {
+				opts = vaccination.DefaultOptions()
+			}
+
+			evaluation, err := vaccination.Evaluate(tc.vaccine, tc.at, opts)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedStatus, evaluation.Status)
+			require.Equal(t, tc.expectedExplanation, evaluation.Explanation)
+
+			validUntil := ""
+			if !evaluation.ValidUntil.IsZero() {
+				validUntil = evaluation.ValidUntil.Format(datamodel.DateLayout)
+			}
+			require.Equal(t, tc.expectedValidUntil, validUntil)
+		})
+	}
+}
+
+func Test_Evaluate_Errors(t *testing.T) {
+
+	type testCase struct {
+		name    string
+		vaccine *datamodel.Vaccine
+	}
+
+	testCases := []testCase{
+		{name: "should error if no vaccination", vaccine: nil},
+		{name: "should error if no dose number", vaccine: &datamodel.Vaccine{DN: 0, SD: 2, DT: "2021-06-01"}},
+		{name: "should error if no series doses", vaccine: &datamodel.Vaccine{DN: 1, SD: 0, DT: "2021-06-01"}},
+		{name: "should error if the date is not a full date", vaccine: &datamodel.Vaccine{DN: 1, SD: 2, DT: "2021-06"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := vaccination.Evaluate(tc.vaccine, time.Now(), nil)
+			require.Error(t, err)
+		})
+	}
+}
+
+func Test_EvaluateDCC(t *testing.T) {
+
+	decodeOutput, err := helper.NewDecoder(false, false).FromFileQRCode("../testfiles/dcc-testdata/DE/2DCode/png/1.png")
+	require.NoError(t, err)
+
+	dcc := decodeOutput.DCC()
+	require.NotNil(t, dcc)
+	require.NotEmpty(t, dcc.Vaccine)
+
+	evaluation, err := vaccination.EvaluateDCC(dcc, time.Now(), nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, evaluation.Status)
+	require.Contains(t, evaluation.Explanation, dcc.Vaccine[0].DT)
+
+	_, err = vaccination.EvaluateDCC(&datamodel.DCC{}, time.Now(), nil)
+	require.Error(t, err, "should error if no vaccination entry")
+}
@@ -0,0 +1,12 @@
+package valuesetdata
+
+import "embed"
+
+//
+// The EU DCC value sets, see https://github.com/ehn-dcc-development/ehn-dcc-valuesets, and the versioned mapping of
+// their codes to CVX, MVX, SNOMED and ATC. Embedded so the library can map codes without the files on disk
+//
+
+//Files the value set JSON files
+//go:embed *.json
+var Files embed.FS
@@ -0,0 +1,53 @@
+package verifier
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/webshield-dev/eudvcdecoder/helper"
+)
+
+//
+// X.509 chain validation from the Document Signer Certificate (DSC) to a Country Signing CA (CSCA)
+// see https://ec.europa.eu/health/sites/default/files/ehealth/docs/digital-green-certificates_v2_en.pdf
+// section 5
+//
+// A DSC is only trusted if it chains to a CSCA, a KID match only shows the key is known. The validity
+// periods are checked at the time the credential was issued, a credential signed while the DSC was valid
+// stays valid after the DSC expires.
+//
+
+//issuedAt the time the credential was issued from the CWT iat claim, if there is none uses now, the validation
+//clock
+func issuedAt(decodeOutput *helper.Output, now time.Time) time.Time {
+
+	if decodeOutput.CommonPayload != nil && decodeOutput.CommonPayload.IAT != 0 {
+		return time.Unix(int64(decodeOutput.CommonPayload.IAT), 0)
+	}
+
+	return now
+}
+
+//verifyCertificateChain returns the chain from the DSC to one of the CSCA roots, an error if there is no
+//such chain at the time the credential was issued, or at now if there is no iat
+func verifyCertificateChain(dsc *x509.Certificate, cscaRoots *x509.CertPool,
+	decodeOutput *helper.Output, now time.Time) ([]*x509.Certificate, error) {
+
+	if dsc == nil {
+		return nil, fmt.Errorf("error no DSC to validate the chain, only the public key is known")
+	}
+
+	chains, err := dsc.Verify(x509.VerifyOptions{
+		Roots:       cscaRoots,
+		CurrentTime: issuedAt(decodeOutput, now),
+
+		//the DSC extended key usages are EU DCC specific and checked separately
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error validating DSC chain subject=%s err=%s", dsc.Subject, err)
+	}
+
+	return chains[0], nil
+}
@@ -0,0 +1,346 @@
+package verifier_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+	"github.com/webshield-dev/eudvcdecoder/internal/testvectors"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+//testCertificate a generated certificate and its key
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+//makeCSCA make a self signed Country Signing CA
+func makeCSCA(t *testing.T, country string) *testCertificate {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Country: []string{country}, CommonName: country + " CSCA"},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2035, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCertificate{cert: cert, key: key}
+}
+
+//makeDSC make a Document Signer Certificate issued by the CSCA with the extended key usages
+func makeDSC(t *testing.T, csca *testCertificate, notBefore time.Time, notAfter time.Time,
+	ekus ...asn1.ObjectIdentifier) *testCertificate {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	return issueDSC(t, csca, key, notBefore, notAfter, ekus...)
+}
+
+//renewDSC a new DSC for the same key with the extended key usages
+func renewDSC(t *testing.T, csca *testCertificate, dsc *testCertificate, ekus ...asn1.ObjectIdentifier) *testCertificate {
+	return issueDSC(t, csca, dsc.key, dsc.cert.NotBefore, dsc.cert.NotAfter, ekus...)
+}
+
+//issueDSC issue a Document Signer Certificate for the key
+func issueDSC(t *testing.T, csca *testCertificate, key *ecdsa.PrivateKey, notBefore time.Time, notAfter time.Time,
+	ekus ...asn1.ObjectIdentifier) *testCertificate {
+
+	template := &x509.Certificate{
+		SerialNumber:       big.NewInt(time.Now().UnixNano()),
+		Subject:            pkix.Name{Country: csca.cert.Subject.Country, CommonName: "DSC"},
+		NotBefore:          notBefore,
+		NotAfter:           notAfter,
+		KeyUsage:           x509.KeyUsageDigitalSignature,
+		UnknownExtKeyUsage: ekus,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, csca.cert, &key.PublicKey, csca.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCertificate{cert: cert, key: key}
+}
+
+//signingKey the DSC as a verifier.SigningKey
+func (tc *testCertificate) signingKey() *verifier.SigningKey {
+	kid := sha256.Sum256(tc.cert.Raw)
+	return &verifier.SigningKey{
+		Kid:         kid[:8],
+		Country:     tc.cert.Subject.Country[0],
+		PublicKey:   tc.cert.PublicKey,
+		Certificate: tc.cert,
+	}
+}
+
+func Test_Verify_Certificate_Chain(t *testing.T) {
+
+	//re-sign a known payload with generated DSCs as the test data does not include the CSCAs
+	tv, _ := testvectors.Read(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+	decodeOutput, err := helper.NewDecoder(false, false).FromQRCodeContents([]byte(tv.Prefix))
+	require.NoError(t, err)
+	iat := time.Unix(int64(decodeOutput.CommonPayload.IAT), 0)
+
+	csca := makeCSCA(t, "DE")
+	otherCSCA := makeCSCA(t, "DE")
+
+	cscaRoots := x509.NewCertPool()
+	cscaRoots.AddCert(csca.cert)
+
+	type testCase struct {
+		name            string
+		dsc             *testCertificate
+		cscaRoots       *x509.CertPool
+		withCertificate bool
+		expectedTrusted bool
+	}
+
+	testCases := []testCase{
+		{
+			name:            "should trust DSC issued by CSCA",
+			dsc:             makeDSC(t, csca, iat.AddDate(0, -1, 0), iat.AddDate(1, 0, 0)),
+			cscaRoots:       cscaRoots,
+			withCertificate: true,
+			expectedTrusted: true,
+		},
+		{
+			name:            "should trust DSC that expired after the credential was issued",
+			dsc:             makeDSC(t, csca, iat.AddDate(0, -1, 0), iat.AddDate(0, 0, 1)),
+			cscaRoots:       cscaRoots,
+			withCertificate: true,
+			expectedTrusted: true,
+		},
+		{
+			name:            "should not trust DSC that was not valid when the credential was issued",
+			dsc:             makeDSC(t, csca, iat.AddDate(0, 0, 1), iat.AddDate(1, 0, 0)),
+			cscaRoots:       cscaRoots,
+			withCertificate: true,
+		},
+		{
+			name:            "should not trust DSC issued by another CSCA",
+			dsc:             makeDSC(t, otherCSCA, iat.AddDate(0, -1, 0), iat.AddDate(1, 0, 0)),
+			cscaRoots:       cscaRoots,
+			withCertificate: true,
+		},
+		{
+			name:            "should not trust if only the public key is known",
+			dsc:             makeDSC(t, csca, iat.AddDate(0, -1, 0), iat.AddDate(1, 0, 0)),
+			cscaRoots:       cscaRoots,
+			withCertificate: false,
+		},
+		{
+			name:            "should not trust if no CSCAs configured",
+			dsc:             makeDSC(t, csca, iat.AddDate(0, -1, 0), iat.AddDate(1, 0, 0)),
+			withCertificate: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			signingKey := tc.dsc.signingKey()
+			qrCodeContents := signQRCodeContents(t, decodeOutput.SignedCWT.Payload, signingKey.Kid, tc.dsc.key)
+			if !tc.withCertificate {
+				signingKey.Certificate = nil
+			}
+
+			dgVerifier, err := verifier.NewVerifier(false, false, verifier.NewMemoryKeyResolver(signingKey))
+			require.NoError(t, err)
+
+			verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), qrCodeContents,
+				&verifier.VerifyOptions{CSCARoots: tc.cscaRoots})
+			require.NoError(t, err)
+
+			require.True(t, verifierOutput.Results.CardStructure.SignatureValid, "signature should be valid")
+			require.Equal(t, tc.expectedTrusted, verifierOutput.Results.Issuer.Trusted)
+			if tc.expectedTrusted {
+				require.Len(t, verifierOutput.CertificateChain, 2)
+				require.True(t, csca.cert.Equal(verifierOutput.CertificateChain[1]), "should chain to CSCA")
+			} else {
+				require.Nil(t, verifierOutput.CertificateChain)
+			}
+		})
+	}
+}
+
+func Test_Verify_Certificate_Chain_No_IAT(t *testing.T) {
+
+	//re-sign a known payload without its iat claim
+	tv, _ := testvectors.Read(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+	decodeOutput, err := helper.NewDecoder(false, false).FromQRCodeContents([]byte(tv.Prefix))
+	require.NoError(t, err)
+	var claims map[int]interface{}
+	require.NoError(t, cbor.Unmarshal(decodeOutput.SignedCWT.Payload, &claims))
+	delete(claims, 6)
+	payload, err := cbor.Marshal(claims)
+	require.NoError(t, err)
+
+	//the DSC was only valid in 2020
+	notBefore := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)
+	csca := makeCSCA(t, "DE")
+	dsc := makeDSC(t, csca, notBefore, notAfter)
+
+	cscaRoots := x509.NewCertPool()
+	cscaRoots.AddCert(csca.cert)
+
+	type testCase struct {
+		name            string
+		clock           verifier.Clock
+		expectedTrusted bool
+	}
+
+	testCases := []testCase{
+		{
+			name:            "should validate the chain at the validation clock",
+			clock:           verifier.FixedClock(time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)),
+			expectedTrusted: true,
+		},
+		{
+			name:            "should not trust a DSC that is not valid at the validation clock",
+			clock:           verifier.FixedClock(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)),
+			expectedTrusted: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			signingKey := dsc.signingKey()
+			qrCodeContents := signQRCodeContents(t, payload, signingKey.Kid, dsc.key)
+
+			dgVerifier, err := verifier.NewVerifier(false, false, verifier.NewMemoryKeyResolver(signingKey))
+			require.NoError(t, err)
+
+			verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), qrCodeContents,
+				&verifier.VerifyOptions{CSCARoots: cscaRoots, Clock: tc.clock})
+			require.NoError(t, err)
+			require.Zero(t, verifierOutput.DecodeOutput.CommonPayload.IAT)
+
+			require.True(t, verifierOutput.Results.CardStructure.SignatureValid, "signature should be valid")
+			require.Equal(t, tc.expectedTrusted, verifierOutput.Results.Issuer.Trusted)
+		})
+	}
+}
+
+func Test_Verify_Certificate_Chain_COSE_Sign(t *testing.T) {
+
+	testOID := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 1847, 2021, 1, 1}
+
+	//re-sign a known payload as a multi signer COSE_Sign where only some signers are trusted
+	tv, _ := testvectors.Read(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+	decodeOutput, err := helper.NewDecoder(false, false).FromQRCodeContents([]byte(tv.Prefix))
+	require.NoError(t, err)
+	iat := time.Unix(int64(decodeOutput.CommonPayload.IAT), 0)
+
+	csca := makeCSCA(t, "DE")
+	otherCSCA := makeCSCA(t, "DE")
+
+	cscaRoots := x509.NewCertPool()
+	cscaRoots.AddCert(csca.cert)
+
+	trusted := makeDSC(t, csca, iat.AddDate(0, -1, 0), iat.AddDate(1, 0, 0))
+	foreign := makeDSC(t, otherCSCA, iat.AddDate(0, -1, 0), iat.AddDate(1, 0, 0))
+	testOnly := makeDSC(t, csca, iat.AddDate(0, -1, 0), iat.AddDate(1, 0, 0), testOID)
+
+	//signer the COSE_Sign signer and the key to resolve for it
+	signer := func(dsc *testCertificate) (*coseSigner, *verifier.SigningKey) {
+		signingKey := dsc.signingKey()
+		return &coseSigner{kid: signingKey.Kid, key: dsc.key}, signingKey
+	}
+
+	type testCase struct {
+		name string
+		dscs []*testCertificate
+
+		expectedValid   bool
+		expectedTrusted bool
+		expectedCert    *testCertificate
+	}
+
+	testCases := []testCase{
+		{
+			name:            "should use a later signer that chains to a CSCA",
+			dscs:            []*testCertificate{foreign, trusted},
+			expectedValid:   true,
+			expectedTrusted: true,
+			expectedCert:    trusted,
+		},
+		{
+			name:            "should use a later signer that is allowed to sign a vaccination",
+			dscs:            []*testCertificate{testOnly, trusted},
+			expectedValid:   true,
+			expectedTrusted: true,
+			expectedCert:    trusted,
+		},
+		{
+			name:            "should prefer a signer allowed to sign a vaccination if none chain",
+			dscs:            []*testCertificate{testOnly, foreign},
+			expectedValid:   true,
+			expectedTrusted: false,
+			expectedCert:    foreign,
+		},
+		{
+			name:            "should fail the chain if no signer chains to a CSCA",
+			dscs:            []*testCertificate{foreign},
+			expectedValid:   true,
+			expectedTrusted: false,
+			expectedCert:    foreign,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			var signers []*coseSigner
+			var keys []*verifier.SigningKey
+			for _, dsc := range tc.dscs {
+				coseSigner, signingKey := signer(dsc)
+				signers = append(signers, coseSigner)
+				keys = append(keys, signingKey)
+			}
+			qrCodeContents := signCOSESignQRCodeContents(t, decodeOutput.SignedCWT.Payload, signers...)
+
+			dgVerifier, err := verifier.NewVerifier(false, false, verifier.NewMemoryKeyResolver(keys...))
+			require.NoError(t, err)
+
+			verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), qrCodeContents,
+				&verifier.VerifyOptions{CSCARoots: cscaRoots})
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedValid, verifierOutput.Results.CardStructure.SignatureValid)
+			require.Equal(t, tc.expectedTrusted, verifierOutput.Results.Issuer.Trusted)
+			require.Equal(t, tc.expectedCert.cert, verifierOutput.SigningKey.Certificate)
+			require.NotContains(t, verifierOutput.FailureReasons, verifier.FailureReasonKeyUsage)
+			if tc.expectedTrusted {
+				require.NotContains(t, verifierOutput.FailureReasons, verifier.FailureReasonCertificateChain)
+			} else {
+				require.Contains(t, verifierOutput.FailureReasons, verifier.FailureReasonCertificateChain)
+			}
+		})
+	}
+}
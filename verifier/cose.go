@@ -0,0 +1,177 @@
+package verifier
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/fxamacker/cbor/v2"
+	eudvcdatamodel "github.com/webshield-dev/eudvcdecoder/datamodel"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+)
+
+//
+// COSE_Sign1 signature verification see https://datatracker.ietf.org/doc/html/rfc8152#section-4.4
+//
+// The issuer signs the CBOR encoded Sig_structure, so to verify we rebuild it from the exact
+// protected header and payload bytes in the message and check the signature with the issuers public key.
+// A COSE_Sign has several signers, each signs a Sig_structure that also has their own protected header
+//
+
+//signingAlgorithm returns the COSE algorithm from the protected header, falling back to the unprotected
+//header as some issuers put it there
+func signingAlgorithm(headers *eudvcdatamodel.COSEHeaders) (int, error) {
+
+	alg := headers.Merged().Alg
+	if alg == nil {
+		return 0, fmt.Errorf("error no signing algorithm in the COSE headers")
+	}
+	if alg.Text != "" {
+		return 0, fmt.Errorf("error unsupported COSE signing algorithm=%s only ES256(-7) and PS256(-37)", alg)
+	}
+
+	return alg.Int, nil
+}
+
+//keyID returns the key identifier (kid) of the signature from its protected header, falling back to the
+//unprotected header as some issuers put it there
+func keyID(headers *eudvcdatamodel.COSEHeaders) ([]byte, error) {
+
+	if kid := headers.Merged().Kid; len(kid) != 0 {
+		return kid, nil
+	}
+
+	return nil, fmt.Errorf("error no kid in the COSE headers")
+}
+
+//verifyCOSESignature verify one signature of the decoded COSE message with the headers the decoder read
+//within its limits, see VerifyCOSESign1 and VerifyCOSESign
+func verifyCOSESignature(decodeOutput *helper.Output, candidate *signerKey) (bool, error) {
+
+	if decodeOutput.SignedCWT == nil {
+		return false, fmt.Errorf("error no COSE message to verify")
+	}
+
+	if decodeOutput.COSeCBORTag == eudvcdatamodel.COSESignTag {
+		return verifyCOSESign(decodeOutput.SignedCWT, candidate.signature, candidate.headers,
+			candidate.key.PublicKey)
+	}
+
+	return verifyCOSESign1(decodeOutput.SignedCWT, candidate.headers, candidate.key.PublicKey)
+}
+
+//VerifyCOSESign1 returns true if the COSE_Sign1 signature was produced by the private key for publicKey,
+//an error means the signature could not be checked, for example an unsupported algorithm. Only supports the
+//algorithms used by the EU DCC ES256 and PS256. The headers are decoded with the default decoder limits
+func VerifyCOSESign1(signedCWT *eudvcdatamodel.SignedCWT, publicKey crypto.PublicKey) (bool, error) {
+
+	decMode, err := helper.DefaultDecoderOptions().DecMode()
+	if err != nil {
+		return false, err
+	}
+	headers, err := signedCWT.Headers(decMode)
+	if err != nil {
+		return false, err
+	}
+
+	return verifyCOSESign1(signedCWT, headers, publicKey)
+}
+
+//verifyCOSESign1 see VerifyCOSESign1, headers are the decoded message headers
+func verifyCOSESign1(signedCWT *eudvcdatamodel.SignedCWT, headers *eudvcdatamodel.COSEHeaders,
+	publicKey crypto.PublicKey) (bool, error) {
+
+	alg, err := signingAlgorithm(headers)
+	if err != nil {
+		return false, err
+	}
+
+	tbs, err := cbor.Marshal(signedCWT.SigStructure(nil))
+	if err != nil {
+		return false, fmt.Errorf("error cbor.Marshal Sig_structure err=%s", err)
+	}
+
+	return verifySignature(alg, tbs, signedCWT.Signature, publicKey)
+}
+
+//VerifyCOSESign returns true if the signature of one signer of a COSE_Sign was produced by the private key for
+//publicKey, signedCWT has the message headers and payload. See VerifyCOSESign1
+func VerifyCOSESign(signedCWT *eudvcdatamodel.SignedCWT, signature *eudvcdatamodel.COSESignature,
+	publicKey crypto.PublicKey) (bool, error) {
+
+	decMode, err := helper.DefaultDecoderOptions().DecMode()
+	if err != nil {
+		return false, err
+	}
+	headers, err := signature.Headers(decMode)
+	if err != nil {
+		return false, err
+	}
+
+	return verifyCOSESign(signedCWT, signature, headers, publicKey)
+}
+
+//verifyCOSESign see VerifyCOSESign, headers are the decoded signer headers
+func verifyCOSESign(signedCWT *eudvcdatamodel.SignedCWT, signature *eudvcdatamodel.COSESignature,
+	headers *eudvcdatamodel.COSEHeaders, publicKey crypto.PublicKey) (bool, error) {
+
+	alg, err := signingAlgorithm(headers)
+	if err != nil {
+		return false, err
+	}
+
+	tbs, err := cbor.Marshal(signedCWT.SignerSigStructure(signature, nil))
+	if err != nil {
+		return false, fmt.Errorf("error cbor.Marshal Sig_structure err=%s", err)
+	}
+
+	return verifySignature(alg, tbs, signature.Signature, publicKey)
+}
+
+//verifySignature true if signature is the alg signature of the CBOR encoded Sig_structure tbs
+func verifySignature(alg int, tbs []byte, signature []byte, publicKey crypto.PublicKey) (bool, error) {
+
+	digest := sha256.Sum256(tbs)
+
+	switch alg {
+
+	case eudvcdatamodel.COSEAlgES256:
+		{
+			ecKey, ok := publicKey.(*ecdsa.PublicKey)
+			if !ok {
+				return false, fmt.Errorf("error ES256 requires an ECDSA public key got=%T", publicKey)
+			}
+
+			//COSE uses the fixed length r|s encoding not ASN.1 see https://datatracker.ietf.org/doc/html/rfc8152#section-8.1
+			keySize := (ecKey.Curve.Params().BitSize + 7) / 8
+			if len(signature) != 2*keySize {
+				return false, nil
+			}
+
+			r := new(big.Int).SetBytes(signature[:keySize])
+			s := new(big.Int).SetBytes(signature[keySize:])
+
+			return ecdsa.Verify(ecKey, digest[:], r, s), nil
+		}
+
+	case eudvcdatamodel.COSEAlgPS256:
+		{
+			rsaKey, ok := publicKey.(*rsa.PublicKey)
+			if !ok {
+				return false, fmt.Errorf("error PS256 requires an RSA public key got=%T", publicKey)
+			}
+
+			//see https://datatracker.ietf.org/doc/html/rfc8230#section-2 the salt length is the hash length
+			opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+			err := rsa.VerifyPSS(rsaKey, crypto.SHA256, digest[:], signature, opts)
+
+			return err == nil, nil
+		}
+
+	default:
+		return false, fmt.Errorf("error unsupported COSE signing algorithm=%d only ES256(-7) and PS256(-37)", alg)
+	}
+}
@@ -0,0 +1,353 @@
+package verifier_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/dasio/base45"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/verification"
+	"github.com/webshield-dev/eudvcdecoder/datamodel"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+	"github.com/webshield-dev/eudvcdecoder/internal/testvectors"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+//signQRCodeContents sign the CWT payload with the key, ES256 for an ECDSA key and PS256 for an RSA key, and
+//encode as QR code contents
+func signQRCodeContents(t *testing.T, payload []byte, kid []byte, key crypto.Signer) []byte {
+
+	alg := datamodel.COSEAlgES256
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		alg = datamodel.COSEAlgPS256
+	}
+
+	header := map[int]interface{}{1: alg}
+	if kid != nil {
+		header[4] = kid
+	}
+
+	return signHeaderQRCodeContents(t, payload, header, key)
+}
+
+//signHeaderQRCodeContents sign the CWT payload with the key, PS256 for an RSA key otherwise ES256, whatever the
+//header alg, and encode as QR code contents
+func signHeaderQRCodeContents(t *testing.T, payload []byte, header map[int]interface{}, key crypto.Signer) []byte {
+
+	protected, err := cbor.Marshal(header)
+	require.NoError(t, err)
+
+	signedCWT := &datamodel.SignedCWT{
+		Protected: protected,
+		Payload:   payload,
+	}
+	tbs, err := cbor.Marshal(signedCWT.SigStructure(nil))
+	require.NoError(t, err)
+	signedCWT.Signature = coseSignature(t, tbs, key)
+
+	coseB, err := cbor.Marshal(cbor.Tag{Number: 18, Content: signedCWT})
+	require.NoError(t, err)
+
+	return compressedQRCodeContents(t, coseB)
+}
+
+//coseSigner a COSE_Sign signer, the kid may not be the kid of the key
+type coseSigner struct {
+	kid []byte
+	key *ecdsa.PrivateKey
+}
+
+//signCOSESignQRCodeContents sign the CWT payload as a COSE_Sign with a ES256 signature per signer, and encode as
+//QR code contents
+func signCOSESignQRCodeContents(t *testing.T, payload []byte, signers ...*coseSigner) []byte {
+
+	signedCWT := &datamodel.SignedCWT{Payload: payload}
+	sign := &datamodel.COSESign{Payload: payload}
+
+	for _, signer := range signers {
+		protected, err := cbor.Marshal(map[int]interface{}{1: datamodel.COSEAlgES256, 4: signer.kid})
+		require.NoError(t, err)
+
+		signature := &datamodel.COSESignature{Protected: protected}
+		tbs, err := cbor.Marshal(signedCWT.SignerSigStructure(signature, nil))
+		require.NoError(t, err)
+		signature.Signature = coseSignature(t, tbs, signer.key)
+
+		sign.Signatures = append(sign.Signatures, signature)
+	}
+
+	coseB, err := cbor.Marshal(cbor.Tag{Number: datamodel.COSESignTag, Content: sign})
+	require.NoError(t, err)
+
+	return compressedQRCodeContents(t, coseB)
+}
+
+//coseSignature sign the CBOR encoded Sig_structure, PS256 for an RSA key otherwise ES256 as r||s
+func coseSignature(t *testing.T, tbs []byte, key crypto.Signer) []byte {
+
+	digest := sha256.Sum256(tbs)
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err := rsa.SignPSS(rand.Reader, key, crypto.SHA256, digest[:],
+			&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		require.NoError(t, err)
+		return signature
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature
+	default:
+		require.Failf(t, "unsupported key", "%T", key)
+		return nil
+	}
+}
+
+//compressedQRCodeContents zlib compress and base45 encode the COSE message
+func compressedQRCodeContents(t *testing.T, coseB []byte) []byte {
+
+	compressed := &bytes.Buffer{}
+	zw := zlib.NewWriter(compressed)
+	_, err := zw.Write(coseB)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	return []byte(datamodel.QRCodePrefix + ":" + base45.EncodeToString(compressed.Bytes()))
+}
+
+//validationClock the TESTCTX.VALIDATIONCLOCK, some are RFC3339 and some have no time zone so are UTC
+func validationClock(t *testing.T, tv *testvectors.Vector) verifier.Clock {
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if clock, err := time.Parse(layout, tv.TestCtx.ValidationClock); err == nil {
+			return verifier.FixedClock(clock)
+		}
+	}
+
+	require.Failf(t, "could not parse VALIDATIONCLOCK", "%s", tv.TestCtx.ValidationClock)
+	return nil
+}
+
+func Test_Verify_COSE_Signature(t *testing.T) {
+
+	type testCase struct {
+		name              string
+		vectorPath        string
+		keyPath           string // the vector whose certificate to use, empty means same as vectorPath
+		expectedValid     bool
+		expectedCardState verification.CardVerificationState
+	}
+
+	testCases := []testCase{
+		{
+			name:              "should verify German ES256 vaccine",
+			vectorPath:        "../testfiles/dcc-testdata/DE/2DCode/raw/1.json",
+			expectedValid:     true,
+			expectedCardState: verification.CardVerificationStateIssuerUnknown,
+		},
+		{
+			name:              "should verify Austrian ES256 recovery, kid and alg in protected header",
+			vectorPath:        "../testfiles/dcc-testdata/AT/2DCode/raw/2.json",
+			expectedValid:     true,
+			expectedCardState: verification.CardVerificationStateUnknown,
+		},
+		{
+			name:              "should verify Greek ES256 test",
+			vectorPath:        "../testfiles/dcc-testdata/GR/2DCode/raw/3.json",
+			expectedValid:     true,
+			expectedCardState: verification.CardVerificationStateUnknown,
+		},
+		{
+			name:              "should be corrupt if signed by another key",
+			vectorPath:        "../testfiles/dcc-testdata/DE/2DCode/raw/1.json",
+			keyPath:           "../testfiles/dcc-testdata/GR/2DCode/raw/1.json",
+			expectedValid:     false,
+			expectedCardState: verification.CardVerificationStateCorrupt,
+		},
+	}
+
+	dgVerifier, err := verifier.NewVerifier(true, true, nil)
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			tv, cert := testvectors.Read(t, tc.vectorPath)
+			if tc.keyPath != "" {
+				_, cert = testvectors.Read(t, tc.keyPath)
+			}
+
+			opts := &verifier.VerifyOptions{PublicKey: cert.PublicKey}
+			verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), []byte(tv.Prefix), opts)
+			require.NoError(t, err)
+
+			require.True(t, verifierOutput.Results.CardStructure.SignatureChecked, "should have checked signature")
+			require.Equal(t, tc.expectedValid, verifierOutput.Results.CardStructure.SignatureValid)
+			require.Equal(t, tc.expectedCardState, verifierOutput.Results.State)
+		})
+	}
+}
+
+func Test_Verify_COSE_Signature_PS256(t *testing.T) {
+
+	//re-sign a known payload with an RSA key as none of the test data uses PS256
+	tv, _ := testvectors.Read(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+	decodeOutput, err := helper.NewDecoder(false, false).FromQRCodeContents([]byte(tv.Prefix))
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	qrCodeContents := signQRCodeContents(t, decodeOutput.SignedCWT.Payload, nil, rsaKey)
+
+	dgVerifier, err := verifier.NewVerifier(true, true, nil)
+	require.NoError(t, err)
+
+	verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), qrCodeContents,
+		&verifier.VerifyOptions{PublicKey: &rsaKey.PublicKey})
+	require.NoError(t, err)
+	require.True(t, verifierOutput.Results.CardStructure.SignatureValid, "should be valid")
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	verifierOutput, err = dgVerifier.FromQRCodeContents(context.TODO(), qrCodeContents,
+		&verifier.VerifyOptions{PublicKey: &otherKey.PublicKey})
+	require.NoError(t, err)
+	require.False(t, verifierOutput.Results.CardStructure.SignatureValid, "should not be valid")
+	require.Equal(t, verification.CardVerificationStateCorrupt, verifierOutput.Results.State)
+}
+
+func Test_Verify_COSE_Sign(t *testing.T) {
+
+	//re-sign a known payload as a multi signer COSE_Sign
+	tv, _ := testvectors.Read(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+	decodeOutput, err := helper.NewDecoder(false, false).FromQRCodeContents([]byte(tv.Prefix))
+	require.NoError(t, err)
+	payload := decodeOutput.SignedCWT.Payload
+
+	csca := makeCSCA(t, "DE")
+	trusted := makeDSC(t, csca, csca.cert.NotBefore, csca.cert.NotAfter)
+	untrusted := makeDSC(t, csca, csca.cert.NotBefore, csca.cert.NotAfter)
+	trustedSigner := &coseSigner{kid: trusted.signingKey().Kid, key: trusted.key}
+	untrustedSigner := &coseSigner{kid: untrusted.signingKey().Kid, key: untrusted.key}
+
+	type testCase struct {
+		name    string
+		signers []*coseSigner
+		keys    []*verifier.SigningKey
+
+		expectedChecked bool
+		expectedValid   bool
+	}
+
+	testCases := []testCase{
+		{
+			name:            "should verify if the first signer is trusted",
+			signers:         []*coseSigner{trustedSigner, untrustedSigner},
+			keys:            []*verifier.SigningKey{trusted.signingKey()},
+			expectedChecked: true,
+			expectedValid:   true,
+		},
+		{
+			name:            "should verify if the second signer is trusted",
+			signers:         []*coseSigner{untrustedSigner, trustedSigner},
+			keys:            []*verifier.SigningKey{trusted.signingKey()},
+			expectedChecked: true,
+			expectedValid:   true,
+		},
+		{
+			name:            "should not be valid if the trusted kid was signed by another key",
+			signers:         []*coseSigner{untrustedSigner, {kid: trustedSigner.kid, key: untrusted.key}},
+			keys:            []*verifier.SigningKey{trusted.signingKey()},
+			expectedChecked: true,
+			expectedValid:   false,
+		},
+		{
+			name:            "should not check if no signer is trusted",
+			signers:         []*coseSigner{untrustedSigner},
+			keys:            []*verifier.SigningKey{trusted.signingKey()},
+			expectedChecked: false,
+			expectedValid:   false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			qrCodeContents := signCOSESignQRCodeContents(t, payload, tc.signers...)
+
+			dgVerifier, err := verifier.NewVerifier(false, false, verifier.NewMemoryKeyResolver(tc.keys...))
+			require.NoError(t, err)
+
+			verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), qrCodeContents, nil)
+			require.NoError(t, err)
+			require.Equal(t, datamodel.COSESignTag, verifierOutput.DecodeOutput.COSeCBORTag)
+			require.Len(t, verifierOutput.DecodeOutput.Signatures, len(tc.signers))
+
+			require.Equal(t, tc.expectedChecked, verifierOutput.Results.CardStructure.SignatureChecked)
+			require.Equal(t, tc.expectedValid, verifierOutput.Results.CardStructure.SignatureValid)
+			if tc.expectedValid {
+				require.Equal(t, trusted.cert, verifierOutput.SigningKey.Certificate)
+				require.Equal(t, datamodel.HeaderBucketProtected, verifierOutput.SigningKidBucket)
+			}
+		})
+	}
+}
+
+func Test_Verify_COSE_Unsupported_Algorithm(t *testing.T) {
+
+	tv, _ := testvectors.Read(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+	decodeOutput, err := helper.NewDecoder(false, false).FromQRCodeContents([]byte(tv.Prefix))
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	type testCase struct {
+		name string
+		alg  interface{}
+
+		expectedAlg *datamodel.COSEAlgorithm
+	}
+
+	testCases := []testCase{
+		{
+			name:        "should decode a tstr alg and not verify it",
+			alg:         "ES256",
+			expectedAlg: &datamodel.COSEAlgorithm{Text: "ES256"},
+		},
+		{
+			name:        "should not verify an int alg other than ES256 or PS256",
+			alg:         -35,
+			expectedAlg: datamodel.IntAlgorithm(-35),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			qrCodeContents := signHeaderQRCodeContents(t, decodeOutput.SignedCWT.Payload,
+				map[int]interface{}{1: tc.alg}, key)
+
+			signedOutput, err := helper.NewDecoder(false, false).FromQRCodeContents(qrCodeContents)
+			require.NoError(t, err, "should decode the headers")
+			require.Equal(t, tc.expectedAlg, signedOutput.Headers.Merged().Alg)
+
+			_, err = verifier.VerifyCOSESign1(signedOutput.SignedCWT, key.Public())
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "unsupported COSE signing algorithm")
+		})
+	}
+}
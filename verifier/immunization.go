@@ -0,0 +1,89 @@
+package verifier
+
+import (
+	"math"
+
+	"github.com/webshield-dev/dhc-common/pdm"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+	eudvcdatamodel "github.com/webshield-dev/eudvcdecoder/datamodel"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+)
+
+//
+// The dhc-common immunization checks are shared with SMART Health Cards so work on FHIR like doses coded with
+// CVX, see https://www.cdc.gov/vaccines/programs/iis/COVID-19-related-codes.html. The DCC vaccination entry
+// records the medicinal product (mp), the dose number (dn) and the date of that dose (dt), so it is mapped to
+// dn doses of the CVX vaccine for the mp. Only the latest dose has a date, the earlier doses in the series are
+// counted but have no date. The CVX code comes from the versioned code mappings, see ValueSetMapper.MPToCVX, a
+// product without a CVX code is coded with the SNOMED vaccine type (vp) so the check reports it as an unknown
+// vaccine type. The dn and sd are not trusted, the check runs even if the signature or schema is not valid, so
+// a dn or sd that is not a whole number up to the schema maximum is not checked. The checks compare the dose date
+// against the current time, not VerifyOptions.Clock
+//
+
+//maxDoses the schema maximum of the dose number (dn) and the doses in the series (sd)
+const maxDoses = 9
+
+//SNOMEDSystem the system of the DCC vp codes
+const SNOMEDSystem = "http://snomed.info/sct"
+
+//verifyImmunization run the dhc-common immunization checks on the DCC vaccination, nothing to do if the DCC is
+//not a vaccination so the state stays unknown
+func verifyImmunization(vp verification.Processor, dcc *eudvcdatamodel.DCC, vsMapper *helper.ValueSetMapper) error {
+
+	doses := immunizationDoses(dcc, vsMapper)
+	if len(doses) == 0 {
+		return nil
+	}
+
+	//the dhc-common checks only support the USA region, which trusts the CVX active vaccines
+	_, err := vp.VerifyImmunization(vaccinemd.RegionUSA, doses)
+
+	return err
+}
+
+//immunizationDoses the doses of the vaccination entry with the highest dose number, none if the DCC is not a
+//vaccination, the date is not a full date or the dn or sd is not a valid dose count
+func immunizationDoses(dcc *eudvcdatamodel.DCC, vsMapper *helper.ValueSetMapper) []*pdm.Dose {
+
+	if dcc == nil || len(dcc.Vaccine) == 0 {
+		return nil
+	}
+
+	latest := &dcc.Vaccine[0]
+	for i := range dcc.Vaccine {
+		if dcc.Vaccine[i].DN > latest.DN {
+			latest = &dcc.Vaccine[i]
+		}
+	}
+
+	doseDate, err := eudvcdatamodel.ParseDate(latest.DT)
+	if err != nil || !validDoseCount(latest.DN) || !validDoseCount(latest.SD) {
+		return nil
+	}
+
+	coding := vaccinemd.Coding{System: SNOMEDSystem, Code: latest.VP}
+	if cvx, err := vsMapper.MPToCVX(latest.MP); err == nil {
+		coding = vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: cvx}
+	}
+
+	//the latest dose is first as the checks compare the other doses dates against it
+	doses := []*pdm.Dose{
+		{
+			Coding:             coding,
+			Status:             pdm.CodeCompleted,
+			OccurrenceDateTime: doseDate.String(),
+		},
+	}
+	for i := 1; i < int(latest.DN); i++ {
+		doses = append(doses, &pdm.Dose{Coding: coding, Status: pdm.CodeCompleted})
+	}
+
+	return doses
+}
+
+//validDoseCount true if the count is a whole number from 1 to maxDoses
+func validDoseCount(count float64) bool {
+	return count >= 1 && count <= maxDoses && count == math.Trunc(count)
+}
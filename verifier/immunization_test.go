@@ -0,0 +1,125 @@
+package verifier_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/verification"
+	"github.com/webshield-dev/eudvcdecoder/datamodel"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+func Test_Verify_Immunization(t *testing.T) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	longAgo := "2021-05-29"
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(datamodel.DateLayout)
+
+	vaccination := func(mp string, dn float64, sd float64, dt string) *datamodel.DCC {
+		return &datamodel.DCC{
+			Version: "1.3.0",
+			DOB:     "1964-08-12",
+			Name:    datamodel.Name{FN: "Mustermann", FNT: "MUSTERMANN"},
+			Vaccine: []datamodel.Vaccine{{
+				TG: "840539006", VP: "1119349007", MP: mp, MA: "ORG-100031184", DN: dn, SD: sd, DT: dt,
+				CO: "DE", IS: "Robert Koch-Institut", CI: "URN:UVCI:01DE/IZ12345A/5CWLU12RNOB9RXSEOP6FG8#W",
+			}},
+		}
+	}
+
+	type testCase struct {
+		name string
+		dcc  *datamodel.DCC
+
+		expectedCardState   verification.CardVerificationState
+		expectedNotChecked  bool
+		expectedUnknownType bool
+		expectedDosesMet    bool
+	}
+
+	//the signature is valid but the issuer is not trusted, so a vaccination meeting the criteria is issuer unknown
+	testCases := []testCase{
+		{
+			name:              "should meet the criteria with two doses of Spikevax",
+			dcc:               vaccination("EU/1/20/1507", 2, 2, longAgo),
+			expectedCardState: verification.CardVerificationStateIssuerUnknown,
+			expectedDosesMet:  true,
+		},
+		{
+			name:              "should meet the criteria with one dose of Janssen",
+			dcc:               vaccination("EU/1/20/1525", 1, 1, longAgo),
+			expectedCardState: verification.CardVerificationStateIssuerUnknown,
+			expectedDosesMet:  true,
+		},
+		{
+			name:              "should not meet the criteria with one dose of Comirnaty",
+			dcc:               vaccination("EU/1/20/1528", 1, 2, longAgo),
+			expectedCardState: verification.CardVerificationStateSafetyCriteriaNotMet,
+		},
+		{
+			name:              "should not meet the criteria if the last dose was yesterday",
+			dcc:               vaccination("EU/1/20/1528", 2, 2, yesterday),
+			expectedCardState: verification.CardVerificationStateSafetyCriteriaNotMet,
+			expectedDosesMet:  true,
+		},
+		{
+			name:                "should not meet the criteria for a product with no CVX code",
+			dcc:                 vaccination("Sputnik-V", 2, 2, longAgo),
+			expectedCardState:   verification.CardVerificationStateSafetyCriteriaNotMet,
+			expectedUnknownType: true,
+		},
+		{
+			name:               "should not check a dose number above the schema maximum",
+			dcc:                vaccination("EU/1/20/1507", 1e12, 2, longAgo),
+			expectedCardState:  verification.CardVerificationStateUnknown,
+			expectedNotChecked: true,
+		},
+		{
+			name:               "should not check a dose number that is not a whole number",
+			dcc:                vaccination("EU/1/20/1507", 1.5, 2, longAgo),
+			expectedCardState:  verification.CardVerificationStateUnknown,
+			expectedNotChecked: true,
+		},
+		{
+			name:               "should not check a series length that is not a whole number",
+			dcc:                vaccination("EU/1/20/1507", 2, 2.5, longAgo),
+			expectedCardState:  verification.CardVerificationStateUnknown,
+			expectedNotChecked: true,
+		},
+	}
+
+	dgVerifier, err := verifier.NewVerifier(false, false, nil)
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			payload := &datamodel.DGCCommonPayload{
+				ISS:   "DE",
+				IAT:   uint64(time.Now().Add(-time.Hour).Unix()),
+				EXP:   uint64(time.Now().Add(time.Hour).Unix()),
+				HCERT: datamodel.HCERTMap{datamodel.HCERTMapKeyOne: tc.dcc},
+			}
+			encodeOutput, err := helper.NewEncoder().ToQRCodeContents(payload, key, []byte{1, 2, 3, 4, 5, 6, 7, 8})
+			require.NoError(t, err)
+
+			verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), encodeOutput.DecodedQRCode,
+				&verifier.VerifyOptions{PublicKey: key.Public()})
+			require.NoError(t, err)
+
+			immunization := verifierOutput.Results.Immunization
+			require.Equal(t, !tc.expectedNotChecked, immunization.VerificationPerformed)
+			require.Equal(t, tc.expectedUnknownType, immunization.UnKnownVaccineType)
+			require.Equal(t, tc.expectedDosesMet, immunization.MetDosesRequiredCriteria)
+			require.Equal(t, tc.expectedCardState, verifierOutput.Results.State)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package verifier
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+//SigningKey a candidate key that may have signed a credential
+type SigningKey struct {
+
+	//Kid the key identifier, for the EU DCC this is the first 8 bytes of the SHA-256 of the DER encoded
+	//Document Signer Certificate (DSC)
+	Kid []byte
+
+	//Country 2-letter ISO3166 code of the country that issued the key, if empty the key is not country specific
+	Country string
+
+	//PublicKey the key to verify with, *ecdsa.PublicKey for ES256 or *rsa.PublicKey for PS256
+	PublicKey crypto.PublicKey
+
+	//Certificate the Document Signer Certificate if known, nil if only the public key is known
+	Certificate *x509.Certificate
+}
+
+//KeyResolver finds the keys that may have signed a credential, implementations may be backed by a file,
+//a database, a downloaded trust list or an in memory map
+type KeyResolver interface {
+
+	//ResolveKeys returns the candidate keys for the key identifier (kid) from the COSE header and the
+	//issuing country from the CWT iss claim. Returns an empty list if no keys are known, an error is only
+	//returned if the lookup itself failed
+	ResolveKeys(ctx context.Context, kid []byte, country string) ([]*SigningKey, error)
+}
+
+//TrustListStatus optionally implemented by a KeyResolver whose keys come from a downloaded trust list,
+//so the verifier can report how old the keys are
+type TrustListStatus interface {
+
+	//TrustListFetchedAt when the trust list was fetched, zero if there is no trust list
+	TrustListFetchedAt() time.Time
+
+	//TrustListAge how long ago the trust list was fetched
+	TrustListAge() time.Duration
+}
+
+//NewMemoryKeyResolver make an in memory KeyResolver, mainly for tests
+func NewMemoryKeyResolver(keys ...*SigningKey) *MemoryKeyResolver {
+
+	mkr := &MemoryKeyResolver{keys: make(map[string][]*SigningKey)}
+	for _, key := range keys {
+		mkr.Add(key)
+	}
+
+	return mkr
+}
+
+//MemoryKeyResolver a KeyResolver that holds the keys in a map indexed by kid, safe for concurrent use
+type MemoryKeyResolver struct {
+	mutex sync.RWMutex
+	keys  map[string][]*SigningKey
+}
+
+//Add add a key, multiple keys can have the same kid
+func (mkr *MemoryKeyResolver) Add(key *SigningKey) {
+	mkr.mutex.Lock()
+	defer mkr.mutex.Unlock()
+
+	kidHex := hex.EncodeToString(key.Kid)
+	mkr.keys[kidHex] = append(mkr.keys[kidHex], key)
+}
+
+//ResolveKeys see interface, keys with no country match any country
+func (mkr *MemoryKeyResolver) ResolveKeys(ctx context.Context, kid []byte, country string) ([]*SigningKey, error) {
+	mkr.mutex.RLock()
+	defer mkr.mutex.RUnlock()
+
+	result := make([]*SigningKey, 0)
+	for _, key := range mkr.keys[hex.EncodeToString(kid)] {
+		if key.Country != "" && country != "" && !strings.EqualFold(key.Country, country) {
+			continue
+		}
+		result = append(result, key)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,97 @@
+package verifier_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/verification"
+	"github.com/webshield-dev/eudvcdecoder/internal/testvectors"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+func Test_KeyResolver(t *testing.T) {
+
+	type testCase struct {
+		name              string
+		vectorPath        string
+		keyCountry        string
+		useOptsResolver   bool
+		expectedFetched   bool
+		expectedValid     bool
+		expectedCardState verification.CardVerificationState
+	}
+
+	testCases := []testCase{
+		{
+			name:              "should find key by kid and country",
+			vectorPath:        "../testfiles/dcc-testdata/DE/2DCode/raw/1.json",
+			keyCountry:        "DE",
+			expectedFetched:   true,
+			expectedValid:     true,
+			expectedCardState: verification.CardVerificationStateIssuerUnknown,
+		},
+		{
+			name:              "should find key with no country",
+			vectorPath:        "../testfiles/dcc-testdata/GR/2DCode/raw/2.json",
+			expectedFetched:   true,
+			expectedValid:     true,
+			expectedCardState: verification.CardVerificationStateUnknown,
+		},
+		{
+			name:              "should use the resolver passed in the options",
+			vectorPath:        "../testfiles/dcc-testdata/AT/2DCode/raw/1.json",
+			keyCountry:        "AT",
+			useOptsResolver:   true,
+			expectedFetched:   true,
+			expectedValid:     true,
+			expectedCardState: verification.CardVerificationStateSafetyCriteriaNotMet,
+		},
+		{
+			name:              "should not find a key issued by another country",
+			vectorPath:        "../testfiles/dcc-testdata/DE/2DCode/raw/1.json",
+			keyCountry:        "FR",
+			expectedFetched:   false,
+			expectedValid:     false,
+			expectedCardState: verification.CardVerificationStateUnVerified,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			tv, cert := testvectors.Read(t, tc.vectorPath)
+			kid := sha256.Sum256(cert.Raw)
+			keyResolver := verifier.NewMemoryKeyResolver(&verifier.SigningKey{
+				Kid:         kid[:8],
+				Country:     tc.keyCountry,
+				PublicKey:   cert.PublicKey,
+				Certificate: cert,
+			})
+
+			var opts *verifier.VerifyOptions
+			var dgVerifier verifier.Verifier
+			var err error
+			if tc.useOptsResolver {
+				dgVerifier, err = verifier.NewVerifier(true, true, nil)
+				opts = &verifier.VerifyOptions{KeyResolver: keyResolver}
+			} else {
+				dgVerifier, err = verifier.NewVerifier(true, true, keyResolver)
+			}
+			require.NoError(t, err)
+
+			verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), []byte(tv.Prefix), opts)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedFetched, verifierOutput.Results.CardStructure.FetchedKey)
+			require.Equal(t, tc.expectedValid, verifierOutput.Results.CardStructure.SignatureValid)
+			require.Equal(t, tc.expectedCardState, verifierOutput.Results.State)
+			if tc.expectedValid {
+				require.Equal(t, cert, verifierOutput.SigningKey.Certificate, "should return the signing key")
+			} else {
+				require.Nil(t, verifierOutput.SigningKey)
+			}
+		})
+	}
+}
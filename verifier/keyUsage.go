@@ -0,0 +1,109 @@
+package verifier
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+
+	eudvcdatamodel "github.com/webshield-dev/eudvcdecoder/datamodel"
+)
+
+//
+// A DSC can be restricted to signing some certificate types by extended key usage OIDs
+// see https://ec.europa.eu/health/sites/default/files/ehealth/docs/digital-green-certificates_v1_en.pdf
+// section 5, if a DSC has none of these OIDs it can sign any type
+//
+
+//CertificateType the type of EU DCC a credential is
+type CertificateType string
+
+const (
+	//CertificateTypeVaccination the DCC has a vaccination entry (v)
+	CertificateTypeVaccination CertificateType = "vaccination"
+
+	//CertificateTypeTest the DCC has a test entry (t)
+	CertificateTypeTest CertificateType = "test"
+
+	//CertificateTypeRecovery the DCC has a recovery entry (r)
+	CertificateTypeRecovery CertificateType = "recovery"
+)
+
+//keyUsageOIDs the extended key usage OIDs for each certificate type, the spec OID, the OID with the
+//extra 0 arc used by some issuers and in the dcc-testdata, and the 0.4.0.127.0.0.2 arc
+var keyUsageOIDs = map[CertificateType][]asn1.ObjectIdentifier{
+	CertificateTypeTest: {
+		{1, 3, 6, 1, 4, 1, 1847, 2021, 1, 1},
+		{1, 3, 6, 1, 4, 1, 0, 1847, 2021, 1, 1},
+		{0, 4, 0, 127, 0, 0, 2, 1},
+	},
+	CertificateTypeVaccination: {
+		{1, 3, 6, 1, 4, 1, 1847, 2021, 1, 2},
+		{1, 3, 6, 1, 4, 1, 0, 1847, 2021, 1, 2},
+		{0, 4, 0, 127, 0, 0, 2, 2},
+	},
+	CertificateTypeRecovery: {
+		{1, 3, 6, 1, 4, 1, 1847, 2021, 1, 3},
+		{1, 3, 6, 1, 4, 1, 0, 1847, 2021, 1, 3},
+		{0, 4, 0, 127, 0, 0, 2, 3},
+	},
+}
+
+//CertificateTypeOf the type of the DCC, empty if it has no vaccination, test or recovery entry
+func CertificateTypeOf(dcc *eudvcdatamodel.DCC) CertificateType {
+	return certificateType(dcc)
+}
+
+//KeyUsageOID the extended key usage OID from the specification that restricts a DSC to signing the
+//certificate type, nil if not a certificate type
+func KeyUsageOID(certType CertificateType) asn1.ObjectIdentifier {
+	oids := keyUsageOIDs[certType]
+	if len(oids) == 0 {
+		return nil
+	}
+	return oids[0]
+}
+
+//groupCertificateTypes the certificate type of each DCC group
+var groupCertificateTypes = map[eudvcdatamodel.DCCGroup]CertificateType{
+	eudvcdatamodel.DCCGroupVaccination: CertificateTypeVaccination,
+	eudvcdatamodel.DCCGroupTest:        CertificateTypeTest,
+	eudvcdatamodel.DCCGroupRecovery:    CertificateTypeRecovery,
+}
+
+//certificateType returns the type of the DCC, see DCC.Group for a DCC with more than one of v, t, r.
+//Returns empty if none are populated
+func certificateType(dcc *eudvcdatamodel.DCC) CertificateType {
+	return groupCertificateTypes[dcc.Group()]
+}
+
+//keyUsageAllowed true if the DSC is allowed to sign the certificate type, a DSC without any of the
+//EU DCC key usage OIDs can sign all types
+func keyUsageAllowed(dsc *x509.Certificate, certType CertificateType) bool {
+
+	if dsc == nil || certType == "" {
+		return true
+	}
+
+	restricted := false
+	for oidCertType, oids := range keyUsageOIDs {
+		for _, oid := range oids {
+			if !hasExtKeyUsage(dsc, oid) {
+				continue
+			}
+			if oidCertType == certType {
+				return true
+			}
+			restricted = true
+		}
+	}
+
+	return !restricted
+}
+
+func hasExtKeyUsage(cert *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	for _, eku := range cert.UnknownExtKeyUsage {
+		if eku.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
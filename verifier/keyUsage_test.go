@@ -0,0 +1,184 @@
+package verifier_test
+
+import (
+	"context"
+	"encoding/asn1"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+	"github.com/webshield-dev/eudvcdecoder/internal/testvectors"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+//Test_Verify_Key_Usage_DCC_TestData the dcc-testdata vectors with an EXPECTEDKEYUSAGE
+func Test_Verify_Key_Usage_DCC_TestData(t *testing.T) {
+
+	var vectorPaths []string
+	for _, pattern := range []string{
+		"../testfiles/dcc-testdata/GR/2DCode/raw/*.json",
+		"../testfiles/dcc-testdata/IE/2DCode/Raw/*.json",
+		"../testfiles/dcc-testdata/NL/2DCode/raw/*.json",
+	} {
+		paths, err := filepath.Glob(pattern)
+		require.NoError(t, err)
+		vectorPaths = append(vectorPaths, paths...)
+	}
+	require.NotEmpty(t, vectorPaths)
+
+	dgVerifier, err := verifier.NewVerifier(false, false, nil)
+	require.NoError(t, err)
+
+	for _, vectorPath := range vectorPaths {
+
+		jsonB, err := helper.ReadData(vectorPath)
+		require.NoError(t, err)
+		var expected struct {
+			ExpectedResults struct {
+				ExpectedKeyUsage *bool `json:"EXPECTEDKEYUSAGE"`
+			} `json:"EXPECTEDRESULTS"`
+		}
+		require.NoError(t, json.Unmarshal(jsonB, &expected))
+		if expected.ExpectedResults.ExpectedKeyUsage == nil {
+			continue
+		}
+		expectedKeyUsage := *expected.ExpectedResults.ExpectedKeyUsage
+
+		t.Run(vectorPath, func(t *testing.T) {
+
+			tv, cert := testvectors.Read(t, vectorPath)
+			signingKey := (&testCertificate{cert: cert}).signingKey()
+			verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), []byte(tv.Prefix),
+				&verifier.VerifyOptions{
+					KeyResolver: verifier.NewMemoryKeyResolver(signingKey),
+					Clock:       validationClock(t, tv),
+				})
+			require.NoError(t, err)
+
+			require.NotNil(t, verifierOutput.SigningKey, "signature should verify")
+			require.Equal(t, expectedKeyUsage, verifierOutput.Results.CardStructure.SignatureValid)
+			if expectedKeyUsage {
+				require.Empty(t, verifierOutput.FailureReasons)
+			} else {
+				require.Equal(t, []verifier.FailureReason{verifier.FailureReasonKeyUsage}, verifierOutput.FailureReasons)
+			}
+		})
+	}
+}
+
+func Test_Verify_Key_Usage(t *testing.T) {
+
+	testOID := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 1847, 2021, 1, 1}
+	vaccinationOID := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 1847, 2021, 1, 2}
+	alternateVaccinationOID := asn1.ObjectIdentifier{0, 4, 0, 127, 0, 0, 2, 2}
+
+	//the German vector is a vaccination
+	tv, _ := testvectors.Read(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+	decodeOutput, err := helper.NewDecoder(false, false).FromQRCodeContents([]byte(tv.Prefix))
+	require.NoError(t, err)
+	require.NotEmpty(t, decodeOutput.DCC().Vaccine)
+
+	csca := makeCSCA(t, "DE")
+	notBefore := csca.cert.NotBefore
+	notAfter := csca.cert.NotAfter
+
+	type testCase struct {
+		name          string
+		ekus          []asn1.ObjectIdentifier
+		expectedValid bool
+	}
+
+	testCases := []testCase{
+		{name: "should allow DSC with no key usage", expectedValid: true},
+		{name: "should allow vaccination DSC", ekus: []asn1.ObjectIdentifier{vaccinationOID}, expectedValid: true},
+		{name: "should allow alternate vaccination OID", ekus: []asn1.ObjectIdentifier{alternateVaccinationOID},
+			expectedValid: true},
+		{name: "should allow multi type DSC", ekus: []asn1.ObjectIdentifier{testOID, vaccinationOID},
+			expectedValid: true},
+		{name: "should not allow test only DSC", ekus: []asn1.ObjectIdentifier{testOID}, expectedValid: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			dsc := makeDSC(t, csca, notBefore, notAfter, tc.ekus...)
+			signingKey := dsc.signingKey()
+			qrCodeContents := signQRCodeContents(t, decodeOutput.SignedCWT.Payload, signingKey.Kid, dsc.key)
+
+			dgVerifier, err := verifier.NewVerifier(false, false, verifier.NewMemoryKeyResolver(signingKey))
+			require.NoError(t, err)
+
+			verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), qrCodeContents, nil)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedValid, verifierOutput.Results.CardStructure.SignatureValid)
+			if !tc.expectedValid {
+				require.Contains(t, verifierOutput.FailureReasons, verifier.FailureReasonKeyUsage)
+			}
+		})
+	}
+}
+
+func Test_Verify_Key_Usage_Renewed_DSC(t *testing.T) {
+
+	testOID := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 1847, 2021, 1, 1}
+	vaccinationOID := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 1847, 2021, 1, 2}
+
+	tv, _ := testvectors.Read(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+	decodeOutput, err := helper.NewDecoder(false, false).FromQRCodeContents([]byte(tv.Prefix))
+	require.NoError(t, err)
+
+	csca := makeCSCA(t, "DE")
+	testOnly := makeDSC(t, csca, csca.cert.NotBefore, csca.cert.NotAfter, testOID)
+	renewed := renewDSC(t, csca, testOnly, vaccinationOID)
+
+	//both DSCs have the same key so both verify the signature, they are resolved by the same kid
+	testOnlyKey := testOnly.signingKey()
+	renewedKey := renewed.signingKey()
+	renewedKey.Kid = testOnlyKey.Kid
+
+	type testCase struct {
+		name          string
+		keys          []*verifier.SigningKey
+		expectedValid bool
+		expectedCert  *testCertificate
+	}
+
+	testCases := []testCase{
+		{
+			name:          "should use a later DSC that is allowed to sign a vaccination",
+			keys:          []*verifier.SigningKey{testOnlyKey, renewedKey},
+			expectedValid: true,
+			expectedCert:  renewed,
+		},
+		{
+			name:          "should not be valid if no DSC is allowed to sign a vaccination",
+			keys:          []*verifier.SigningKey{testOnlyKey},
+			expectedValid: false,
+			expectedCert:  testOnly,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			qrCodeContents := signQRCodeContents(t, decodeOutput.SignedCWT.Payload, testOnlyKey.Kid, testOnly.key)
+
+			dgVerifier, err := verifier.NewVerifier(false, false, verifier.NewMemoryKeyResolver(tc.keys...))
+			require.NoError(t, err)
+
+			verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), qrCodeContents, nil)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedValid, verifierOutput.Results.CardStructure.SignatureValid)
+			require.Equal(t, tc.expectedCert.cert, verifierOutput.SigningKey.Certificate)
+			if tc.expectedValid {
+				require.NotContains(t, verifierOutput.FailureReasons, verifier.FailureReasonKeyUsage)
+			} else {
+				require.Contains(t, verifierOutput.FailureReasons, verifier.FailureReasonKeyUsage)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package verifier
+
+import (
+	"time"
+
+	"github.com/webshield-dev/eudvcdecoder/rules"
+)
+
+//
+// Business rules, such as the entry rules of a country of arrival, are evaluated against the decoded DCC
+// at the validation time, see the rules package. A rule that fails is a FailureReasonBusinessRule, an open
+// rule could not be evaluated so is only reported in the Output RuleResults
+//
+
+//evaluateRules evaluate the VerifyOptions rules, nothing to do if there are none
+func evaluateRules(verifyOutput *Output, now time.Time, opts *VerifyOptions) error {
+
+	if opts == nil || len(opts.Rules) == 0 || verifyOutput.DCC() == nil {
+		return nil
+	}
+
+	external := &rules.External{
+		ValidationClock: now,
+		ValueSets:       opts.ValueSets,
+		CountryCode:     opts.RulesCountry,
+	}
+	if payload := verifyOutput.DecodeOutput.CommonPayload; payload != nil {
+		if payload.EXP != 0 {
+			external.Exp = time.Unix(int64(payload.EXP), 0).UTC()
+		}
+		if payload.IAT != 0 {
+			external.Iat = time.Unix(int64(payload.IAT), 0).UTC()
+		}
+	}
+
+	results, err := rules.EvaluateRules(opts.Rules, verifyOutput.DCC(), external, opts.RulesLanguage)
+	if err != nil {
+		return err
+	}
+
+	verifyOutput.RuleResults = results
+	if len(rules.Failed(results)) != 0 {
+		verifyOutput.FailureReasons = append(verifyOutput.FailureReasons, FailureReasonBusinessRule)
+	}
+
+	return nil
+}
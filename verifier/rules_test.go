@@ -0,0 +1,100 @@
+package verifier_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/internal/testvectors"
+	"github.com/webshield-dev/eudvcdecoder/rules"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+func Test_Verify_Rules(t *testing.T) {
+
+	tv, cert := testvectors.Read(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+
+	//the vaccination in DE/1 was on 2021-05-29
+	waitingPeriod := &rules.Rule{
+		Identifier:  "VR-DE-0003",
+		Type:        rules.RuleTypeAcceptance,
+		Description: []*rules.Description{{Lang: "en", Desc: "At least 14 days since the last dose"}},
+		Logic: map[string]interface{}{"not-before": []interface{}{
+			map[string]interface{}{"plusTime": []interface{}{map[string]interface{}{"var": "external.validationClock"}, 0, "day"}},
+			map[string]interface{}{"plusTime": []interface{}{map[string]interface{}{"var": "payload.v.0.dt"}, 14, "day"}},
+		}},
+	}
+	arrival := &rules.Rule{
+		Identifier: "GR-DE-0001",
+		Type:       rules.RuleTypeAcceptance,
+		Logic:      map[string]interface{}{"===": []interface{}{map[string]interface{}{"var": "external.countryCode"}, "DE"}},
+	}
+
+	type testCase struct {
+		name string
+
+		validationClock time.Time
+		country         string
+
+		expectedOutcomes []rules.Outcome
+		expectedFailure  bool
+	}
+
+	testCases := []testCase{
+		{
+			name:             "should pass the rules",
+			validationClock:  time.Date(2021, 6, 20, 0, 0, 0, 0, time.UTC),
+			country:          "DE",
+			expectedOutcomes: []rules.Outcome{rules.OutcomePassed, rules.OutcomePassed},
+		},
+		{
+			name:             "should fail the waiting period",
+			validationClock:  time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+			country:          "DE",
+			expectedOutcomes: []rules.Outcome{rules.OutcomeFailed, rules.OutcomePassed},
+			expectedFailure:  true,
+		},
+		{
+			name:             "should fail for another country",
+			validationClock:  time.Date(2021, 6, 20, 0, 0, 0, 0, time.UTC),
+			country:          "AT",
+			expectedOutcomes: []rules.Outcome{rules.OutcomePassed, rules.OutcomeFailed},
+			expectedFailure:  true,
+		},
+	}
+
+	dgVerifier, err := verifier.NewVerifier(false, false, nil)
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), []byte(tv.Prefix), &verifier.VerifyOptions{
+				PublicKey:    cert.PublicKey,
+				Clock:        verifier.FixedClock(tc.validationClock),
+				Rules:        []*rules.Rule{waitingPeriod, arrival},
+				RulesCountry: tc.country,
+			})
+			require.NoError(t, err)
+			require.NotNil(t, verifierOutput.SigningKey, "should still verify the signature")
+
+			var outcomes []rules.Outcome
+			for _, result := range verifierOutput.RuleResults {
+				outcomes = append(outcomes, result.Outcome)
+			}
+			require.Equal(t, tc.expectedOutcomes, outcomes)
+			require.Equal(t, tc.expectedFailure, containsReason(verifierOutput.FailureReasons,
+				verifier.FailureReasonBusinessRule))
+		})
+	}
+}
+
+func containsReason(reasons []verifier.FailureReason, reason verifier.FailureReason) bool {
+	for _, r := range reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package verifier
+
+import (
+	"time"
+
+	"github.com/webshield-dev/eudvcdecoder/helper"
+)
+
+//
+// CWT validity period see https://datatracker.ietf.org/doc/html/rfc8392#section-3.1
+// the credential is valid from its iat (issued at) to its exp (expires at), both seconds since epoch
+//
+
+//Clock the time to validate against, a fixed clock lets tests reproduce the dcc-testdata VALIDATIONCLOCK
+type Clock interface {
+	Now() time.Time
+}
+
+//ClockFunc adapts a function to a Clock
+type ClockFunc func() time.Time
+
+//Now see Clock
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+//FixedClock a Clock that always returns t
+func FixedClock(t time.Time) Clock {
+	return ClockFunc(func() time.Time { return t })
+}
+
+//SystemClock a Clock that returns the current time
+var SystemClock Clock = ClockFunc(time.Now)
+
+//validityPeriodFailure returns why the credential is not valid at now, empty if it is valid.
+//The clockSkew is allowed either side as the issuer and verifier clocks may differ
+func validityPeriodFailure(decodeOutput *helper.Output, now time.Time, clockSkew time.Duration) FailureReason {
+
+	if decodeOutput == nil || decodeOutput.CommonPayload == nil {
+		return ""
+	}
+	payload := decodeOutput.CommonPayload
+
+	if payload.EXP != 0 && now.After(time.Unix(int64(payload.EXP), 0).Add(clockSkew)) {
+		return FailureReasonExpired
+	}
+
+	if payload.IAT != 0 && now.Before(time.Unix(int64(payload.IAT), 0).Add(-clockSkew)) {
+		return FailureReasonNotYetValid
+	}
+
+	return ""
+}
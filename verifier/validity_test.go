@@ -0,0 +1,101 @@
+package verifier_test
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/eudvcdecoder/helper"
+	"github.com/webshield-dev/eudvcdecoder/internal/testvectors"
+	"github.com/webshield-dev/eudvcdecoder/verifier"
+)
+
+//Test_Verify_Validity_DCC_TestData the dcc-testdata vectors with an EXPECTEDEXPIRATIONCHECK at their VALIDATIONCLOCK
+func Test_Verify_Validity_DCC_TestData(t *testing.T) {
+
+	vectorPaths, err := filepath.Glob("../testfiles/dcc-testdata/*/2DCode/*/*.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, vectorPaths)
+
+	dgVerifier, err := verifier.NewVerifier(false, false, nil)
+	require.NoError(t, err)
+
+	for _, vectorPath := range vectorPaths {
+
+		jsonB, err := helper.ReadData(vectorPath)
+		require.NoError(t, err)
+		var expected struct {
+			ExpectedResults struct {
+				ExpectedExpirationCheck *bool `json:"EXPECTEDEXPIRATIONCHECK"`
+			} `json:"EXPECTEDRESULTS"`
+		}
+		require.NoError(t, json.Unmarshal(jsonB, &expected))
+		if expected.ExpectedResults.ExpectedExpirationCheck == nil {
+			continue
+		}
+		expectedValid := *expected.ExpectedResults.ExpectedExpirationCheck
+
+		t.Run(vectorPath, func(t *testing.T) {
+
+			tv, _ := testvectors.Read(t, vectorPath)
+			verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), []byte(tv.Prefix),
+				&verifier.VerifyOptions{UnSafe: true, Clock: validationClock(t, tv)})
+			require.NoError(t, err)
+
+			require.Equal(t, !expectedValid, verifierOutput.Results.CardStructure.Expired)
+		})
+	}
+}
+
+func Test_Verify_Validity(t *testing.T) {
+
+	tv, _ := testvectors.Read(t, "../testfiles/dcc-testdata/DE/2DCode/raw/1.json")
+	decodeOutput, err := helper.NewDecoder(false, false).FromQRCodeContents([]byte(tv.Prefix))
+	require.NoError(t, err)
+	iat := time.Unix(int64(decodeOutput.CommonPayload.IAT), 0)
+	exp := time.Unix(int64(decodeOutput.CommonPayload.EXP), 0)
+
+	type testCase struct {
+		name           string
+		clock          verifier.Clock
+		clockSkew      time.Duration
+		expectedReason verifier.FailureReason
+	}
+
+	testCases := []testCase{
+		{name: "should be valid between iat and exp", clock: verifier.FixedClock(iat.Add(time.Hour))},
+		{name: "should be expired after exp", clock: verifier.FixedClock(exp.Add(time.Second)),
+			expectedReason: verifier.FailureReasonExpired},
+		{name: "should allow clock skew after exp", clock: verifier.FixedClock(exp.Add(time.Minute)),
+			clockSkew: 5 * time.Minute},
+		{name: "should not be valid before iat", clock: verifier.FixedClock(iat.Add(-time.Second)),
+			expectedReason: verifier.FailureReasonNotYetValid},
+		{name: "should allow clock skew before iat", clock: verifier.FixedClock(iat.Add(-time.Minute)),
+			clockSkew: 5 * time.Minute},
+		{name: "should use system clock by default, test data has expired",
+			expectedReason: verifier.FailureReasonExpired},
+	}
+
+	dgVerifier, err := verifier.NewVerifier(false, false, nil)
+	require.NoError(t, err)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), []byte(tv.Prefix),
+				&verifier.VerifyOptions{UnSafe: true, Clock: tc.clock, ClockSkew: tc.clockSkew})
+			require.NoError(t, err)
+
+			if tc.expectedReason == "" {
+				require.False(t, verifierOutput.Results.CardStructure.Expired, "should not be expired")
+				require.Empty(t, verifierOutput.FailureReasons)
+			} else {
+				require.True(t, verifierOutput.Results.CardStructure.Expired, "should be expired")
+				require.Equal(t, []verifier.FailureReason{tc.expectedReason}, verifierOutput.FailureReasons)
+			}
+		})
+	}
+}
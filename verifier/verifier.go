@@ -2,12 +2,15 @@ package verifier
 
 import (
 	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/hex"
 	"fmt"
-	dhcPdm "github.com/webshield-dev/dhc-common/pdm"
-	"github.com/webshield-dev/dhc-common/vaccinemd"
 	"github.com/webshield-dev/dhc-common/verification"
 	eudvcdatamodel "github.com/webshield-dev/eudvcdecoder/datamodel"
 	"github.com/webshield-dev/eudvcdecoder/helper"
+	"github.com/webshield-dev/eudvcdecoder/rules"
+	"time"
 )
 
 //
@@ -18,7 +21,8 @@ import (
 type Verifier interface {
 
 	//FromFileQRCode verifies a EUDC QR code stored in a .png or .jpg file.
-	//if an error returns what it has processed so far, incase want to display
+	//if an error returns what it has processed so far, incase want to display. A decoding error wraps a
+	//helper.DecodeError with the stage that failed
 	FromFileQRCode(ctx context.Context, filename string, opts *VerifyOptions) (*Output, error)
 
 	//FromQRCodePNGBytes decode starting with a QR code PNG represented as bytes
@@ -41,8 +45,54 @@ type Output struct {
 
 	//Results captures all the verifications that occurred
 	Results *verification.CardVerificationResults
+
+	//SigningKey the key that verified the COSE signature, nil if the signature was not verified. Set even if the
+	//key was not allowed to sign the certificate type see FailureReasonKeyUsage
+	SigningKey *SigningKey
+
+	//SigningKidBucket where the kid of the verified signature was, HeaderBucketUnprotected if it was only in the
+	//unprotected header so is not covered by the signature, a caller may not accept that
+	SigningKidBucket eudvcdatamodel.HeaderBucket
+
+	//FailureReasons why verifications failed, more specific than the Results state
+	FailureReasons []FailureReason
+
+	//CertificateChain the chain from the SigningKey DSC to a CSCA root, nil if the chain was not validated or
+	//there is no valid chain
+	CertificateChain []*x509.Certificate
+
+	//TrustListFetchedAt when the trust list the keys were resolved from was fetched, zero if the
+	//KeyResolver is not a TrustListStatus
+	TrustListFetchedAt time.Time
+
+	//TrustListStale true if the trust list is older than VerifyOptions.MaxTrustListAge, or there is no list
+	TrustListStale bool
+
+	//RuleResults the outcome of each VerifyOptions business rule, passed, failed or open
+	RuleResults []*rules.Result
 }
 
+//FailureReason why a verification failed
+type FailureReason string
+
+const (
+	//FailureReasonKeyUsage the DSC extended key usage does not allow it to sign this type of certificate,
+	//for example a test only key signed a vaccination certificate
+	FailureReasonKeyUsage FailureReason = "dsc_key_usage_not_allowed"
+
+	//FailureReasonCertificateChain the DSC does not chain to one of the CSCA roots
+	FailureReasonCertificateChain FailureReason = "dsc_certificate_chain_not_valid"
+
+	//FailureReasonExpired the validation time is after the CWT exp
+	FailureReasonExpired FailureReason = "expired"
+
+	//FailureReasonNotYetValid the validation time is before the CWT iat, the credential was issued in the future
+	FailureReasonNotYetValid FailureReason = "not_yet_valid"
+
+	//FailureReasonBusinessRule one or more of the business rules failed, see the Output RuleResults
+	FailureReasonBusinessRule FailureReason = "business_rule_failed"
+)
+
 //DCC return the (Digital Covid Certificate) inside the record, if none returns nil
 func (o *Output) DCC() *eudvcdatamodel.DCC {
 	if o.DecodeOutput != nil {
@@ -57,23 +107,73 @@ type VerifyOptions struct {
 	//UnSafe if set does not verify the signature, somehow the client knows valid.
 	UnSafe bool
 
-	//FakeVerificationResultValid if passed in the card verification results will be fake values
-	//required for some strange demo situation, do not reuce
-	FakeVerificationResultValid bool
+	//PublicKey the issuers public key (*ecdsa.PublicKey for ES256 or *rsa.PublicKey for PS256) used to
+	//verify the COSE signature, if set the key resolvers are not used
+	PublicKey crypto.PublicKey
+
+	//KeyResolver if set used instead of the verifiers KeyResolver to find the signing keys
+	KeyResolver KeyResolver
+
+	//CSCARoots if set the DSC that verified the signature must chain to one of these Country Signing CAs
+	//for the issuer to be trusted, the validity periods are checked at the credential iat
+	CSCARoots *x509.CertPool
+
+	//Clock the time to check the credential iat and exp against, if nil uses the SystemClock. The immunization
+	//checks are from dhc-common which always uses the current time
+	Clock Clock
+
+	//ClockSkew tolerance allowed when checking the iat and exp
+	ClockSkew time.Duration
+
+	//MaxTrustListAge if set and the KeyResolver is a TrustListStatus, the Output is flagged as
+	//TrustListStale when the trust list is older than this
+	MaxTrustListAge time.Duration
+
+	//Rules if set the business rules are evaluated against the DCC at the Clock time, see the rules package
+	Rules []*rules.Rule
+
+	//RulesCountry the country of arrival the Rules are for, the rules external countryCode
+	RulesCountry string
+
+	//RulesLanguage the language for the rule descriptions, English if empty or not available
+	RulesLanguage string
+
+	//ValueSets the codes in each value set by id, the rules external valueSets, see ValueSetMapper.ValueSets
+	ValueSets map[string][]string
+}
+
+//NewVerifier make a verifier with the DefaultDecoderOptions limits, keyResolver is used to find the keys to
+//verify the signature, if nil and no key is passed in the VerifyOptions then the signature is not checked
+func NewVerifier(debug bool, maxDebug bool, keyResolver KeyResolver) (Verifier, error) {
+	return NewVerifierWithOptions(debug, maxDebug, keyResolver, nil)
 }
 
-//NewVerifier make a verifier
-func NewVerifier(debug bool, maxDebug bool) (Verifier, error) {
+//NewVerifierWithOptions make a verifier that decodes with the decoder limits, see helper.NewDecoderWithOptions. An
+//error if a limit is out of range
+func NewVerifierWithOptions(debug bool, maxDebug bool, keyResolver KeyResolver,
+	decoderOpts *helper.DecoderOptions) (Verifier, error) {
 
-	decoder := helper.NewDecoder(debug, maxDebug)
+	decoder, err := helper.NewDecoderWithOptions(debug, maxDebug, decoderOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	//maps the vaccination medicinal product to CVX for the immunization checks
+	vsMapper, err := helper.NewEmbeddedValueSetMapper()
+	if err != nil {
+		return nil, fmt.Errorf("error loading the value sets err=%s", err)
+	}
 
-	return &verifierImpl{debug: debug, maxDebug: maxDebug, decoder: decoder}, nil
+	return &verifierImpl{debug: debug, maxDebug: maxDebug, decoder: decoder, keyResolver: keyResolver,
+		vsMapper: vsMapper}, nil
 }
 
 type verifierImpl struct {
-	debug    bool
-	maxDebug bool
-	decoder  helper.Decoder
+	debug       bool
+	maxDebug    bool
+	decoder     helper.Decoder
+	keyResolver KeyResolver
+	vsMapper    *helper.ValueSetMapper
 }
 
 func (v *verifierImpl) FromFileQRCode(ctx context.Context, filename string, opts *VerifyOptions) (*Output, error) {
@@ -84,7 +184,7 @@ func (v *verifierImpl) FromFileQRCode(ctx context.Context, filename string, opts
 	decodeOutput, err := v.decoder.FromFileQRCode(filename)
 	if err != nil {
 		verifyOutput.DecodeOutput = decodeOutput //some decode stages may have passed
-		return verifyOutput, fmt.Errorf("error decoding the digital credential err=%s", err)
+		return verifyOutput, fmt.Errorf("error decoding the digital credential err=%w", err)
 	}
 	verifyOutput.DecodeOutput = decodeOutput
 	if !decodeOutput.Decoded {
@@ -93,7 +193,7 @@ func (v *verifierImpl) FromFileQRCode(ctx context.Context, filename string, opts
 	}
 
 	//verify signature
-	if err = v.verify(verifyOutput, opts); err != nil {
+	if err = v.verify(ctx, verifyOutput, opts); err != nil {
 		return verifyOutput, fmt.Errorf("error verifying the digital credential err=%s", err)
 
 	}
@@ -109,11 +209,11 @@ func (v *verifierImpl) FromQRCodePNGBytes(ctx context.Context, pngB []byte, opts
 	decodeOutput, err := v.decoder.FromQRCodePNGBytes(pngB)
 	if err != nil {
 		verifyOutput.DecodeOutput = decodeOutput //some decode stages may have passed
-		return verifyOutput, fmt.Errorf("error decoding the digital credential err=%s", err)
+		return verifyOutput, fmt.Errorf("error decoding the digital credential err=%w", err)
 	}
 	verifyOutput.DecodeOutput = decodeOutput
 
-	if err = v.verify(verifyOutput, opts); err != nil {
+	if err = v.verify(ctx, verifyOutput, opts); err != nil {
 		return verifyOutput, fmt.Errorf("error verifying the digital credential err=%s", err)
 	}
 
@@ -132,62 +232,298 @@ func (v *verifierImpl) FromQRCodeContents(ctx context.Context, qrCodeContents []
 	decodeOutput, err := v.decoder.FromQRCodeContents(qrCodeContents)
 	if err != nil {
 		verifyOutput.DecodeOutput = decodeOutput //some decode stages may have passed
-		return verifyOutput, fmt.Errorf("error decoding the digital credential err=%s", err)
+		return verifyOutput, fmt.Errorf("error decoding the digital credential err=%w", err)
 	}
 	verifyOutput.DecodeOutput = decodeOutput
 
-	if err = v.verify(verifyOutput, opts); err != nil {
+	if err = v.verify(ctx, verifyOutput, opts); err != nil {
 		return verifyOutput, fmt.Errorf("error verifying the digital credential err=%s", err)
 	}
 
 	return verifyOutput, nil
 }
 
-func (v *verifierImpl) verify(verifyOutput *Output, opts *VerifyOptions) error {
+func (v *verifierImpl) verify(ctx context.Context, verifyOutput *Output, opts *VerifyOptions) error {
+
+	vp := verification.NewProcessor()
 
 	//
-	// Fixme no verifications are currently performed so this state will always been unknown.
-	// for product demos (as still in dev) to prospects want to show a valid so allow client
-	// to override to fake verification results
+	// Verify the COSE signature
+	//
+	if opts == nil || !opts.UnSafe {
 
-	vp := verification.NewProcessor()
+		v.trustListStatus(verifyOutput, opts)
 
-	results := vp.GetVerificationResults()
-
-	if opts != nil && opts.FakeVerificationResultValid && results.State != verification.CardVerificationStateValid {
-		//fixme comeback and revist, see issue, for now added as upper layers needed to demo code
-		//to prospect and need to be able to show as valid for now.
-		vp.SetFetchedKey()
-		vp.SetSignatureChecked()
-		vp.SetSignatureValid()
-		vp.SetIssuerTrusted()
-
-		//fake immunization, so does not matter what use
-		doses := []*dhcPdm.Dose{
-			{
-				Coding: vaccinemd.Coding{
-					System: vaccinemd.CVXSystem,
-					Code:   "207", //moderna
-				},
-
-				OccurrenceDateTime: "2021-03-16",
-			},
-			{
-				Coding: vaccinemd.Coding{
-					System: vaccinemd.CVXSystem,
-					Code:   "207", //moderna
-				},
-
-				OccurrenceDateTime: "2021-04-06",
-			},
+		candidates, err := v.signingKeys(ctx, verifyOutput.DecodeOutput, opts)
+		if err != nil {
+			verifyOutput.Results = vp.GetVerificationResults()
+			return err
 		}
 
-		_, _ = vp.VerifyImmunization(vaccinemd.RegionUSA, doses)
+		if len(candidates) != 0 {
+			vp.SetFetchedKey()
+
+			//try each candidate key for each signer, only need one to verify. A kid can match several DSCs, such
+			//as a renewed DSC with the same key, and a COSE_Sign has several signers, so prefer one that is allowed
+			//to sign this type of certificate and chains to a trusted CSCA
+			checked := false
+			var checkErr error
+			var best *signerCheck
+			for _, candidate := range candidates {
+				valid, err := verifyCOSESignature(verifyOutput.DecodeOutput, candidate)
+				if err != nil {
+					checkErr = err
+					continue
+				}
+
+				checked = true
+				if !valid {
+					continue
+				}
+
+				check := newSignerCheck(verifyOutput, candidate, opts, v.now(opts))
+				if best == nil || check.rank() > best.rank() {
+					best = check
+				}
+				if best.trusted() {
+					break
+				}
+			}
+
+			if !checked {
+				//none of the keys could be used so cannot say if the signature is valid or not
+				verifyOutput.Results = vp.GetVerificationResults()
+				return checkErr
+			}
+			vp.SetSignatureChecked()
+
+			if best != nil {
+				setSigningKey(verifyOutput, best.candidate)
+
+				//the signature is only valid if the DSC is allowed to sign this type of certificate
+				if best.keyUsageAllowed {
+					vp.SetSignatureValid()
+				} else {
+					verifyOutput.FailureReasons = append(verifyOutput.FailureReasons, FailureReasonKeyUsage)
+				}
+
+				//no chain is not an error, the issuer is just not trusted
+				if best.chainChecked {
+					if best.chain != nil {
+						vp.SetIssuerTrusted()
+						verifyOutput.CertificateChain = best.chain
+					} else {
+						verifyOutput.FailureReasons = append(verifyOutput.FailureReasons, FailureReasonCertificateChain)
+					}
+				}
+			}
+		}
+	}
 
-		results = vp.GetVerificationResults()
+	//
+	// Verify the credential is valid now, a credential issued in the future is treated as expired
+	// as it is not valid at this time
+	//
+	if reason := validityPeriodFailure(verifyOutput.DecodeOutput, v.now(opts), v.clockSkew(opts)); reason != "" {
+		vp.SetExpired()
+		verifyOutput.FailureReasons = append(verifyOutput.FailureReasons, reason)
+	}
 
+	//
+	// Evaluate the business rules
+	//
+	if err := evaluateRules(verifyOutput, v.now(opts), opts); err != nil {
+		verifyOutput.Results = vp.GetVerificationResults()
+		return err
 	}
 
-	verifyOutput.Results = results
+	//
+	// Verify the vaccination meets the immunization criteria
+	//
+	if err := verifyImmunization(vp, verifyOutput.DCC(), v.vsMapper); err != nil {
+		verifyOutput.Results = vp.GetVerificationResults()
+		return err
+	}
+
+	verifyOutput.Results = vp.GetVerificationResults()
 	return nil
 }
+
+//setSigningKey record the candidate that verified the signature
+func setSigningKey(verifyOutput *Output, candidate *signerKey) {
+
+	verifyOutput.SigningKey = candidate.key
+	verifyOutput.SigningKidBucket = candidate.headers.KidBucket()
+}
+
+//signerCheck the checks of a candidate key that verified the signature
+type signerCheck struct {
+	candidate *signerKey
+
+	//keyUsageAllowed the DSC is allowed to sign this type of certificate
+	keyUsageAllowed bool
+
+	//chainChecked there are CSCA roots in the options so the DSC chain was checked
+	chainChecked bool
+
+	//chain from the DSC to a CSCA root, nil if there is no chain
+	chain []*x509.Certificate
+}
+
+//newSignerCheck check the key usage of the candidate DSC and, if there are CSCA roots, its chain at the credential
+//iat or now if there is no iat
+func newSignerCheck(verifyOutput *Output, candidate *signerKey, opts *VerifyOptions, now time.Time) *signerCheck {
+
+	check := &signerCheck{
+		candidate:       candidate,
+		keyUsageAllowed: keyUsageAllowed(candidate.key.Certificate, certificateType(verifyOutput.DCC())),
+	}
+
+	if opts != nil && opts.CSCARoots != nil {
+		check.chainChecked = true
+		if chain, err := verifyCertificateChain(candidate.key.Certificate, opts.CSCARoots,
+			verifyOutput.DecodeOutput, now); err == nil {
+			check.chain = chain
+		}
+	}
+
+	return check
+}
+
+//trusted true if the DSC passes the key usage and, if checked, the chain
+func (c *signerCheck) trusted() bool {
+	return c.keyUsageAllowed && (!c.chainChecked || c.chain != nil)
+}
+
+//rank higher the more checks passed, the key usage counts most as it decides if the signature is valid
+func (c *signerCheck) rank() int {
+
+	rank := 0
+	if c.keyUsageAllowed {
+		rank += 2
+	}
+	if !c.chainChecked || c.chain != nil {
+		rank++
+	}
+
+	return rank
+}
+
+//signerKey a candidate key to verify one of the message signatures
+type signerKey struct {
+	signature *eudvcdatamodel.COSESignature
+	headers   *eudvcdatamodel.COSEHeaders
+	key       *SigningKey
+}
+
+//signingKeys the candidate keys to verify each of the message signatures, a COSE_Sign has one signature per
+//signer. A key in the options takes precedence over the options KeyResolver which takes precedence over the
+//verifiers KeyResolver. Only errors if no keys were found for any signature
+func (v *verifierImpl) signingKeys(ctx context.Context, decodeOutput *helper.Output,
+	opts *VerifyOptions) ([]*signerKey, error) {
+
+	if len(decodeOutput.SignatureHeaders) != len(decodeOutput.Signatures) {
+		return nil, fmt.Errorf("error the decoded signatures have no headers")
+	}
+
+	if opts != nil && opts.PublicKey != nil {
+		var candidates []*signerKey
+		for i, signature := range decodeOutput.Signatures {
+			candidates = append(candidates, &signerKey{signature: signature, headers: decodeOutput.SignatureHeaders[i],
+				key: &SigningKey{PublicKey: opts.PublicKey}})
+		}
+		return candidates, nil
+	}
+
+	keyResolver := v.resolver(opts)
+	if keyResolver == nil {
+		return nil, nil
+	}
+
+	var candidates []*signerKey
+	var resolveErr error
+	for i, signature := range decodeOutput.Signatures {
+		headers := decodeOutput.SignatureHeaders[i]
+		keys, err := resolveKeys(ctx, keyResolver, decodeOutput, headers)
+		if err != nil {
+			if resolveErr == nil {
+				resolveErr = err
+			}
+			continue
+		}
+		for _, key := range keys {
+			candidates = append(candidates, &signerKey{signature: signature, headers: headers, key: key})
+		}
+	}
+
+	if len(candidates) == 0 && resolveErr != nil {
+		return nil, resolveErr
+	}
+
+	return candidates, nil
+}
+
+//resolveKeys the keys for the kid in the signature headers
+func resolveKeys(ctx context.Context, keyResolver KeyResolver, decodeOutput *helper.Output,
+	headers *eudvcdatamodel.COSEHeaders) ([]*SigningKey, error) {
+
+	kid, err := keyID(headers)
+	if err != nil {
+		return nil, err
+	}
+
+	country := ""
+	if decodeOutput.CommonPayload != nil {
+		country = decodeOutput.CommonPayload.ISS
+	}
+
+	keys, err := keyResolver.ResolveKeys(ctx, kid, country)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving signing keys kid=%s country=%s err=%s",
+			hex.EncodeToString(kid), country, err)
+	}
+
+	return keys, nil
+}
+
+//resolver the options KeyResolver if set otherwise the verifiers
+func (v *verifierImpl) resolver(opts *VerifyOptions) KeyResolver {
+	if opts != nil && opts.KeyResolver != nil {
+		return opts.KeyResolver
+	}
+	return v.keyResolver
+}
+
+//trustListStatus if the keys come from a trust list record how old it is
+func (v *verifierImpl) trustListStatus(verifyOutput *Output, opts *VerifyOptions) {
+
+	if opts != nil && opts.PublicKey != nil {
+		return
+	}
+
+	status, ok := v.resolver(opts).(TrustListStatus)
+	if !ok {
+		return
+	}
+
+	verifyOutput.TrustListFetchedAt = status.TrustListFetchedAt()
+	if opts != nil && opts.MaxTrustListAge > 0 {
+		verifyOutput.TrustListStale = verifyOutput.TrustListFetchedAt.IsZero() ||
+			status.TrustListAge() > opts.MaxTrustListAge
+	}
+}
+
+//now the time to validate against
+func (v *verifierImpl) now(opts *VerifyOptions) time.Time {
+	if opts != nil && opts.Clock != nil {
+		return opts.Clock.Now()
+	}
+	return SystemClock.Now()
+}
+
+func (v *verifierImpl) clockSkew(opts *VerifyOptions) time.Duration {
+	if opts != nil {
+		return opts.ClockSkew
+	}
+	return 0
+}
@@ -2,8 +2,10 @@ package verifier_test
 
 import (
 	"context"
+	"errors"
 	"github.com/stretchr/testify/require"
 	"github.com/webshield-dev/dhc-common/verification"
+	"github.com/webshield-dev/eudvcdecoder/helper"
 	"github.com/webshield-dev/eudvcdecoder/verifier"
 	"io/ioutil"
 	"testing"
@@ -16,11 +18,15 @@ func Test_Verifier(t *testing.T) {
 		name              string
 		qrCodePath        string
 		expectedCardState verification.CardVerificationState
+		expectedDosesMet  bool
 	}
 
 	//
 	//test data https://github.com/eu-digital-green-certificates/dgc-testdata
-	//the verifier today does not check any immunization criteria hence state is unknown
+	//the immunization criteria are only checked for a vaccination, a test or recovery is unknown. With no key the
+	//signature is not checked so a vaccination that meets the criteria is unverified. The immunization checks use
+	//the current time, the vaccinations that do not meet the criteria fail on the doses or the vaccine type which
+	//do not depend on it, the German vaccination dose was on 2021-05-29 so is always long enough ago
 	testCases := []testCase{
 		{
 			name:              "should decode a WebShield generated file",
@@ -30,7 +36,7 @@ func Test_Verifier(t *testing.T) {
 		{
 			name:              "should support ireland vaccine qr code",
 			qrCodePath:        "../testfiles/dcc-testdata/IE/png/1_qr.png",
-			expectedCardState: verification.CardVerificationStateUnknown,
+			expectedCardState: verification.CardVerificationStateSafetyCriteriaNotMet,
 		},
 		{
 			name:              "should support greece test qr code png",
@@ -40,21 +46,22 @@ func Test_Verifier(t *testing.T) {
 		{
 			name:              "should support NL vaccine qr code png",
 			qrCodePath:        "../testfiles/dcc-testdata/NL/png/072-NL-vaccination.png",
-			expectedCardState: verification.CardVerificationStateUnknown,
+			expectedCardState: verification.CardVerificationStateSafetyCriteriaNotMet,
 		},
 		{
 			name:              "should support German Vaccine qr code png",
 			qrCodePath:        "../testfiles/dcc-testdata/DE/2DCode/png/1.png",
-			expectedCardState: verification.CardVerificationStateUnknown,
+			expectedCardState: verification.CardVerificationStateUnVerified,
+			expectedDosesMet:  true,
 		},
 		{
 			name:              "should support austria vaccine qr code png",
 			qrCodePath:        "../testfiles/dcc-testdata/AT/png/1.png",
-			expectedCardState: verification.CardVerificationStateUnknown,
+			expectedCardState: verification.CardVerificationStateSafetyCriteriaNotMet,
 		},
 	}
 
-	dgVerifier, err := verifier.NewVerifier(true, true)
+	dgVerifier, err := verifier.NewVerifier(true, true, nil)
 	require.NoError(t, err)
 
 	for _, tc := range testCases {
@@ -74,59 +81,39 @@ func Test_Verifier(t *testing.T) {
 			require.NotNil(t, verifierOutput)
 
 			require.Equal(t, tc.expectedCardState, verifierOutput.Results.State)
+			require.Equal(t, tc.expectedDosesMet, verifierOutput.Results.Immunization.MetDosesRequiredCriteria)
 
 		})
 	}
 }
 
-func Test_FakeVerificationResult(t *testing.T) {
+func Test_Verifier_Decode_Error_Stage(t *testing.T) {
 
-	type testCase struct {
-		name              string
-		qrCodePath        string
-		opts              *verifier.VerifyOptions
-		expectedCardState verification.CardVerificationState
-	}
+	dgVerifier, err := verifier.NewVerifier(false, false, nil)
+	require.NoError(t, err)
 
-	//
-	//test data https://github.com/eu-digital-green-certificates/dgc-testdata
-	//
-	testCases := []testCase{
-		{
-			name:              "should be valid if faking results",
-			opts:              &verifier.VerifyOptions{FakeVerificationResultValid: true},
-			qrCodePath:        "../testfiles/dcc-testdata/IE/png/1_qr.png",
-			expectedCardState: verification.CardVerificationStateValid,
-		},
-		{
-			name:              "should be unverified if faking results",
-			opts:              &verifier.VerifyOptions{FakeVerificationResultValid: false},
-			qrCodePath:        "../testfiles/dcc-testdata/IE/png/1_qr.png",
-			expectedCardState: verification.CardVerificationStateUnknown,
-		},
-	}
+	verifierOutput, err := dgVerifier.FromQRCodeContents(context.TODO(), []byte("HC1:6BFO~N"), nil)
+	require.Error(t, err)
+	require.NotNil(t, verifierOutput.DecodeOutput, "should return what was decoded")
 
-	dgVerifier, err := verifier.NewVerifier(true, true)
-	require.NoError(t, err)
+	var decodeErr *helper.DecodeError
+	require.True(t, errors.As(err, &decodeErr), "should wrap the DecodeError")
+	require.Equal(t, helper.StageBase45, decodeErr.Stage)
+}
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
+func Test_Verifier_Decoder_Options(t *testing.T) {
 
-			ctx := context.TODO()
-			verifierOutput, err := dgVerifier.FromFileQRCode(ctx, tc.qrCodePath, tc.opts)
-			require.NoError(t, err)
-			require.NotNil(t, verifierOutput)
-			require.True(t, dgVerifier.IsDGCFromQRCodeContents(verifierOutput.DecodeOutput.DecodedQRCode), "should be a DGC")
+	_, err := verifier.NewVerifierWithOptions(false, false, nil, &helper.DecoderOptions{MaxNestedLevels: 2})
+	require.Error(t, err, "should error if a limit is out of range")
 
-			//from bytes
-			pngB, err := ioutil.ReadFile(tc.qrCodePath)
-			require.NoError(t, err)
-			verifierOutput, err = dgVerifier.FromQRCodePNGBytes(ctx, pngB, tc.opts)
-			require.NoError(t, err)
-			require.NotNil(t, verifierOutput)
+	dgVerifier, err := verifier.NewVerifierWithOptions(false, false, nil, &helper.DecoderOptions{MaxInflatedSize: 64})
+	require.NoError(t, err)
 
-			require.Equal(t, tc.expectedCardState, verifierOutput.Results.State)
+	_, err = dgVerifier.FromFileQRCode(context.TODO(), "../testfiles/dcc-testdata/DE/2DCode/png/1.png", nil)
+	require.Error(t, err)
 
-		})
-	}
+	var limitErr *helper.LimitError
+	require.True(t, errors.As(err, &limitErr), "should decode with the options limits err=%s", err)
+	require.Equal(t, helper.LimitInflatedSize, limitErr.Limit)
+	require.Equal(t, 64, limitErr.Max)
 }